@@ -0,0 +1,161 @@
+package set
+
+import (
+	"errors"
+)
+
+// ErrNotIterable is returned by the set algebra functions and NewBlockingSet for the sets that don't implement Iterable.
+var ErrNotIterable = errors.New("set does not implement Iterable")
+
+// Constructor builds an empty set, e.g. NewLazySyncSet.
+type Constructor func(opts ...Option) Set
+
+// The set algebra functions merge the sorted lists of their arguments in a single pass and append
+// the values of the result to the tail of a new set built with the given constructor and options.
+//
+// The arguments are read one after another with Ascend, so the result reflects a snapshot of each argument
+// only if it isn't modified concurrently (or it's a coarse-grained set); otherwise the values inserted
+// or removed during the call may or may not be taken into account (see Iterable).
+
+// Union returns a new set containing the values present in a or b.
+func Union(a, b Set, newSet Constructor, opts ...Option) (Set, error) {
+	return combine(a, b, newSet, opts, func(inA, inB bool) bool { return inA || inB })
+}
+
+// Intersection returns a new set containing the values present both in a and b.
+func Intersection(a, b Set, newSet Constructor, opts ...Option) (Set, error) {
+	return combine(a, b, newSet, opts, func(inA, inB bool) bool { return inA && inB })
+}
+
+// Difference returns a new set containing the values of a absent in b.
+func Difference(a, b Set, newSet Constructor, opts ...Option) (Set, error) {
+	return combine(a, b, newSet, opts, func(inA, inB bool) bool { return inA && !inB })
+}
+
+// SymmetricDifference returns a new set containing the values present in exactly one of a and b.
+func SymmetricDifference(a, b Set, newSet Constructor, opts ...Option) (Set, error) {
+	return combine(a, b, newSet, opts, func(inA, inB bool) bool { return inA != inB })
+}
+
+// Subset reports whether every value of a is present in b.
+func Subset(a, b Set) (bool, error) {
+	subset := true
+
+	err := merge(a, b, func(value int, inA, inB bool) bool {
+		subset = !inA || inB
+
+		return subset
+	})
+	if err != nil {
+		return false, err
+	}
+
+	return subset, nil
+}
+
+// combine builds a new set of the values for which keep returns true.
+func combine(a, b Set, newSet Constructor, opts []Option, keep func(inA, inB bool) bool) (Set, error) {
+	s := newSet(opts...)
+	appendValue := appenderOf(s)
+
+	err := merge(a, b, func(value int, inA, inB bool) bool {
+		if keep(inA, inB) {
+			appendValue(value)
+		}
+
+		return true
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	return s, nil
+}
+
+// sortedBuilder is implemented by the sets building their list from ascending values in a single pass:
+// appender returns the function linking the node of the value after the previously appended one.
+// It's used only for a new empty set that is not shared until the build is over.
+type sortedBuilder interface {
+	appender() func(value int)
+}
+
+var (
+	_ sortedBuilder = (*sequentialSet)(nil)
+	_ sortedBuilder = (*coarseGrainedSyncSet)(nil)
+	_ sortedBuilder = (*fineGrainedSyncSet)(nil)
+	_ sortedBuilder = (*optimisticSyncSet)(nil)
+	_ sortedBuilder = (*lazySyncSet)(nil)
+	_ sortedBuilder = (*nonBlockingSet)(nil)
+	_ sortedBuilder = (*hazardNonBlockingSet)(nil)
+	_ sortedBuilder = (*stmSet)(nil)
+	_ sortedBuilder = (*mvccSet)(nil)
+	_ sortedBuilder = (*tracedSet)(nil)
+)
+
+// appenderOf returns the function adding the ascending values to the new set; the sets of other packages
+// get them by Insert.
+func appenderOf(s Set) func(value int) {
+	if builder, ok := s.(sortedBuilder); ok {
+		return builder.appender()
+	}
+
+	return func(value int) { s.Insert(value) }
+}
+
+// merge calls visit for the values of both sets in ascending order until visit returns false.
+// The values of b are copied before the walk of a, so a walk never waits for the other one
+// (e.g. when both arguments are the same coarse-grained set).
+func merge(a, b Set, visit func(value int, inA, inB bool) bool) error {
+	left, ok := a.(Iterable)
+	if !ok {
+		return ErrNotIterable
+	}
+
+	right, err := values(b)
+	if err != nil {
+		return err
+	}
+
+	j, proceed := 0, true
+
+	left.Ascend(func(value int) bool {
+		for ; j < len(right) && right[j] < value; j++ {
+			if proceed = visit(right[j], false, true); !proceed {
+				return false
+			}
+		}
+
+		inB := j < len(right) && right[j] == value
+		if inB {
+			j++
+		}
+
+		proceed = visit(value, true, inB)
+
+		return proceed
+	})
+
+	for ; proceed && j < len(right); j++ {
+		proceed = visit(right[j], false, true)
+	}
+
+	return nil
+}
+
+// values copies the values of the set.
+func values(s Set) ([]int, error) {
+	iterable, ok := s.(Iterable)
+	if !ok {
+		return nil, ErrNotIterable
+	}
+
+	var result []int
+
+	iterable.Ascend(func(value int) bool {
+		result = append(result, value)
+
+		return true
+	})
+
+	return result, nil
+}
@@ -0,0 +1,340 @@
+package set
+
+import (
+	"math/rand"
+	"sort"
+	"sync"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+// ascend returns the values of the set in the order reported by Ascend.
+func ascend(t *testing.T, s Set) []int {
+	iterable, ok := s.(Iterable)
+	require.True(t, ok)
+
+	result := []int{}
+
+	iterable.Ascend(func(value int) bool {
+		result = append(result, value)
+
+		return true
+	})
+
+	return result
+}
+
+// sortedKeys returns the values of the map-based oracle in ascending order.
+func sortedKeys(oracle map[int]bool) []int {
+	result := []int{}
+
+	for value := range oracle {
+		result = append(result, value)
+	}
+
+	sort.Ints(result)
+
+	return result
+}
+
+// mapSet is a Set that doesn't implement Iterable.
+type mapSet map[int]bool
+
+func (m mapSet) Insert(value int) bool {
+	if m[value] {
+		return false
+	}
+
+	m[value] = true
+
+	return true
+}
+
+func (m mapSet) Contains(value int) bool { return m[value] }
+
+func (m mapSet) Remove(value int) bool {
+	if !m[value] {
+		return false
+	}
+
+	delete(m, value)
+
+	return true
+}
+
+func TestAscend(t *testing.T) {
+	f := factory{}
+
+	kinds := []setKind{
+		sequential,
+		coarseGrained,
+		fineGrained,
+		optimistic,
+		lazy,
+		nonBlocking,
+		hazardNonBlocking,
+		stm,
+		mvcc,
+	}
+
+	for _, k := range kinds {
+		k := k
+
+		t.Run(k.String(), func(t *testing.T) {
+			set := f.new(k, WithTracing())
+			require.Empty(t, ascend(t, set))
+
+			for _, value := range []int{5, -3, 8, 0, 1} {
+				require.True(t, set.Insert(value))
+			}
+
+			require.True(t, set.Remove(0))
+			require.Equal(t, []int{-3, 1, 5, 8}, ascend(t, set))
+
+			// the walk stops when yield returns false
+			var visited []int
+
+			iterable, ok := set.(Iterable)
+			require.True(t, ok)
+			iterable.Ascend(func(value int) bool {
+				visited = append(visited, value)
+
+				return value < 1
+			})
+			require.Equal(t, []int{-3, 1}, visited)
+		})
+	}
+}
+
+// TestAscendConcurrent verifies that a walk concurrent with writers reports the stable values
+// in ascending order and never reports the values that are never inserted.
+func TestAscendConcurrent(t *testing.T) {
+	f := factory{}
+
+	kinds := []setKind{
+		coarseGrained,
+		fineGrained,
+		optimistic,
+		lazy,
+		nonBlocking,
+		hazardNonBlocking,
+		stm,
+		mvcc,
+	}
+
+	const (
+		keys    = 256
+		writers = 2
+		walks   = 50
+	)
+
+	for _, k := range kinds {
+		k := k
+
+		t.Run(k.String(), func(t *testing.T) {
+			set := f.new(k, WithNodeRecycling())
+
+			// even values stay in the set, odd values are inserted and removed by the writers
+			for value := 0; value < keys; value += 2 {
+				require.True(t, set.Insert(value))
+			}
+
+			done := make(chan struct{})
+			wg := sync.WaitGroup{}
+			wg.Add(writers)
+
+			for i := 0; i < writers; i++ {
+				go func() {
+					defer wg.Done()
+
+					for j := 1; ; j = (j + 2) % keys {
+						select {
+						case <-done:
+							return
+						default:
+						}
+
+						set.Insert(j)
+						set.Remove(j)
+					}
+				}()
+			}
+
+			for i := 0; i < walks; i++ {
+				visited := ascend(t, set)
+				require.True(t, sort.IntsAreSorted(visited))
+
+				even := 0
+
+				for j, value := range visited {
+					require.True(t, value >= 0 && value < keys, value)
+
+					if j > 0 {
+						require.NotEqual(t, visited[j-1], value)
+					}
+
+					if value%2 == 0 {
+						even++
+					}
+				}
+
+				require.Equal(t, keys/2, even)
+			}
+
+			close(done)
+			wg.Wait()
+		})
+	}
+}
+
+func TestAlgebra(t *testing.T) {
+	f := factory{}
+
+	kinds := []setKind{
+		sequential,
+		coarseGrained,
+		fineGrained,
+		optimistic,
+		lazy,
+		nonBlocking,
+		hazardNonBlocking,
+		stm,
+		mvcc,
+	}
+
+	constructors := map[setKind]Constructor{
+		sequential:        NewSequentialSet,
+		coarseGrained:     NewCoarseGrainedSyncSet,
+		fineGrained:       NewFineGrainedSyncSet,
+		optimistic:        NewOptimisticSyncSet,
+		lazy:              NewLazySyncSet,
+		nonBlocking:       NewNonBlockingSyncSet,
+		hazardNonBlocking: NewHazardNonBlockingSyncSet,
+		stm:               func(opts ...Option) Set { return NewSTMSet(opts...) },
+		mvcc:              func(opts ...Option) Set { return NewMVCCSet(opts...) },
+	}
+
+	operations := []struct {
+		name  string
+		apply func(a, b Set, newSet Constructor, opts ...Option) (Set, error)
+		keep  func(inA, inB bool) bool
+	}{
+		{"union", Union, func(inA, inB bool) bool { return inA || inB }},
+		{"intersection", Intersection, func(inA, inB bool) bool { return inA && inB }},
+		{"difference", Difference, func(inA, inB bool) bool { return inA && !inB }},
+		{"symmetric_difference", SymmetricDifference, func(inA, inB bool) bool { return inA != inB }},
+	}
+
+	rnd := rand.New(rand.NewSource(1)) //nolint:gosec // reproducible test data
+
+	fill := func(s Set, size, keySpace int) map[int]bool {
+		oracle := map[int]bool{}
+
+		for i := 0; i < size; i++ {
+			value := rnd.Intn(keySpace) - keySpace/2
+			require.Equal(t, !oracle[value], s.Insert(value))
+			oracle[value] = true
+		}
+
+		return oracle
+	}
+
+	for i, k := range kinds {
+		// the arguments and the result are of different implementations
+		k, other, result := k, kinds[(i+1)%len(kinds)], kinds[(i+2)%len(kinds)]
+
+		t.Run(k.String(), func(t *testing.T) {
+			for _, size := range []int{0, 1, 10, 200} {
+				a, b := f.new(k), f.new(other)
+				oracleA, oracleB := fill(a, size, 2*size+1), fill(b, size/2, 2*size+1)
+
+				for _, op := range operations {
+					expected := map[int]bool{}
+
+					for value := range oracleA {
+						if op.keep(true, oracleB[value]) {
+							expected[value] = true
+						}
+					}
+
+					for value := range oracleB {
+						if op.keep(oracleA[value], true) {
+							expected[value] = true
+						}
+					}
+
+					s, err := op.apply(a, b, constructors[result])
+					require.NoError(t, err)
+					require.Equal(t, sortedKeys(expected), ascend(t, s), op.name)
+
+					checker, ok := s.(invariantChecker)
+					require.True(t, ok)
+					require.NoError(t, checker.checkInvariants())
+
+					// the appended nodes are ordinary nodes of the result
+					for value := range expected {
+						require.False(t, s.Insert(value), value)
+						require.True(t, s.Remove(value), value)
+					}
+
+					require.Empty(t, ascend(t, s), op.name)
+				}
+
+				subset := true
+
+				for value := range oracleB {
+					subset = subset && oracleA[value]
+				}
+
+				isSubset, err := Subset(b, a)
+				require.NoError(t, err)
+				require.Equal(t, subset, isSubset)
+
+				isSubset, err = Subset(a, a)
+				require.NoError(t, err)
+				require.True(t, isSubset)
+
+				// the arguments are not modified
+				require.Equal(t, sortedKeys(oracleA), ascend(t, a))
+				require.Equal(t, sortedKeys(oracleB), ascend(t, b))
+			}
+		})
+	}
+}
+
+func TestAlgebraOptions(t *testing.T) {
+	var inserted []int
+
+	a := NewSequentialSet()
+	b := NewSequentialSet()
+
+	require.True(t, a.Insert(1))
+	require.True(t, b.Insert(2))
+
+	s, err := Union(a, b, NewLazySyncSet, WithHooks(Hooks{OnInsert: func(value int) { inserted = append(inserted, value) }}))
+	require.NoError(t, err)
+	require.True(t, s.Contains(1))
+	require.True(t, s.Contains(2))
+	// the values are appended in ascending order
+	require.Equal(t, []int{1, 2}, inserted)
+
+	// the sets of other packages get the values by Insert
+	s, err = Union(a, b, func(...Option) Set { return mapSet{} })
+	require.NoError(t, err)
+	require.Equal(t, mapSet{1: true, 2: true}, s)
+}
+
+func TestAlgebraNotIterable(t *testing.T) {
+	a := NewLazySyncSet()
+	b := mapSet{}
+
+	_, err := Union(a, b, NewLazySyncSet)
+	require.ErrorIs(t, err, ErrNotIterable)
+
+	_, err = Intersection(b, a, NewLazySyncSet)
+	require.ErrorIs(t, err, ErrNotIterable)
+
+	_, err = Subset(a, b)
+	require.ErrorIs(t, err, ErrNotIterable)
+}
@@ -3,9 +3,13 @@ package set
 import (
 	"fmt"
 	"math/rand"
+	"runtime"
 	"sync"
 	"testing"
 	"time"
+
+	"github.com/vitalyisaev2/linked_list_set/internal/histogram"
+	"github.com/vitalyisaev2/linked_list_set/internal/workload"
 )
 
 func BenchmarkSet(b *testing.B) {
@@ -55,6 +59,557 @@ func BenchmarkSet(b *testing.B) {
 	}
 }
 
+// BenchmarkContentionManager shows how contention policies change throughput of the implementations with retry loops.
+func BenchmarkContentionManager(b *testing.B) {
+	rand.Seed(time.Now().Unix())
+
+	kinds := []setKind{
+		optimistic,
+		lazy,
+		nonBlocking,
+	}
+
+	policies := []struct {
+		name string
+		cm   ContentionManager
+	}{
+		{name: "none", cm: NewNoContentionManager()},
+		{name: "exponential_backoff", cm: NewExponentialBackoff(time.Microsecond, time.Millisecond)},
+		{name: "yield", cm: NewYieldContentionManager()},
+	}
+
+	const inputLength = 2 << 9
+
+	ds := &dataSource{name: "shuffled_array", data: makeShuffledArray(inputLength)}
+
+	threadNumbers := []int{8, 32, 128}
+
+	for _, threadNumber := range threadNumbers {
+		threadNumber := threadNumber
+
+		b.Run(fmt.Sprintf("%v_threads", threadNumber), func(b *testing.B) {
+			for _, kind := range kinds {
+				kind := kind
+
+				b.Run(kind.String(), func(b *testing.B) {
+					for _, policy := range policies {
+						policy := policy
+
+						b.Run(policy.name, func(b *testing.B) {
+							params := &benchParams{
+								kind:       kind,
+								threads:    threadNumber,
+								dataSource: ds,
+								opts:       []Option{WithContentionManager(policy.cm)},
+							}
+
+							start := time.Now()
+							benchInsertAndRemove(b, params)
+							reportThroughput(b, params, time.Since(start))
+						})
+					}
+				})
+			}
+		})
+	}
+}
+
+// BenchmarkNodeLayout measures the scenarios sensitive to false sharing on the current node layout.
+// Run it with and without `-tags padded` to compare padded and unpadded nodes (see `make bench-padding`).
+func BenchmarkNodeLayout(b *testing.B) {
+	rand.Seed(time.Now().Unix())
+
+	kinds := []setKind{
+		fineGrained,
+		optimistic,
+		lazy,
+		nonBlocking,
+	}
+
+	const inputLength = 2 << 9
+
+	ds := &dataSource{name: "shuffled_array", data: makeShuffledArray(inputLength)}
+
+	// the number of cores of the reference machine and twice as much
+	threadNumbers := []int{16, 32}
+
+	b.Run(nodeLayout, func(b *testing.B) {
+		for _, threadNumber := range threadNumbers {
+			threadNumber := threadNumber
+
+			b.Run(fmt.Sprintf("%v_threads", threadNumber), func(b *testing.B) {
+				for _, kind := range kinds {
+					kind := kind
+
+					b.Run(kind.String(), func(b *testing.B) {
+						params := &benchParams{kind: kind, threads: threadNumber, dataSource: ds}
+
+						b.Run("contains", func(b *testing.B) { benchContains(b, params) })
+						b.Run("insert_and_contains", func(b *testing.B) { benchInsertAndContains(b, params) })
+						b.Run("insert_and_remove", func(b *testing.B) { benchInsertAndRemove(b, params) })
+					})
+				}
+			})
+		}
+	})
+}
+
+// BenchmarkNodeRecycling compares allocations and GC cycles with and without node recycling.
+func BenchmarkNodeRecycling(b *testing.B) {
+	rand.Seed(time.Now().Unix())
+
+	kinds := []setKind{
+		lazy,
+		nonBlocking,
+	}
+
+	modes := []struct {
+		name string
+		opts []Option
+	}{
+		{name: "gc"},
+		{name: "recycling", opts: []Option{WithNodeRecycling()}},
+	}
+
+	const (
+		inputLength = 2 << 9
+		threads     = 8
+	)
+
+	ds := &dataSource{name: "shuffled_array", data: makeShuffledArray(inputLength)}
+
+	for _, kind := range kinds {
+		kind := kind
+
+		b.Run(kind.String(), func(b *testing.B) {
+			for _, mode := range modes {
+				mode := mode
+
+				b.Run(mode.name, func(b *testing.B) {
+					params := &benchParams{kind: kind, threads: threads, dataSource: ds, opts: mode.opts}
+
+					var before, after runtime.MemStats
+
+					b.ReportAllocs()
+					runtime.ReadMemStats(&before)
+					benchChurn(b, params)
+					runtime.ReadMemStats(&after)
+
+					b.ReportMetric(float64(after.NumGC-before.NumGC), "gc-cycles")
+				})
+			}
+		})
+	}
+}
+
+// BenchmarkBulk compares the bulk operations with the loops applying the same values one by one.
+// Every thread owns its own values, and an iteration applies all of them.
+func BenchmarkBulk(b *testing.B) {
+	kinds := []setKind{
+		coarseGrained,
+		fineGrained,
+		lazy,
+		nonBlocking,
+	}
+
+	modes := []struct {
+		name  string
+		apply func(s Set, op Operation, values []int)
+	}{
+		{
+			name: "loop",
+			apply: func(s Set, op Operation, values []int) {
+				for _, value := range values {
+					apply(s, op, value)
+				}
+			},
+		},
+		{
+			name:  "batch",
+			apply: func(s Set, op Operation, values []int) { applyAll(s, op, values) },
+		},
+	}
+
+	const inputLength = 2 << 9
+
+	for _, threadNumber := range []int{1, 8} {
+		threadNumber := threadNumber
+
+		b.Run(fmt.Sprintf("%v_threads", threadNumber), func(b *testing.B) {
+			for _, kind := range kinds {
+				kind := kind
+
+				b.Run(kind.String(), func(b *testing.B) {
+					for _, mode := range modes {
+						mode := mode
+
+						b.Run("contains/"+mode.name, func(b *testing.B) {
+							benchBulk(b, kind, threadNumber, inputLength, func(s Set, values []int) {
+								mode.apply(s, OperationContains, values)
+							})
+						})
+						b.Run("insert_and_remove/"+mode.name, func(b *testing.B) {
+							benchBulk(b, kind, threadNumber, inputLength, func(s Set, values []int) {
+								mode.apply(s, OperationInsert, values)
+								mode.apply(s, OperationRemove, values)
+							})
+						})
+					}
+				})
+			}
+		})
+	}
+}
+
+// benchBulk fills the set with the shuffled values of all threads and runs the iterations concurrently.
+func benchBulk(b *testing.B, kind setKind, threads, length int, iteration func(s Set, values []int)) {
+	b.Helper()
+
+	f := factory{}
+	set := f.new(kind)
+
+	inputs := make([][]int, threads)
+
+	for i := range inputs {
+		for _, value := range makeShuffledArray(length / threads) {
+			inputs[i] = append(inputs[i], value*threads+i)
+		}
+
+		InsertAll(set, inputs[i])
+	}
+
+	wg := sync.WaitGroup{}
+	wg.Add(threads)
+
+	b.ResetTimer()
+
+	for i := 0; i < threads; i++ {
+		values := inputs[i]
+
+		go func() {
+			defer wg.Done()
+
+			for j := 0; j < b.N; j++ {
+				iteration(set, values)
+			}
+		}()
+	}
+
+	wg.Wait()
+}
+
+// BenchmarkTransactions compares multi-key transactions of STM set with coarse-grained set
+// running the same transactions under its lock, the trivially transactional baseline.
+func BenchmarkTransactions(b *testing.B) {
+	const keySpace = 1024
+
+	transactions := []struct {
+		name string
+		run  func(tx Tx, rnd *rand.Rand) error
+	}{
+		{
+			// move key A to B
+			name: "move",
+			run: func(tx Tx, rnd *rand.Rand) error {
+				from, to := rnd.Intn(keySpace), rnd.Intn(keySpace)
+				if tx.Remove(from) {
+					tx.Insert(to)
+				}
+
+				return nil
+			},
+		},
+		{
+			// insert X only if Y is absent
+			name: "insert_if_absent",
+			run: func(tx Tx, rnd *rand.Rand) error {
+				x, y := rnd.Intn(keySpace), rnd.Intn(keySpace)
+				if !tx.Contains(y) {
+					tx.Insert(x)
+				} else {
+					tx.Remove(x)
+				}
+
+				return nil
+			},
+		},
+	}
+
+	kinds := []setKind{
+		coarseGrained,
+		stm,
+	}
+
+	for _, threadNumber := range []int{1, 4, 16} {
+		threadNumber := threadNumber
+
+		b.Run(fmt.Sprintf("%v_threads", threadNumber), func(b *testing.B) {
+			for _, transaction := range transactions {
+				transaction := transaction
+
+				b.Run(transaction.name, func(b *testing.B) {
+					for _, kind := range kinds {
+						kind := kind
+
+						b.Run(kind.String(), func(b *testing.B) {
+							benchTransactions(b, kind, threadNumber, keySpace, transaction.run)
+						})
+					}
+				})
+			}
+		})
+	}
+}
+
+func benchTransactions(b *testing.B, kind setKind, threads, keySpace int, run func(tx Tx, rnd *rand.Rand) error) {
+	b.Helper()
+
+	f := factory{}
+	set := f.new(kind)
+
+	for i := 0; i < keySpace; i += 2 {
+		set.Insert(i)
+	}
+
+	atomically := func(f func(tx Tx) error) error {
+		if s, ok := set.(TransactionalSet); ok {
+			return s.Atomically(f)
+		}
+
+		// the baseline doesn't roll back, but the benchmarked transactions never fail
+		c, _ := set.(*coarseGrainedSyncSet)
+		c.mutex.Lock()
+		defer c.mutex.Unlock()
+
+		return f(c.sequentialSet)
+	}
+
+	wg := sync.WaitGroup{}
+	wg.Add(threads)
+
+	b.ResetTimer()
+
+	for i := 0; i < threads; i++ {
+		rnd := rand.New(rand.NewSource(int64(i))) //nolint:gosec // reproducible benchmark data
+
+		go func() {
+			defer wg.Done()
+
+			for j := 0; j < b.N; j++ {
+				_ = atomically(func(tx Tx) error { return run(tx, rnd) })
+			}
+		}()
+	}
+
+	wg.Wait()
+
+	reportStats(b, set)
+}
+
+// BenchmarkWorkload runs YCSB-style workloads: a mix of operations over a partially filled key space
+// with different key distributions.
+func BenchmarkWorkload(b *testing.B) {
+	kinds := []setKind{
+		coarseGrained,
+		fineGrained,
+		optimistic,
+		lazy,
+		nonBlocking,
+	}
+
+	var configs []workload.Config
+
+	for _, mix := range []string{"read_mostly", "balanced", "write_heavy"} {
+		for _, d := range []workload.Distribution{workload.Uniform, workload.Zipfian, workload.Hotspot, workload.Sequential} {
+			configs = append(configs, workload.Config{
+				Mix:          workload.Presets[mix],
+				KeySpace:     2 << 9,
+				InitialFill:  0.5,
+				Distribution: d,
+				Seed:         time.Now().UnixNano(),
+			})
+		}
+	}
+
+	threadNumbers := []int{8, 32}
+
+	for _, threadNumber := range threadNumbers {
+		threadNumber := threadNumber
+
+		b.Run(fmt.Sprintf("%v_threads", threadNumber), func(b *testing.B) {
+			for _, config := range configs {
+				w, err := workload.New(config)
+				if err != nil {
+					b.Fatal(err)
+				}
+
+				b.Run(config.Name(), func(b *testing.B) {
+					for _, kind := range kinds {
+						kind := kind
+
+						b.Run(kind.String(), func(b *testing.B) {
+							params := &benchParams{kind: kind, threads: threadNumber}
+
+							benchWorkload(b, params, w)
+						})
+					}
+				})
+			}
+		})
+	}
+}
+
+// benchWorkload fills the set and makes every thread execute b.N operations of the workload.
+// Latencies are recorded into per-thread histograms and reported as percentiles of every kind of operation.
+func benchWorkload(b *testing.B, params *benchParams, w *workload.Workload) {
+	b.Helper()
+
+	f := factory{}
+	set := f.new(params.kind, params.opts...)
+
+	config := w.Config()
+	config.Fill(set)
+
+	generators := make([]*workload.Generator, params.threads)
+	latencies := newLatencies(params.threads)
+
+	for i := range generators {
+		generators[i] = w.Generator(i)
+	}
+
+	wg := sync.WaitGroup{}
+	wg.Add(params.threads)
+
+	b.ResetTimer()
+
+	start := time.Now()
+
+	for i := 0; i < params.threads; i++ {
+		g, h := generators[i], latencies[i]
+
+		go func() {
+			defer wg.Done()
+
+			for j := 0; j < b.N; j++ {
+				op, key := g.Next()
+
+				start := time.Now()
+				workload.Apply(set, op, key)
+				h[op].Record(time.Since(start))
+			}
+		}()
+	}
+	wg.Wait()
+
+	b.StopTimer()
+	reportThroughput(b, params, time.Since(start))
+	reportLatencies(b, latencies)
+	reportStats(b, set)
+}
+
+// newLatencies allocates a histogram for every kind of operation of every thread.
+func newLatencies(threads int) [][]*histogram.Histogram {
+	latencies := make([][]*histogram.Histogram, threads)
+
+	for i := range latencies {
+		for range workload.Ops() {
+			latencies[i] = append(latencies[i], histogram.New())
+		}
+	}
+
+	return latencies
+}
+
+// reportLatencies merges per-thread histograms and adds percentiles to the benchmark output,
+// e.g. "contains-p99-ns".
+func reportLatencies(b *testing.B, latencies [][]*histogram.Histogram) {
+	b.Helper()
+
+	for _, op := range workload.Ops() {
+		merged := histogram.New()
+		for _, h := range latencies {
+			merged.Merge(h[op])
+		}
+
+		if merged.Count() == 0 {
+			continue
+		}
+
+		b.ReportMetric(float64(merged.ValueAtQuantile(0.5)), op.String()+"-p50-ns")
+		b.ReportMetric(float64(merged.ValueAtQuantile(0.99)), op.String()+"-p99-ns")
+		b.ReportMetric(float64(merged.ValueAtQuantile(0.999)), op.String()+"-p999-ns")
+		b.ReportMetric(float64(merged.Max()), op.String()+"-max-ns")
+	}
+}
+
+// benchChurn makes every thread insert and immediately remove its own keys,
+// so that every operation either allocates or releases a node.
+func benchChurn(b *testing.B, params *benchParams) {
+	b.Helper()
+
+	f := factory{}
+	set := f.new(params.kind, params.opts...)
+
+	wg := sync.WaitGroup{}
+	wg.Add(params.threads)
+
+	b.ResetTimer()
+
+	for i := 0; i < params.threads; i++ {
+		i := i
+
+		go func() {
+			defer wg.Done()
+
+			for j := 0; j < b.N; j++ {
+				ix := (j*params.threads + i) % len(params.dataSource.data)
+				val := params.dataSource.data[ix]
+				set.Insert(val)
+				set.Remove(val)
+			}
+		}()
+	}
+	wg.Wait()
+
+	reportStats(b, set)
+}
+
+// reportStats adds the counters of the set per completed operation to the benchmark output
+// when the package is built with `-tags stats`.
+func reportStats(b *testing.B, set Set) {
+	b.Helper()
+
+	provider, ok := set.(StatsProvider)
+	if !StatsEnabled || !ok {
+		return
+	}
+
+	stats := provider.Stats()
+	if stats.Operations == 0 {
+		return
+	}
+
+	perOperation := func(v int64) float64 { return float64(v) / float64(stats.Operations) }
+
+	b.ReportMetric(stats.TraversedPerOperation(), "nodes/call")
+	b.ReportMetric(perOperation(stats.ValidationFailures), "validation-failures/call")
+	b.ReportMetric(perOperation(stats.CASFailures), "cas-failures/call")
+	b.ReportMetric(perOperation(stats.Restarts), "restarts/call")
+	b.ReportMetric(perOperation(stats.Snips), "snips/call")
+}
+
+// reportThroughput adds total number of operations per second performed by all threads to the benchmark output.
+func reportThroughput(b *testing.B, params *benchParams, elapsed time.Duration) {
+	b.Helper()
+
+	if elapsed <= 0 {
+		return
+	}
+
+	ops := float64(b.N) * float64(params.threads)
+	b.ReportMetric(ops/elapsed.Seconds(), "ops/s")
+}
+
 type dataSource struct {
 	name string
 	data []int
@@ -80,6 +635,7 @@ func makeShuffledArray(length int) []int {
 
 type benchParams struct {
 	dataSource *dataSource
+	opts       []Option
 	threads    int
 	kind       setKind
 }
@@ -88,7 +644,9 @@ func benchInsert(b *testing.B, params *benchParams) {
 	b.Helper()
 
 	f := factory{}
-	set := f.new(params.kind)
+	set := f.new(params.kind, params.opts...)
+
+	latencies := newLatencies(params.threads)
 
 	wg := sync.WaitGroup{}
 	wg.Add(params.threads)
@@ -97,30 +655,39 @@ func benchInsert(b *testing.B, params *benchParams) {
 
 	// all threads are inserting
 	for i := 0; i < params.threads; i++ {
+		h := latencies[i]
+
 		go func() {
 			defer wg.Done()
 
 			for j := 0; j < b.N; j++ {
 				ix := j % len(params.dataSource.data)
 				val := params.dataSource.data[ix]
+				start := time.Now()
 				set.Insert(val)
+				h[workload.Insert].Record(time.Since(start))
 			}
 		}()
 	}
 	wg.Wait()
+
+	reportLatencies(b, latencies)
+	reportStats(b, set)
 }
 
 func benchContains(b *testing.B, params *benchParams) {
 	b.Helper()
 
 	f := factory{}
-	set := f.new(params.kind)
+	set := f.new(params.kind, params.opts...)
 
 	// fill the set
 	for _, value := range params.dataSource.data {
 		set.Insert(value)
 	}
 
+	latencies := newLatencies(params.threads)
+
 	wg := sync.WaitGroup{}
 	wg.Add(params.threads)
 
@@ -128,13 +695,17 @@ func benchContains(b *testing.B, params *benchParams) {
 
 	// all threads are seeking for the value
 	for i := 0; i < params.threads; i++ {
+		h := latencies[i]
+
 		go func() {
 			defer wg.Done()
 
 			for j := 0; j < b.N; j++ {
 				ix := j % len(params.dataSource.data)
 				val := params.dataSource.data[ix]
+				start := time.Now()
 				ok := set.Contains(val)
+				h[workload.Contains].Record(time.Since(start))
 
 				if !ok {
 					panic("invariant violation")
@@ -143,13 +714,18 @@ func benchContains(b *testing.B, params *benchParams) {
 		}()
 	}
 	wg.Wait()
+
+	reportLatencies(b, latencies)
+	reportStats(b, set)
 }
 
 func benchInsertAndContains(b *testing.B, params *benchParams) {
 	b.Helper()
 
 	f := factory{}
-	set := f.new(params.kind)
+	set := f.new(params.kind, params.opts...)
+
+	latencies := newLatencies(params.threads)
 
 	wg := sync.WaitGroup{}
 	wg.Add(params.threads)
@@ -158,19 +734,25 @@ func benchInsertAndContains(b *testing.B, params *benchParams) {
 
 	// half threads are inserting
 	for i := 0; i < params.threads/2; i++ {
+		h := latencies[i]
+
 		go func() {
 			defer wg.Done()
 
 			for j := 0; j < b.N; j++ {
 				ix := j % len(params.dataSource.data)
 				val := params.dataSource.data[ix]
+				start := time.Now()
 				set.Insert(val)
+				h[workload.Insert].Record(time.Since(start))
 			}
 		}()
 	}
 
 	// another half is seeking for values
 	for i := 0; i < params.threads/2; i++ {
+		h := latencies[params.threads/2+i]
+
 		go func() {
 			defer wg.Done()
 
@@ -178,19 +760,26 @@ func benchInsertAndContains(b *testing.B, params *benchParams) {
 				ix := j % len(params.dataSource.data)
 				val := params.dataSource.data[ix]
 				// don't check the result cause set still can be empty
+				start := time.Now()
 				set.Contains(val)
+				h[workload.Contains].Record(time.Since(start))
 			}
 		}()
 	}
 
 	wg.Wait()
+
+	reportLatencies(b, latencies)
+	reportStats(b, set)
 }
 
 func benchInsertAndRemove(b *testing.B, params *benchParams) {
 	b.Helper()
 
 	f := factory{}
-	set := f.new(params.kind)
+	set := f.new(params.kind, params.opts...)
+
+	latencies := newLatencies(params.threads)
 
 	wg := sync.WaitGroup{}
 	wg.Add(params.threads)
@@ -199,19 +788,25 @@ func benchInsertAndRemove(b *testing.B, params *benchParams) {
 
 	// half threads are inserting
 	for i := 0; i < params.threads/2; i++ {
+		h := latencies[i]
+
 		go func() {
 			defer wg.Done()
 
 			for j := 0; j < b.N; j++ {
 				ix := j % len(params.dataSource.data)
 				val := params.dataSource.data[ix]
+				start := time.Now()
 				set.Insert(val)
+				h[workload.Insert].Record(time.Since(start))
 			}
 		}()
 	}
 
 	// another half is seeking for values
 	for i := 0; i < params.threads/2; i++ {
+		h := latencies[params.threads/2+i]
+
 		go func() {
 			defer wg.Done()
 
@@ -219,10 +814,15 @@ func benchInsertAndRemove(b *testing.B, params *benchParams) {
 				ix := j % len(params.dataSource.data)
 				val := params.dataSource.data[ix]
 				// don't check the result cause set still can be empty or value could be removed by other thread
+				start := time.Now()
 				set.Remove(val)
+				h[workload.Remove].Record(time.Since(start))
 			}
 		}()
 	}
 
 	wg.Wait()
+
+	reportLatencies(b, latencies)
+	reportStats(b, set)
 }
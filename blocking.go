@@ -0,0 +1,156 @@
+package set
+
+import (
+	"context"
+	"sync"
+)
+
+// BlockingSet adds blocking operations to a set, so that it can coordinate goroutines.
+// All blocking operations return the error of ctx if it is done before their condition is met.
+type BlockingSet interface {
+	Set
+	// WaitContains blocks until the value is present. It guarantees only that the value has been present
+	// at some moment during the call: it may be removed again by the time WaitContains returns.
+	WaitContains(ctx context.Context, value int) error
+	// WaitAbsent blocks until the value is absent, with the same guarantee as WaitContains.
+	WaitAbsent(ctx context.Context, value int) error
+	// TakeMin blocks until the set is non-empty and atomically removes the minimal value.
+	TakeMin(ctx context.Context) (int, error)
+}
+
+var _ BlockingSet = (*blockingSet)(nil)
+
+// takersKey is the only key of the waiters of TakeMin: every insertion wakes up one of them.
+const takersKey = 0
+
+// blockingSet wakes up the waiters after the successful updates of the inner set. The updates run concurrently
+// holding the lock for reading, while TakeMin holds it for writing: no update can run between finding the minimum
+// and removing it.
+type blockingSet struct {
+	set      Set
+	iterable Iterable
+	mutex    sync.RWMutex
+	inserted waiters
+	removed  waiters
+	takers   waiters
+}
+
+func (s *blockingSet) Insert(value int) bool {
+	s.mutex.RLock()
+	defer s.mutex.RUnlock()
+
+	if !s.set.Insert(value) {
+		return false
+	}
+
+	s.inserted.wake(value)
+	s.takers.wakeOne(takersKey)
+
+	return true
+}
+
+func (s *blockingSet) Contains(value int) bool {
+	return s.set.Contains(value)
+}
+
+func (s *blockingSet) Remove(value int) bool {
+	s.mutex.RLock()
+	defer s.mutex.RUnlock()
+
+	if !s.set.Remove(value) {
+		return false
+	}
+
+	s.removed.wake(value)
+
+	return true
+}
+
+func (s *blockingSet) WaitContains(ctx context.Context, value int) error {
+	return s.inserted.wait(ctx, value, func() bool { return s.set.Contains(value) })
+}
+
+func (s *blockingSet) WaitAbsent(ctx context.Context, value int) error {
+	return s.removed.wait(ctx, value, func() bool { return !s.set.Contains(value) })
+}
+
+func (s *blockingSet) TakeMin(ctx context.Context) (int, error) {
+	// the taker may have been woken up by an insertion and leave without taking its value
+	// (another taker has won it, or ctx is done), so the wakeup is passed on while the set is not empty
+	defer s.wakeTaker()
+
+	for {
+		var (
+			value int
+			taken bool
+		)
+
+		// another taker may win the value inserted meanwhile, so a woken taker tries again
+		err := s.takers.wait(ctx, takersKey, func() bool {
+			value, taken = s.takeMin()
+
+			return taken
+		})
+		if err != nil {
+			return 0, err
+		}
+
+		if taken {
+			return value, nil
+		}
+	}
+}
+
+func (s *blockingSet) wakeTaker() {
+	if s.takers.len() == 0 {
+		return
+	}
+
+	empty := true
+
+	s.iterable.Ascend(func(int) bool {
+		empty = false
+
+		return false
+	})
+
+	if !empty {
+		s.takers.wakeOne(takersKey)
+	}
+}
+
+func (s *blockingSet) takeMin() (int, bool) {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+
+	var (
+		value int
+		found bool
+	)
+
+	// without concurrent updates even a weakly consistent walk finds the minimum
+	s.iterable.Ascend(func(v int) bool {
+		value, found = v, true
+
+		return false
+	})
+
+	if !found || !s.set.Remove(value) {
+		return 0, false
+	}
+
+	s.removed.wake(value)
+
+	return value, true
+}
+
+// NewBlockingSet wraps the set to provide blocking operations. The set must implement Iterable,
+// otherwise ErrNotIterable is returned. All updates of the set must go through the wrapper.
+func NewBlockingSet(s Set) (BlockingSet, error) {
+	iterable, ok := s.(Iterable)
+	if !ok {
+		return nil, ErrNotIterable
+	}
+
+	return &blockingSet{set: s, iterable: iterable}, nil
+}
@@ -0,0 +1,302 @@
+package set
+
+import (
+	"context"
+	"sort"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestBlockingSet(t *testing.T) {
+	set, err := NewBlockingSet(NewLazySyncSet())
+	require.NoError(t, err)
+
+	background := context.Background()
+
+	expired, cancel := context.WithTimeout(background, 10*time.Millisecond)
+	defer cancel()
+
+	t.Run("wait contains", func(t *testing.T) {
+		require.True(t, set.Insert(1))
+		require.NoError(t, set.WaitContains(background, 1))
+		require.ErrorIs(t, set.WaitContains(expired, 2), context.DeadlineExceeded)
+
+		errs := make(chan error)
+
+		go func() { errs <- set.WaitContains(background, 2) }()
+
+		require.Eventually(t, func() bool { return blockingWaiters(set) == 1 }, 10*time.Second, time.Millisecond)
+		require.True(t, set.Insert(2))
+		require.NoError(t, <-errs)
+	})
+
+	t.Run("wait absent", func(t *testing.T) {
+		require.NoError(t, set.WaitAbsent(background, 3))
+		require.ErrorIs(t, set.WaitAbsent(expired, 2), context.DeadlineExceeded)
+
+		errs := make(chan error)
+
+		go func() { errs <- set.WaitAbsent(background, 2) }()
+
+		require.Eventually(t, func() bool { return blockingWaiters(set) == 1 }, 10*time.Second, time.Millisecond)
+		require.True(t, set.Remove(2))
+		require.NoError(t, <-errs)
+	})
+
+	t.Run("take min", func(t *testing.T) {
+		require.True(t, set.Insert(0))
+
+		value, err := set.TakeMin(background)
+		require.NoError(t, err)
+		require.Equal(t, 0, value)
+
+		value, err = set.TakeMin(background)
+		require.NoError(t, err)
+		require.Equal(t, 1, value)
+
+		_, err = set.TakeMin(expired)
+		require.ErrorIs(t, err, context.DeadlineExceeded)
+
+		results := make(chan takeResult)
+
+		go takeMin(background, set, results)
+
+		require.Eventually(t, func() bool { return blockingWaiters(set) == 1 }, 10*time.Second, time.Millisecond)
+		require.True(t, set.Insert(5))
+
+		r := <-results
+		require.NoError(t, r.err)
+		require.Equal(t, 5, r.value)
+		require.False(t, set.Contains(5))
+	})
+
+	require.Zero(t, blockingWaiters(set))
+}
+
+// blockingWaiters returns the number of goroutines waiting in the blocking operations.
+func blockingWaiters(s BlockingSet) int {
+	set, ok := s.(*blockingSet)
+	if !ok {
+		return 0
+	}
+
+	return set.inserted.len() + set.removed.len() + set.takers.len()
+}
+
+// takeResult is the outcome of TakeMin called in another goroutine; the test goroutine asserts it.
+type takeResult struct {
+	value int
+	err   error
+}
+
+func takeMin(ctx context.Context, set BlockingSet, results chan<- takeResult) {
+	value, err := set.TakeMin(ctx)
+	results <- takeResult{value: value, err: err}
+}
+
+// receiveTaken returns the value of the next successful TakeMin.
+func receiveTaken(t *testing.T, results <-chan takeResult) int {
+	t.Helper()
+
+	r := <-results
+	require.NoError(t, r.err)
+
+	return r.value
+}
+
+func TestTakeMinWakesOne(t *testing.T) {
+	set, err := NewBlockingSet(NewLazySyncSet())
+	require.NoError(t, err)
+
+	blocking, ok := set.(*blockingSet)
+	require.True(t, ok)
+
+	const takers = 3
+
+	results := make(chan takeResult, takers)
+
+	for i := 0; i < takers; i++ {
+		go takeMin(context.Background(), set, results)
+	}
+
+	require.Eventually(t, func() bool { return blocking.takers.len() == takers }, 10*time.Second, time.Millisecond)
+
+	blocking.takers.mutex.Lock()
+	registered := append([]chan struct{}(nil), blocking.takers.channels[takersKey]...)
+	blocking.takers.mutex.Unlock()
+
+	// the insertion closes the channel of a single taker
+	require.True(t, set.Insert(1))
+
+	woken := 0
+
+	for _, ch := range registered {
+		select {
+		case <-ch:
+			woken++
+		default:
+		}
+	}
+
+	require.Equal(t, 1, woken)
+
+	require.Equal(t, 1, receiveTaken(t, results))
+
+	require.True(t, set.Insert(2))
+	require.True(t, set.Insert(3))
+
+	taken := []int{receiveTaken(t, results), receiveTaken(t, results)}
+	sort.Ints(taken)
+	require.Equal(t, []int{2, 3}, taken)
+	require.Zero(t, blockingWaiters(set))
+}
+
+// TestTakeMinCanceled cancels a taker concurrently with the insertion waking it up:
+// if the canceled taker leaves without the value, the other taker must take it.
+func TestTakeMinCanceled(t *testing.T) {
+	set, err := NewBlockingSet(NewLazySyncSet())
+	require.NoError(t, err)
+
+	for value := 0; value < 200; value++ {
+		ctx, cancel := context.WithCancel(context.Background())
+		canceled, other := make(chan takeResult, 1), make(chan takeResult, 1)
+
+		go takeMin(ctx, set, canceled)
+		require.Eventually(t, func() bool { return blockingWaiters(set) == 1 }, 10*time.Second, time.Millisecond)
+
+		go takeMin(context.Background(), set, other)
+		require.Eventually(t, func() bool { return blockingWaiters(set) == 2 }, 10*time.Second, time.Millisecond)
+
+		// the canceled taker is likely to be woken up before it runs, then it picks either of the cases
+		cancel()
+		require.True(t, set.Insert(value))
+
+		if r := <-canceled; r.err == nil {
+			require.Equal(t, value, r.value)
+			require.True(t, set.Insert(value))
+		}
+
+		select {
+		case r := <-other:
+			require.NoError(t, r.err)
+			require.Equal(t, value, r.value)
+		case <-time.After(10 * time.Second):
+			t.Fatal("the wakeup of the canceled taker is lost")
+		}
+	}
+
+	require.Zero(t, blockingWaiters(set))
+}
+
+func TestBlockingSetNotIterable(t *testing.T) {
+	_, err := NewBlockingSet(mapSet{})
+	require.ErrorIs(t, err, ErrNotIterable)
+}
+
+// TestTakeMinConcurrent runs producers and consumers over the sets of various implementations:
+// every produced value must be taken exactly once, and without producers every consumer must take ascending values.
+func TestTakeMinConcurrent(t *testing.T) {
+	f := factory{}
+
+	kinds := []setKind{
+		coarseGrained,
+		fineGrained,
+		optimistic,
+		lazy,
+		nonBlocking,
+		hazardNonBlocking,
+		stm,
+		mvcc,
+	}
+
+	const (
+		threads = 4
+		items   = 200
+	)
+
+	for _, k := range kinds {
+		k := k
+
+		t.Run(k.String(), func(t *testing.T) {
+			set, err := NewBlockingSet(f.new(k))
+			require.NoError(t, err)
+
+			ctx, cancel := context.WithTimeout(context.Background(), 30*time.Second)
+			defer cancel()
+
+			take := func(n int) [][]int {
+				taken := make([][]int, threads)
+
+				var wg sync.WaitGroup
+
+				wg.Add(threads)
+
+				for i := 0; i < threads; i++ {
+					i := i
+
+					go func() {
+						defer wg.Done()
+
+						for j := 0; j < n; j++ {
+							value, err := set.TakeMin(ctx)
+							if err != nil {
+								return
+							}
+
+							taken[i] = append(taken[i], value)
+						}
+					}()
+				}
+
+				wg.Wait()
+
+				return taken
+			}
+
+			// the consumers block until the producers insert the values
+			var producers sync.WaitGroup
+
+			producers.Add(threads)
+
+			for i := 0; i < threads; i++ {
+				i := i
+
+				go func() {
+					defer producers.Done()
+
+					for value := i; value < items; value += threads {
+						set.Insert(value)
+					}
+				}()
+			}
+
+			var all []int
+
+			for _, values := range take(items / threads) {
+				all = append(all, values...)
+			}
+
+			producers.Wait()
+			sort.Ints(all)
+			require.Equal(t, makeAscendingArray(items), all)
+
+			// without concurrent insertions the minimum grows
+			for value := 0; value < items; value++ {
+				require.True(t, set.Insert(value))
+			}
+
+			for _, values := range take(items / threads) {
+				require.True(t, sort.IntsAreSorted(values), values)
+			}
+
+			require.Zero(t, blockingWaiters(set))
+
+			for value := 0; value < items; value++ {
+				require.False(t, set.Contains(value), value)
+			}
+		})
+	}
+}
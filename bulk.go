@@ -0,0 +1,118 @@
+package set
+
+import (
+	"sort"
+)
+
+// The bulk operations apply an operation to a batch of values. The values are sorted, and the sets
+// supporting batches apply them in a single pass over the list instead of a traversal from head per value:
+// coarse-grained set holds its lock once for the whole batch, fine-grained set sweeps the list hand-over-hand,
+// and non-blocking set resumes the search for the next value from the window of the previous one.
+// The other sets apply the values one by one in ascending order.
+//
+// The result of every value is reported at the position of the value in the input. Each value is a separate
+// linearizable operation, and the batch as a whole is atomic only for coarse-grained set. Duplicate values
+// are applied in the order of the input, e.g. only the first of them may be inserted.
+
+// InsertAll inserts the values and reports whether each of them was inserted.
+func InsertAll(s Set, values []int) []bool {
+	return applyAll(s, OperationInsert, values)
+}
+
+// ContainsAll reports whether each of the values is present.
+func ContainsAll(s Set, values []int) []bool {
+	return applyAll(s, OperationContains, values)
+}
+
+// RemoveAll removes the values and reports whether each of them was removed.
+func RemoveAll(s Set, values []int) []bool {
+	return applyAll(s, OperationRemove, values)
+}
+
+// batchKey is a value of a batch with its position in the input.
+type batchKey struct {
+	value int
+	index int
+}
+
+// batchKeys orders the keys by value and then by position, so duplicates keep the order of the input.
+type batchKeys []batchKey
+
+func (k batchKeys) Len() int { return len(k) }
+
+func (k batchKeys) Less(i, j int) bool {
+	if k[i].value != k[j].value {
+		return k[i].value < k[j].value
+	}
+
+	return k[i].index < k[j].index
+}
+
+func (k batchKeys) Swap(i, j int) { k[i], k[j] = k[j], k[i] }
+
+// batchSet is implemented by the sets applying a sorted batch in a single pass.
+// The keys are sorted by value; the result of a key must be stored to results[key.index].
+type batchSet interface {
+	applyBatch(op Operation, keys []batchKey, results []bool)
+}
+
+var (
+	_ batchSet = (*sequentialSet)(nil)
+	_ batchSet = (*coarseGrainedSyncSet)(nil)
+	_ batchSet = (*fineGrainedSyncSet)(nil)
+	_ batchSet = (*nonBlockingSet)(nil)
+	_ batchSet = (*tracedSet)(nil)
+)
+
+func applyAll(s Set, op Operation, values []int) []bool {
+	keys := make([]batchKey, len(values))
+	for i, value := range values {
+		keys[i] = batchKey{value: value, index: i}
+	}
+
+	sort.Sort(batchKeys(keys))
+
+	results := make([]bool, len(values))
+
+	// the reserved values end up at the ends of the sorted keys, the operations reject them one by one
+	first, last := 0, len(keys)
+
+	for first < last && reserved(keys[first].value) {
+		first++
+	}
+
+	for last > first && reserved(keys[last-1].value) {
+		last--
+	}
+
+	applyOneByOne(s, op, keys[:first], results)
+	applyOneByOne(s, op, keys[last:], results)
+	keys = keys[first:last]
+
+	if batch, ok := s.(batchSet); ok {
+		batch.applyBatch(op, keys, results)
+	} else {
+		applyOneByOne(s, op, keys, results)
+	}
+
+	return results
+}
+
+func applyOneByOne(s Set, op Operation, keys []batchKey, results []bool) {
+	for _, key := range keys {
+		results[key.index] = apply(s, op, key.value)
+	}
+}
+
+func apply(s Set, op Operation, value int) bool {
+	switch op {
+	case OperationInsert:
+		return s.Insert(value)
+	case OperationContains:
+		return s.Contains(value)
+	case OperationRemove:
+		return s.Remove(value)
+	default:
+		panic("unknown Operation")
+	}
+}
@@ -0,0 +1,229 @@
+package set
+
+import (
+	"math"
+	"math/rand"
+	"sync"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+// TestBulk compares the bulk operations with a map-based oracle applying the values one by one.
+func TestBulk(t *testing.T) {
+	f := factory{}
+
+	kinds := []setKind{
+		sequential,
+		coarseGrained,
+		fineGrained,
+		optimistic,
+		lazy,
+		nonBlocking,
+		hazardNonBlocking,
+		stm,
+		mvcc,
+	}
+
+	const (
+		rounds   = 50
+		keySpace = 64
+	)
+
+	for _, k := range kinds {
+		k := k
+
+		for _, traced := range []bool{false, true} {
+			var opts []Option
+
+			name := k.String()
+			if traced {
+				opts = append(opts, WithTracing())
+				name += "_traced"
+			}
+
+			t.Run(name, func(t *testing.T) {
+				rnd := rand.New(rand.NewSource(1)) //nolint:gosec // reproducible test data
+				set := f.new(k, opts...)
+				oracle := map[int]bool{}
+
+				for i := 0; i < rounds; i++ {
+					// the batches contain duplicates and are not sorted
+					values := make([]int, rnd.Intn(keySpace))
+					for j := range values {
+						values[j] = rnd.Intn(keySpace) - keySpace/2
+					}
+
+					op := Operation(rnd.Intn(3) + 1)
+
+					var results []bool
+
+					switch op {
+					case OperationInsert:
+						results = InsertAll(set, values)
+					case OperationContains:
+						results = ContainsAll(set, values)
+					case OperationRemove:
+						results = RemoveAll(set, values)
+					}
+
+					// values are applied in ascending order, duplicates in the order of the input,
+					// so the first occurrence of a value gets the result of its single application
+					seen := map[int]bool{}
+
+					for j, value := range values {
+						expected := false
+
+						switch {
+						case op == OperationContains:
+							expected = oracle[value]
+						case seen[value]:
+						case op == OperationInsert:
+							expected = !oracle[value]
+						case op == OperationRemove:
+							expected = oracle[value]
+						}
+
+						require.Equal(t, expected, results[j], "%v %v", op, value)
+
+						seen[value] = true
+					}
+
+					for value := range seen {
+						switch op {
+						case OperationInsert:
+							oracle[value] = true
+						case OperationRemove:
+							delete(oracle, value)
+						case OperationContains:
+						}
+					}
+
+					require.Equal(t, sortedKeys(oracle), ascend(t, set))
+
+					checker, ok := set.(invariantChecker)
+					require.True(t, ok)
+					require.NoError(t, checker.checkInvariants())
+				}
+			})
+		}
+	}
+}
+
+// TestBulkConcurrent runs batches of different threads over interleaved values.
+func TestBulkReserved(t *testing.T) {
+	f := factory{}
+
+	kinds := []setKind{
+		sequential,
+		coarseGrained,
+		fineGrained,
+		optimistic,
+		lazy,
+		nonBlocking,
+		hazardNonBlocking,
+		stm,
+		mvcc,
+	}
+
+	values := []int{math.MaxInt64, 1, math.MinInt64, -math.MaxInt64, 2}
+	expected := []bool{false, true, false, false, true}
+
+	for _, k := range kinds {
+		k := k
+
+		t.Run(k.String(), func(t *testing.T) {
+			set := f.new(k)
+
+			// the reserved values are never stored, neither by a batch
+			require.Equal(t, expected, InsertAll(set, values))
+			require.Equal(t, expected, ContainsAll(set, values))
+			require.Equal(t, expected, RemoveAll(set, values))
+
+			checker, ok := set.(invariantChecker)
+			require.True(t, ok)
+			require.NoError(t, checker.checkInvariants())
+		})
+	}
+}
+
+func TestBulkConcurrent(t *testing.T) {
+	f := factory{}
+
+	kinds := []setKind{
+		coarseGrained,
+		fineGrained,
+		optimistic,
+		lazy,
+		nonBlocking,
+		hazardNonBlocking,
+		stm,
+		mvcc,
+	}
+
+	const (
+		threads = 4
+		items   = 256
+		rounds  = 10
+	)
+
+	for _, k := range kinds {
+		k := k
+
+		t.Run(k.String(), func(t *testing.T) {
+			set := f.new(k, WithNodeRecycling())
+
+			wg := sync.WaitGroup{}
+			wg.Add(threads)
+
+			for i := 0; i < threads; i++ {
+				// every thread owns the values equal to i modulo threads
+				values := make([]int, 0, items)
+				for j := 0; j < items; j++ {
+					values = append(values, j*threads+i)
+				}
+
+				rand.Shuffle(len(values), func(i, j int) { values[i], values[j] = values[j], values[i] })
+
+				go func() {
+					defer wg.Done()
+
+					for r := 0; r < rounds; r++ {
+						for _, ok := range InsertAll(set, values) {
+							require.True(t, ok)
+						}
+
+						for _, ok := range ContainsAll(set, values) {
+							require.True(t, ok)
+						}
+
+						for _, ok := range RemoveAll(set, values) {
+							require.True(t, ok)
+						}
+					}
+				}()
+			}
+
+			wg.Wait()
+
+			require.Empty(t, ascend(t, set))
+
+			checker, ok := set.(invariantChecker)
+			require.True(t, ok)
+			require.NoError(t, checker.checkInvariants())
+		})
+	}
+}
+
+func TestBulkHooks(t *testing.T) {
+	recorder := newHookRecorder()
+	set := NewFineGrainedSyncSet(WithHooks(recorder.hooks()))
+
+	require.Equal(t, []bool{true, true, false}, InsertAll(set, []int{2, 1, 2}))
+	require.Equal(t, []bool{true, false}, RemoveAll(set, []int{1, 3}))
+
+	require.Equal(t, int64(2), recorder.inserted)
+	require.Equal(t, int64(1), recorder.removed)
+	require.Equal(t, [OperationRemove + 1]int64{0, 1, 0, 1}, recorder.failures)
+	require.Equal(t, int64(5), recorder.locks)
+}
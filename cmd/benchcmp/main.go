@@ -0,0 +1,145 @@
+// Command benchcmp compares two benchmark result files (`go test -bench` output or setbench JSON)
+// in the manner of benchstat: for every benchmark present in both files it prints the change of the mean
+// and the p-value of Mann-Whitney U test. Run the benchmarks with -count 5 or more to get significant results.
+// The exit code is 1 if any benchmark regressed significantly by more than the threshold.
+//
+// Example:
+//
+//	benchcmp -threshold 5 old.txt new.txt
+package main
+
+import (
+	"errors"
+	"flag"
+	"fmt"
+	"io"
+	"os"
+	"strconv"
+	"text/tabwriter"
+
+	"github.com/vitalyisaev2/linked_list_set/internal/report"
+)
+
+// exit codes
+const (
+	exitRegression = 1
+	exitError      = 2
+)
+
+func main() {
+	regressed, err := run(os.Args[1:], os.Stdout, os.Stderr)
+	if err != nil {
+		fmt.Fprintln(os.Stderr, "benchcmp:", err)
+		os.Exit(exitError)
+	}
+
+	if regressed {
+		os.Exit(exitRegression)
+	}
+}
+
+var (
+	errUsage     = errors.New("usage: benchcmp [flags] old new")
+	errNoCommon  = errors.New("no common benchmarks with the unit")
+	errThreshold = errors.New("threshold must be non-negative")
+)
+
+// run prints the comparison and reports whether any benchmark regressed.
+func run(args []string, stdout, stderr io.Writer) (bool, error) {
+	fs := flag.NewFlagSet("benchcmp", flag.ContinueOnError)
+	fs.SetOutput(stderr)
+
+	unit := fs.String("unit", report.DefaultUnit, "unit to compare, e.g. ns/op, ops/s or contains-p99-ns")
+	alpha := fs.Float64("alpha", 0.05, "significance level")
+	threshold := fs.Float64("threshold", 5, "regression threshold in percents")
+
+	if err := fs.Parse(args); err != nil {
+		return false, err
+	}
+
+	if fs.NArg() != 2 {
+		return false, errUsage
+	}
+
+	if *threshold < 0 {
+		return false, errThreshold
+	}
+
+	oldMs, err := report.Load(fs.Arg(0))
+	if err != nil {
+		return false, err
+	}
+
+	newMs, err := report.Load(fs.Arg(1))
+	if err != nil {
+		return false, err
+	}
+
+	deltas := report.Compare(oldMs, newMs, *unit, *alpha, *threshold/100)
+	if len(deltas) == 0 {
+		return false, errNoCommon
+	}
+
+	return printDeltas(stdout, deltas, *unit)
+}
+
+// printDeltas writes the table of deltas and reports whether any of them is a regression.
+func printDeltas(w io.Writer, deltas []*report.Delta, unit string) (bool, error) {
+	tw := tabwriter.NewWriter(w, 0, 0, 2, ' ', 0)
+
+	fmt.Fprintf(tw, "name\told %s\tnew %s\tdelta\tp\t\n", unit, unit)
+
+	var regressed bool
+
+	for _, d := range deltas {
+		delta := "~"
+		if d.Significant {
+			delta = strconv.FormatFloat(d.Change*100, 'f', 2, 64) + "%"
+			if d.Change > 0 {
+				delta = "+" + delta
+			}
+		}
+
+		verdict := ""
+		if d.Regression {
+			verdict = "REGRESSION"
+			regressed = true
+		}
+
+		fmt.Fprintf(tw, "%s\t%s\t%s\t%s\tp=%.3f n=%d+%d\t%s\n",
+			d.Name, summary(d.Old), summary(d.New), delta, d.P, len(d.Old), len(d.New), verdict)
+	}
+
+	return regressed, tw.Flush()
+}
+
+// summary formats the mean and the relative spread of the samples.
+func summary(samples []float64) string {
+	var sum, maxDev float64
+	for _, s := range samples {
+		sum += s
+	}
+
+	mean := sum / float64(len(samples))
+
+	for _, s := range samples {
+		if dev := abs(s - mean); dev > maxDev {
+			maxDev = dev
+		}
+	}
+
+	spread := 0.0
+	if mean != 0 {
+		spread = maxDev / abs(mean) * 100
+	}
+
+	return strconv.FormatFloat(mean, 'g', 4, 64) + " ±" + strconv.FormatFloat(spread, 'f', 0, 64) + "%"
+}
+
+func abs(v float64) float64 {
+	if v < 0 {
+		return -v
+	}
+
+	return v
+}
@@ -0,0 +1,72 @@
+package main
+
+import (
+	"bytes"
+	"errors"
+	"io"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+func writeResults(t *testing.T, name string, values map[string][]string) string {
+	t.Helper()
+
+	var buf strings.Builder
+
+	for bench, samples := range values {
+		for _, s := range samples {
+			buf.WriteString(bench + "-8\t100\t" + s + " ns/op\n")
+		}
+	}
+
+	path := filepath.Join(t.TempDir(), name)
+	require.NoError(t, os.WriteFile(path, []byte(buf.String()), 0o600))
+
+	return path
+}
+
+func TestRun(t *testing.T) {
+	oldPath := writeResults(t, "old.txt", map[string][]string{
+		"BenchmarkSet/2_threads/a/lazy/insert":   {"100", "101", "99", "102", "98"},
+		"BenchmarkSet/2_threads/a/lazy/contains": {"100", "101", "99", "102", "98"},
+	})
+
+	samePath := writeResults(t, "same.txt", map[string][]string{
+		"BenchmarkSet/2_threads/a/lazy/insert":   {"101", "100", "98", "102", "99"},
+		"BenchmarkSet/2_threads/a/lazy/contains": {"80", "81", "79", "82", "78"},
+	})
+
+	slowerPath := writeResults(t, "slower.txt", map[string][]string{
+		"BenchmarkSet/2_threads/a/lazy/insert": {"130", "131", "129", "132", "128"},
+	})
+
+	var stdout bytes.Buffer
+
+	regressed, err := run([]string{oldPath, samePath}, &stdout, io.Discard)
+	require.NoError(t, err)
+	require.False(t, regressed)
+	require.Contains(t, stdout.String(), "-20.00%")
+	require.Contains(t, stdout.String(), "~")
+
+	stdout.Reset()
+
+	regressed, err = run([]string{oldPath, slowerPath}, &stdout, io.Discard)
+	require.NoError(t, err)
+	require.True(t, regressed)
+	require.Contains(t, stdout.String(), "+30.00%")
+	require.Contains(t, stdout.String(), "REGRESSION")
+
+	regressed, err = run([]string{"-threshold", "50", oldPath, slowerPath}, io.Discard, io.Discard)
+	require.NoError(t, err)
+	require.False(t, regressed)
+
+	_, err = run([]string{oldPath}, io.Discard, io.Discard)
+	require.True(t, errors.Is(err, errUsage))
+
+	_, err = run([]string{"-unit", "ops/s", oldPath, samePath}, io.Discard, io.Discard)
+	require.True(t, errors.Is(err, errNoCommon))
+}
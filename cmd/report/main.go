@@ -0,0 +1,97 @@
+// Command report renders benchmark results into SVG charts (thread count vs time per scenario)
+// and regenerates the benchmark section of README. It reads either the output of `go test -bench`
+// or the JSON output of cmd/setbench.
+package main
+
+import (
+	"bytes"
+	"errors"
+	"flag"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+
+	"github.com/vitalyisaev2/linked_list_set/internal/report"
+)
+
+func main() {
+	if err := run(os.Args[1:], os.Stderr); err != nil {
+		fmt.Fprintln(os.Stderr, "report:", err)
+		os.Exit(2)
+	}
+}
+
+var errNoCharts = errors.New("no results to chart")
+
+func run(args []string, stderr io.Writer) error {
+	fs := flag.NewFlagSet("report", flag.ContinueOnError)
+	fs.SetOutput(stderr)
+
+	input := fs.String("input", "report/report.txt", "output of `go test -bench` or JSON output of setbench")
+	benchmark := fs.String("bench", "", "benchmark to chart (the first one in the input if empty)")
+	unit := fs.String("unit", report.DefaultUnit, "unit on the Y axis, e.g. ops/s or contains-p99-ns")
+	dir := fs.String("dir", "report", "directory of the charts")
+	readme := fs.String("readme", "README.md", "README to update (skipped if empty)")
+
+	if err := fs.Parse(args); err != nil {
+		return err
+	}
+
+	measurements, err := report.Load(*input)
+	if err != nil {
+		return err
+	}
+
+	if *benchmark == "" && len(measurements) > 0 {
+		*benchmark = measurements[0].Benchmark
+	}
+
+	charts := report.Charts(measurements, *benchmark, *unit)
+	if len(charts) == 0 {
+		return errNoCharts
+	}
+
+	for _, c := range charts {
+		if err := writeChart(filepath.Join(*dir, c.Name()+".svg"), c); err != nil {
+			return err
+		}
+
+		fmt.Fprintln(stderr, "rendered", c.Name())
+	}
+
+	if *readme == "" {
+		return nil
+	}
+
+	return updateReadme(*readme, *dir, charts)
+}
+
+func writeChart(path string, c *report.Chart) error {
+	var buf bytes.Buffer
+
+	if err := report.RenderSVG(&buf, c); err != nil {
+		return err
+	}
+
+	return os.WriteFile(path, buf.Bytes(), 0o644) //nolint:gosec // charts are published with the repository
+}
+
+func updateReadme(path, dir string, charts []*report.Chart) error {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return err
+	}
+
+	rel, err := filepath.Rel(filepath.Dir(path), dir)
+	if err != nil {
+		return err
+	}
+
+	updated, err := report.UpdateReadme(data, report.Section(charts, filepath.ToSlash(rel)))
+	if err != nil {
+		return err
+	}
+
+	return os.WriteFile(path, updated, 0o644) //nolint:gosec // README is published with the repository
+}
@@ -0,0 +1,248 @@
+// Command setbench runs workloads against set implementations for a fixed duration
+// and reports throughput, latency percentiles and retry counts as JSON or CSV.
+//
+// Example:
+//
+//	setbench -impl lazy,nonblocking -threads 1,4,16 -mix read_mostly,90/5/5 -distribution zipfian -duration 5s -format csv
+package main
+
+import (
+	"errors"
+	"flag"
+	"fmt"
+	"io"
+	"os"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/vitalyisaev2/linked_list_set/internal/workload"
+)
+
+// flags are the command line parameters.
+type flags struct {
+	implementations string
+	threads         string
+	mixes           string
+	distributions   string
+	keySpace        int
+	initialFill     float64
+	duration        time.Duration
+	seed            int64
+	recycling       bool
+	format          string
+	output          string
+}
+
+func main() {
+	if err := run(os.Args[1:], os.Stdout, os.Stderr); err != nil {
+		fmt.Fprintln(os.Stderr, "setbench:", err)
+		os.Exit(2)
+	}
+}
+
+// run parses the arguments, executes the runs and writes the results to stdout; progress goes to stderr.
+func run(args []string, stdout, stderr io.Writer) error {
+	var f flags
+
+	fs := flag.NewFlagSet("setbench", flag.ContinueOnError)
+	fs.SetOutput(stderr)
+	fs.StringVar(&f.implementations, "impl", strings.Join(implementationNames(), ","), "comma-separated set implementations")
+	fs.StringVar(&f.threads, "threads", "1,2,4,8", "comma-separated numbers of goroutines")
+	fs.StringVar(&f.mixes, "mix", "read_mostly", "comma-separated operation mixes: presets ("+presetNames()+") or contains/insert/remove percents")
+	fs.StringVar(&f.distributions, "distribution", "uniform", "comma-separated key distributions: uniform, zipfian, hotspot, sequential")
+	fs.IntVar(&f.keySpace, "keys", 1024, "size of the key space")
+	fs.Float64Var(&f.initialFill, "fill", 0.5, "share of the key space inserted before the run")
+	fs.DurationVar(&f.duration, "duration", time.Second, "duration of every run")
+	fs.Int64Var(&f.seed, "seed", 0, "seed of the workloads (current time if zero)")
+	fs.BoolVar(&f.recycling, "recycling", false, "enable node recycling")
+	fs.StringVar(&f.format, "format", formatJSON, "output format: json or csv")
+	fs.StringVar(&f.output, "o", "", "output file (stdout if empty)")
+
+	if err := fs.Parse(args); err != nil {
+		return err
+	}
+
+	specs, err := f.specs()
+	if err != nil {
+		return err
+	}
+
+	write, err := writerFor(f.format)
+	if err != nil {
+		return err
+	}
+
+	results := make([]*result, 0, len(specs))
+
+	for _, s := range specs {
+		fmt.Fprintf(stderr, "%s\t%d threads\t%s\n", s.implementation, s.threads, s.config.Name())
+
+		r, err := execute(s)
+		if err != nil {
+			return err
+		}
+
+		results = append(results, r)
+	}
+
+	if f.output == "" {
+		return write(stdout, results)
+	}
+
+	out, err := os.Create(f.output)
+	if err != nil {
+		return err
+	}
+
+	if err := write(out, results); err != nil {
+		out.Close()
+
+		return err
+	}
+
+	return out.Close()
+}
+
+var (
+	errUnknownImplementation = errors.New("unknown implementation")
+	errInvalidThreads        = errors.New("number of threads must be a positive integer")
+	errInvalidDuration       = errors.New("duration must be positive")
+)
+
+// invalidValueError points to the flag value that caused the error.
+type invalidValueError struct {
+	err   error
+	value string
+}
+
+func (e *invalidValueError) Error() string {
+	return fmt.Sprintf("%v: %q", e.err, e.value)
+}
+
+func (e *invalidValueError) Unwrap() error {
+	return e.err
+}
+
+// specs builds the cartesian product of implementations, thread numbers, mixes and distributions.
+func (f *flags) specs() ([]*spec, error) {
+	if f.duration <= 0 {
+		return nil, &invalidValueError{err: errInvalidDuration, value: f.duration.String()}
+	}
+
+	impls := splitList(f.implementations)
+	for _, impl := range impls {
+		if _, ok := implementations[impl]; !ok {
+			return nil, &invalidValueError{err: errUnknownImplementation, value: impl}
+		}
+	}
+
+	threads, err := parseThreads(f.threads)
+	if err != nil {
+		return nil, err
+	}
+
+	configs, err := f.configs()
+	if err != nil {
+		return nil, err
+	}
+
+	var specs []*spec
+
+	for _, config := range configs {
+		for _, impl := range impls {
+			for _, t := range threads {
+				specs = append(specs, &spec{
+					implementation: impl,
+					threads:        t,
+					config:         config,
+					duration:       f.duration,
+					recycling:      f.recycling,
+				})
+			}
+		}
+	}
+
+	return specs, nil
+}
+
+func (f *flags) configs() ([]workload.Config, error) {
+	seed := f.seed
+	if seed == 0 {
+		seed = time.Now().UnixNano()
+	}
+
+	var configs []workload.Config
+
+	for _, m := range splitList(f.mixes) {
+		mix, ok := workload.Presets[m]
+		if !ok {
+			var err error
+			if mix, err = workload.ParseMix(m); err != nil {
+				return nil, err
+			}
+		}
+
+		for _, d := range splitList(f.distributions) {
+			distribution, err := workload.ParseDistribution(d)
+			if err != nil {
+				return nil, err
+			}
+
+			config := workload.Config{
+				Mix:          mix,
+				KeySpace:     f.keySpace,
+				InitialFill:  f.initialFill,
+				Distribution: distribution,
+				Seed:         seed,
+			}
+
+			if err := config.Validate(); err != nil {
+				return nil, err
+			}
+
+			configs = append(configs, config)
+		}
+	}
+
+	return configs, nil
+}
+
+func parseThreads(s string) ([]int, error) {
+	var threads []int
+
+	for _, item := range splitList(s) {
+		t, err := strconv.Atoi(item)
+		if err != nil || t <= 0 {
+			return nil, &invalidValueError{err: errInvalidThreads, value: item}
+		}
+
+		threads = append(threads, t)
+	}
+
+	return threads, nil
+}
+
+func splitList(s string) []string {
+	var items []string
+
+	for _, item := range strings.Split(s, ",") {
+		if item = strings.TrimSpace(item); item != "" {
+			items = append(items, item)
+		}
+	}
+
+	return items
+}
+
+func presetNames() string {
+	names := make([]string, 0, len(workload.Presets))
+	for name := range workload.Presets {
+		names = append(names, name)
+	}
+
+	sort.Strings(names)
+
+	return strings.Join(names, ", ")
+}
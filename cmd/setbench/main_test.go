@@ -0,0 +1,79 @@
+package main
+
+import (
+	"bytes"
+	"encoding/csv"
+	"encoding/json"
+	"errors"
+	"io"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+
+	"github.com/vitalyisaev2/linked_list_set/internal/workload"
+)
+
+func TestRunJSON(t *testing.T) {
+	var stdout bytes.Buffer
+
+	args := []string{"-impl", "coarse_grained,nonblocking", "-threads", "1,2", "-mix", "balanced,80/10/10", "-duration", "20ms", "-keys", "64"}
+	require.NoError(t, run(args, &stdout, io.Discard))
+
+	var results []*result
+
+	require.NoError(t, json.Unmarshal(stdout.Bytes(), &results))
+	require.Len(t, results, 8)
+
+	for _, r := range results {
+		require.Positive(t, r.Operations)
+		require.Positive(t, r.Throughput)
+
+		var total int64
+
+		for _, op := range workload.Ops() {
+			l := r.Latency[op.String()]
+			require.NotNil(t, l, op)
+			require.True(t, l.P50 <= l.P90 && l.P90 <= l.P99 && l.P99 <= l.P999 && l.P999 <= l.Max, op)
+
+			total += l.Count
+		}
+
+		require.Equal(t, r.Operations, total)
+
+		if r.Implementation == "coarse_grained" {
+			require.Zero(t, r.Retries)
+		}
+	}
+}
+
+func TestRunCSV(t *testing.T) {
+	var stdout bytes.Buffer
+
+	args := []string{"-impl", "lazy", "-threads", "2", "-distribution", "zipfian,sequential", "-duration", "20ms", "-format", "csv"}
+	require.NoError(t, run(args, &stdout, io.Discard))
+
+	rows, err := csv.NewReader(&stdout).ReadAll()
+	require.NoError(t, err)
+	require.Len(t, rows, 3)
+	require.Equal(t, "implementation", rows[0][0])
+	require.Equal(t, []string{"lazy", "2", "90/9/1", "zipfian"}, rows[1][:4])
+	require.Equal(t, "sequential", rows[2][3])
+}
+
+func TestRunInvalidFlags(t *testing.T) {
+	for _, tc := range []struct {
+		args []string
+		err  error
+	}{
+		{args: []string{"-impl", "skiplist"}, err: errUnknownImplementation},
+		{args: []string{"-threads", "0"}, err: errInvalidThreads},
+		{args: []string{"-duration", "0s"}, err: errInvalidDuration},
+		{args: []string{"-format", "xml"}, err: errUnknownFormat},
+	} {
+		err := run(tc.args, io.Discard, io.Discard)
+		require.True(t, errors.Is(err, tc.err), err)
+	}
+
+	require.Error(t, run([]string{"-mix", "50/50"}, io.Discard, io.Discard))
+	require.Error(t, run([]string{"-distribution", "normal"}, io.Discard, io.Discard))
+}
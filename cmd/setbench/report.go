@@ -0,0 +1,154 @@
+package main
+
+import (
+	"encoding/csv"
+	"encoding/json"
+	"errors"
+	"io"
+	"strconv"
+	"time"
+
+	"github.com/vitalyisaev2/linked_list_set/internal/histogram"
+	"github.com/vitalyisaev2/linked_list_set/internal/workload"
+)
+
+// result is the outcome of a single run.
+type result struct {
+	Implementation string              `json:"implementation"`
+	Threads        int                 `json:"threads"`
+	Workload       string              `json:"workload"`
+	Mix            string              `json:"mix"`
+	Distribution   string              `json:"distribution"`
+	KeySpace       int                 `json:"key_space"`
+	Seconds        float64             `json:"seconds"`
+	Operations     int64               `json:"operations"`
+	Throughput     float64             `json:"throughput"`
+	Retries        int64               `json:"retries"`
+	Latency        map[string]*latency `json:"latency"`
+}
+
+// latency contains the percentiles of latency of a single kind of operation in nanoseconds.
+type latency struct {
+	Count int64 `json:"count"`
+	P50   int64 `json:"p50"`
+	P90   int64 `json:"p90"`
+	P99   int64 `json:"p99"`
+	P999  int64 `json:"p999"`
+	Max   int64 `json:"max"`
+}
+
+func newResult(s *spec, elapsed time.Duration, workers []*worker, retries int64) *result {
+	r := &result{
+		Implementation: s.implementation,
+		Threads:        s.threads,
+		Workload:       s.config.Name(),
+		Mix:            s.config.Mix.String(),
+		Distribution:   s.config.Distribution.String(),
+		KeySpace:       s.config.KeySpace,
+		Seconds:        elapsed.Seconds(),
+		Retries:        retries,
+		Latency:        make(map[string]*latency),
+	}
+
+	for _, op := range workload.Ops() {
+		merged := histogram.New()
+		for _, wk := range workers {
+			merged.Merge(wk.latencies[op])
+		}
+
+		r.Operations += merged.Count()
+
+		if merged.Count() > 0 {
+			r.Latency[op.String()] = newLatency(merged)
+		}
+	}
+
+	r.Throughput = float64(r.Operations) / r.Seconds
+
+	return r
+}
+
+func newLatency(h *histogram.Histogram) *latency {
+	return &latency{
+		Count: h.Count(),
+		P50:   h.ValueAtQuantile(0.5),
+		P90:   h.ValueAtQuantile(0.9),
+		P99:   h.ValueAtQuantile(0.99),
+		P999:  h.ValueAtQuantile(0.999),
+		Max:   h.Max(),
+	}
+}
+
+const (
+	formatJSON = "json"
+	formatCSV  = "csv"
+)
+
+var errUnknownFormat = errors.New("unknown output format")
+
+func writerFor(format string) (func(io.Writer, []*result) error, error) {
+	switch format {
+	case formatJSON:
+		return writeJSON, nil
+	case formatCSV:
+		return writeCSV, nil
+	default:
+		return nil, &invalidValueError{err: errUnknownFormat, value: format}
+	}
+}
+
+func writeJSON(w io.Writer, results []*result) error {
+	enc := json.NewEncoder(w)
+	enc.SetIndent("", "  ")
+
+	return enc.Encode(results)
+}
+
+// writeCSV writes a row per run; latency columns are repeated for every kind of operation.
+func writeCSV(w io.Writer, results []*result) error {
+	header := []string{"implementation", "threads", "mix", "distribution", "key_space", "seconds", "operations", "throughput", "retries"}
+	for _, op := range workload.Ops() {
+		for _, column := range []string{"count", "p50", "p90", "p99", "p999", "max"} {
+			header = append(header, op.String()+"_"+column)
+		}
+	}
+
+	cw := csv.NewWriter(w)
+
+	if err := cw.Write(header); err != nil {
+		return err
+	}
+
+	for _, r := range results {
+		row := []string{
+			r.Implementation,
+			strconv.Itoa(r.Threads),
+			r.Mix,
+			r.Distribution,
+			strconv.Itoa(r.KeySpace),
+			strconv.FormatFloat(r.Seconds, 'f', 3, 64),
+			strconv.FormatInt(r.Operations, 10),
+			strconv.FormatFloat(r.Throughput, 'f', 0, 64),
+			strconv.FormatInt(r.Retries, 10),
+		}
+
+		for _, op := range workload.Ops() {
+			l := r.Latency[op.String()]
+			if l == nil {
+				l = &latency{}
+			}
+
+			for _, v := range []int64{l.Count, l.P50, l.P90, l.P99, l.P999, l.Max} {
+				row = append(row, strconv.FormatInt(v, 10))
+			}
+		}
+
+		if err := cw.Write(row); err != nil {
+			return err
+		}
+	}
+
+	cw.Flush()
+
+	return cw.Error()
+}
@@ -0,0 +1,114 @@
+package main
+
+import (
+	"sort"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	set "github.com/vitalyisaev2/linked_list_set"
+	"github.com/vitalyisaev2/linked_list_set/internal/histogram"
+	"github.com/vitalyisaev2/linked_list_set/internal/workload"
+	"github.com/vitalyisaev2/linked_list_set/metrics"
+)
+
+// implementations lists the thread-safe sets by the names used in benchmarks.
+//
+//nolint:gochecknoglobals // read-only registry
+var implementations = map[string]func(opts ...set.Option) set.Set{
+	"coarse_grained":     set.NewCoarseGrainedSyncSet,
+	"fine_grained":       set.NewFineGrainedSyncSet,
+	"optimistic":         set.NewOptimisticSyncSet,
+	"lazy":               set.NewLazySyncSet,
+	"nonblocking":        set.NewNonBlockingSyncSet,
+	"nonblocking_hazard": set.NewHazardNonBlockingSyncSet,
+	"stm":                func(opts ...set.Option) set.Set { return set.NewSTMSet(opts...) },
+	"mvcc":               func(opts ...set.Option) set.Set { return set.NewMVCCSet(opts...) },
+}
+
+func implementationNames() []string {
+	names := make([]string, 0, len(implementations))
+	for name := range implementations {
+		names = append(names, name)
+	}
+
+	sort.Strings(names)
+
+	return names
+}
+
+// spec describes a single run.
+type spec struct {
+	implementation string
+	threads        int
+	config         workload.Config
+	duration       time.Duration
+	recycling      bool
+}
+
+// worker is the state of a single goroutine.
+type worker struct {
+	generator *workload.Generator
+	latencies [3]*histogram.Histogram
+}
+
+// execute fills the set, runs the workload for the given duration and collects the results.
+func execute(s *spec) (*result, error) {
+	w, err := workload.New(s.config)
+	if err != nil {
+		return nil, err
+	}
+
+	// the sets without retry loops never call the contention manager
+	counter := metrics.CountRetries(nil)
+	opts := []set.Option{set.WithContentionManager(counter)}
+
+	if s.recycling {
+		opts = append(opts, set.WithNodeRecycling())
+	}
+
+	target := implementations[s.implementation](opts...)
+	s.config.Fill(target)
+
+	workers := make([]*worker, s.threads)
+	for i := range workers {
+		workers[i] = &worker{generator: w.Generator(i)}
+		for j := range workers[i].latencies {
+			workers[i].latencies[j] = histogram.New()
+		}
+	}
+
+	var stop int32
+
+	wg := sync.WaitGroup{}
+	wg.Add(s.threads)
+
+	start := time.Now()
+
+	for _, wk := range workers {
+		wk := wk
+
+		go func() {
+			defer wg.Done()
+
+			wk.run(target, &stop)
+		}()
+	}
+
+	time.AfterFunc(s.duration, func() { atomic.StoreInt32(&stop, 1) })
+	wg.Wait()
+
+	return newResult(s, time.Since(start), workers, counter.Retries()), nil
+}
+
+func (wk *worker) run(target set.Set, stop *int32) {
+	for atomic.LoadInt32(stop) == 0 {
+		op, key := wk.generator.Next()
+
+		start := time.Now()
+		workload.Apply(target, op, key)
+		elapsed := time.Since(start)
+
+		wk.latencies[op].Record(elapsed)
+	}
+}
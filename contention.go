@@ -0,0 +1,95 @@
+package set
+
+import (
+	"math/bits"
+	"runtime"
+	"time"
+)
+
+// ContentionManager decides what a goroutine does after a failed optimistic attempt
+// (failed validation or lost CAS) before it tries again.
+type ContentionManager interface {
+	// Backoff is called before the retry with the given number (starting from 1).
+	Backoff(attempt int)
+}
+
+var _ ContentionManager = noContentionManager{}
+
+// noContentionManager retries immediately.
+type noContentionManager struct{}
+
+func (noContentionManager) Backoff(int) {}
+
+// NewNoContentionManager returns contention manager that retries failed attempts immediately.
+func NewNoContentionManager() ContentionManager {
+	return noContentionManager{}
+}
+
+var _ ContentionManager = yieldContentionManager{}
+
+// yieldContentionManager gives the processor to other goroutines before retrying.
+type yieldContentionManager struct{}
+
+func (yieldContentionManager) Backoff(int) {
+	runtime.Gosched()
+}
+
+// NewYieldContentionManager returns contention manager that calls runtime.Gosched before every retry.
+func NewYieldContentionManager() ContentionManager {
+	return yieldContentionManager{}
+}
+
+var _ ContentionManager = (*exponentialBackoff)(nil)
+
+// exponentialBackoff doubles the delay after every failed attempt and randomizes it
+// to keep competing goroutines from retrying in lockstep.
+type exponentialBackoff struct {
+	min time.Duration
+	max time.Duration
+}
+
+func (e *exponentialBackoff) Backoff(attempt int) {
+	delay := e.delay(attempt)
+
+	// "equal jitter": sleep somewhere in [delay/2, delay)
+	half := delay / 2
+	jitter := time.Duration(splitMix64(uint64(time.Now().UnixNano())+uint64(attempt)) % uint64(half+1))
+
+	time.Sleep(half + jitter)
+}
+
+// delay is the upper bound of the sleep before the retry: min doubled for every previous attempt, up to max.
+func (e *exponentialBackoff) delay(attempt int) time.Duration {
+	shift := attempt - 1
+
+	// the shift is clamped before it's applied: min << shift must not reach the sign bit
+	if shift >= bits.LeadingZeros64(uint64(e.min)) || e.min<<shift > e.max {
+		return e.max
+	}
+
+	return e.min << shift
+}
+
+// NewExponentialBackoff returns contention manager that sleeps for a randomized, exponentially growing
+// interval before every retry. The interval starts from min and never exceeds max.
+func NewExponentialBackoff(min, max time.Duration) ContentionManager {
+	if min <= 0 {
+		min = 1
+	}
+
+	if max < min {
+		max = min
+	}
+
+	return &exponentialBackoff{min: min, max: max}
+}
+
+// splitMix64 is a cheap stateless mixer used as a source of jitter:
+// math/rand's global source is guarded by a mutex that would become a contention point itself.
+func splitMix64(x uint64) uint64 {
+	x += 0x9e3779b97f4a7c15
+	x = (x ^ (x >> 30)) * 0xbf58476d1ce4e5b9
+	x = (x ^ (x >> 27)) * 0x94d049bb133111eb
+
+	return x ^ (x >> 31)
+}
@@ -0,0 +1,104 @@
+package set
+
+import (
+	"math"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestExponentialBackoff(t *testing.T) {
+	t.Run("bounds are normalized", func(t *testing.T) {
+		cm, ok := NewExponentialBackoff(0, -1).(*exponentialBackoff)
+		require.True(t, ok)
+		require.Equal(t, time.Duration(1), cm.min)
+		require.Equal(t, time.Duration(1), cm.max)
+	})
+
+	t.Run("delay never exceeds max", func(t *testing.T) {
+		cm := NewExponentialBackoff(time.Microsecond, 2*time.Millisecond)
+
+		// huge attempt numbers must not overflow the shift
+		start := time.Now()
+		cm.Backoff(1 << 20)
+		require.Less(t, int64(time.Since(start)), int64(time.Second))
+	})
+
+	t.Run("delay doesn't overflow", func(t *testing.T) {
+		cm, ok := NewExponentialBackoff(time.Microsecond, 2*time.Millisecond).(*exponentialBackoff)
+		require.True(t, ok)
+		require.Equal(t, time.Microsecond, cm.delay(1))
+		require.Equal(t, 4*time.Microsecond, cm.delay(3))
+		require.Equal(t, 2*time.Millisecond, cm.delay(12))
+		require.Equal(t, 2*time.Millisecond, cm.delay(64))
+		require.Equal(t, 2*time.Millisecond, cm.delay(1<<20))
+
+		// min << 30 wraps around to a second, which is below both min and max
+		cm, ok = NewExponentialBackoff(1<<34+1, time.Hour).(*exponentialBackoff)
+		require.True(t, ok)
+		require.Equal(t, time.Duration(1<<34+1), cm.delay(1))
+		require.Equal(t, time.Hour, cm.delay(31))
+
+		cm, ok = NewExponentialBackoff(1, math.MaxInt64).(*exponentialBackoff)
+		require.True(t, ok)
+		require.Equal(t, time.Duration(1<<62), cm.delay(63))
+		require.Equal(t, time.Duration(math.MaxInt64), cm.delay(64))
+	})
+}
+
+// TestContentionManagers verifies that every contention policy keeps retrying implementations correct.
+func TestContentionManagers(t *testing.T) {
+	f := factory{}
+
+	kinds := []setKind{
+		optimistic,
+		lazy,
+		nonBlocking,
+	}
+
+	policies := map[string]ContentionManager{
+		"none":                NewNoContentionManager(),
+		"exponential_backoff": NewExponentialBackoff(time.Microsecond, 100*time.Microsecond),
+		"yield":               NewYieldContentionManager(),
+	}
+
+	const (
+		threads = 8
+		items   = 200
+	)
+
+	for _, k := range kinds {
+		k := k
+
+		for name, cm := range policies {
+			cm := cm
+
+			t.Run(k.String()+"/"+name, func(t *testing.T) {
+				set := f.new(k, WithContentionManager(cm))
+
+				wg := sync.WaitGroup{}
+				wg.Add(threads)
+
+				for i := 0; i < threads; i++ {
+					go func() {
+						defer wg.Done()
+
+						for j := 0; j < items; j++ {
+							set.Insert(j)
+							set.Remove(j)
+							set.Insert(j)
+						}
+					}()
+				}
+
+				wg.Wait()
+
+				for j := 0; j < items; j++ {
+					require.True(t, set.Contains(j), j)
+				}
+			})
+		}
+	}
+}
@@ -0,0 +1,166 @@
+package set
+
+import (
+	"math"
+	"sync"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+// fuzzOp is a single operation decoded from the fuzz input.
+type fuzzOp struct {
+	kind byte // 0 - Insert, 1 - Contains, 2 - Remove
+	key  int
+}
+
+// maxFuzzThreads limits the number of goroutines the fuzz input may ask for.
+const maxFuzzThreads = 4
+
+// decodeFuzzInput turns raw bytes into the number of goroutines and a sequence of operations:
+// the first byte chooses the number of goroutines, every next pair of bytes encodes an operation and a key.
+func decodeFuzzInput(data []byte) (threads int, ops []fuzzOp) {
+	if len(data) == 0 {
+		return 1, nil
+	}
+
+	threads = int(data[0])%maxFuzzThreads + 1
+
+	for i := 1; i+1 < len(data); i += 2 {
+		ops = append(ops, fuzzOp{kind: data[i] % 3, key: decodeFuzzKey(data[i+1])})
+	}
+
+	return threads, ops
+}
+
+// decodeFuzzKey keeps the keys in the int8 range to make operations collide, except for the int8 extremes:
+// they stand for the sentinel values of the implementations and their neighbours.
+func decodeFuzzKey(b byte) int {
+	switch key := int8(b); key {
+	case math.MinInt8:
+		return math.MinInt64
+	case math.MinInt8 + 1:
+		return math.MinInt64 + 1
+	case math.MinInt8 + 2:
+		return math.MinInt64 + 2
+	case math.MaxInt8 - 1:
+		return math.MaxInt64 - 1
+	case math.MaxInt8:
+		return math.MaxInt64
+	default:
+		return int(key)
+	}
+}
+
+func (op fuzzOp) apply(s Set) bool {
+	switch op.kind {
+	case 0:
+		return s.Insert(op.key)
+	case 1:
+		return s.Contains(op.key)
+	default:
+		return s.Remove(op.key)
+	}
+}
+
+// applyToOracle executes the operation on the map-based oracle.
+func (op fuzzOp) applyToOracle(oracle map[int]struct{}) bool {
+	// the sets never store the reserved values
+	if reserved(op.key) {
+		return false
+	}
+
+	_, exists := oracle[op.key]
+
+	switch op.kind {
+	case 0:
+		oracle[op.key] = struct{}{}
+
+		return !exists
+	case 1:
+		return exists
+	default:
+		delete(oracle, op.key)
+
+		return exists
+	}
+}
+
+// FuzzSet compares every implementation with a map-based oracle. When the input asks for several goroutines,
+// every key is owned by a single goroutine: operations on different keys commute, so the results
+// of every goroutine must match its own sequential oracle, no matter how the goroutines interleave.
+func FuzzSet(f *testing.F) {
+	f.Add([]byte{0, 0, 1, 1, 1, 2, 1, 1, 1})
+	f.Add([]byte{3, 0, 5, 0, 6, 2, 5, 2, 6, 0, 5})
+	f.Add([]byte{1, 0, 0x80, 0, 0x7f, 2, 0x80, 1, 0x7f})
+
+	fa := factory{}
+
+	kinds := []setKind{
+		sequential,
+		coarseGrained,
+		fineGrained,
+		optimistic,
+		lazy,
+		nonBlocking,
+		hazardNonBlocking,
+		stm,
+		mvcc,
+	}
+
+	f.Fuzz(func(t *testing.T, data []byte) {
+		threads, ops := decodeFuzzInput(data)
+
+		for _, k := range kinds {
+			if k == sequential && threads > 1 {
+				continue
+			}
+
+			set := fa.new(k)
+
+			// split operations between goroutines by key ownership
+			scripts := make([][]fuzzOp, threads)
+			for _, op := range ops {
+				owner := (op.key%threads + threads) % threads
+				scripts[owner] = append(scripts[owner], op)
+			}
+
+			oracles := make([]map[int]struct{}, threads)
+			mismatches := make([][]fuzzOp, threads)
+
+			wg := sync.WaitGroup{}
+			wg.Add(threads)
+
+			for i := 0; i < threads; i++ {
+				i := i
+				oracles[i] = make(map[int]struct{})
+
+				go func() {
+					defer wg.Done()
+
+					for _, op := range scripts[i] {
+						if op.apply(set) != op.applyToOracle(oracles[i]) {
+							mismatches[i] = append(mismatches[i], op)
+						}
+					}
+				}()
+			}
+
+			wg.Wait()
+
+			for i := range mismatches {
+				require.Empty(t, mismatches[i], "%v: goroutine %d", k, i)
+			}
+
+			checker, ok := set.(invariantChecker)
+			require.True(t, ok)
+			require.NoError(t, checker.checkInvariants(), k)
+
+			// final contents must match the union of oracles
+			for key := -128; key < 128; key++ {
+				_, expected := oracles[(key%threads+threads)%threads][key]
+				require.Equal(t, expected, set.Contains(key), "%v: key %d", k, key)
+			}
+		}
+	})
+}
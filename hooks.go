@@ -0,0 +1,91 @@
+package set
+
+// Operation identifies the operation reported to Hooks.
+type Operation int8
+
+// Operations of a set.
+const (
+	OperationInsert Operation = iota + 1
+	OperationContains
+	OperationRemove
+)
+
+func (op Operation) String() string {
+	switch op {
+	case OperationInsert:
+		return "insert"
+	case OperationContains:
+		return "contains"
+	case OperationRemove:
+		return "remove"
+	default:
+		panic("unknown Operation")
+	}
+}
+
+// Hooks are callbacks invoked by a set at the key points of its operations; any of them may be nil.
+//
+// OnInsert and OnRemove are called exactly once per successful operation, right after its linearization point:
+// lock-based sets call them while still holding the locks, so the callbacks of conflicting operations
+// are ordered like the operations themselves. Non-blocking sets call them right after the successful CAS,
+// so callbacks of concurrent operations on the same value may run in any order.
+// STM set reports the operations of a transaction when it commits, under the locks of the written nodes.
+// MVCC set calls them under the locks too, except for Contains, which takes no locks.
+// The callbacks must be fast and must not call the set.
+type Hooks struct {
+	// OnInsert is called when the value has been inserted.
+	OnInsert func(value int)
+	// OnRemove is called when the value has been removed.
+	OnRemove func(value int)
+	// OnFailure is called when an operation returns false: the value is already present for Insert,
+	// or it is absent for Contains and Remove.
+	OnFailure func(op Operation, value int)
+	// OnRetry is called before every retry of an optimistic or non-blocking operation;
+	// attempt is the number of failed attempts so far. Restarts of the traversal inside findWindow are not reported.
+	OnRetry func(op Operation, value int, attempt int)
+	// OnLock is called by lock-based sets once they hold the locks protecting the linearization point
+	// of the operation: the set lock of coarse-grained set, the locks of the final window for the others.
+	// Optimistic and lazy sets call it only for the attempt that passed validation. STM set calls neither OnRetry nor OnLock.
+	// MVCC set calls OnLock only for Insert and Remove, and OnRetry for Contains when a collection has passed its version.
+	OnLock func(op Operation, value int)
+}
+
+func (h *Hooks) inserted(value int) {
+	if h.OnInsert != nil {
+		h.OnInsert(value)
+	}
+}
+
+func (h *Hooks) removed(value int) {
+	if h.OnRemove != nil {
+		h.OnRemove(value)
+	}
+}
+
+func (h *Hooks) failed(op Operation, value int) {
+	if h.OnFailure != nil {
+		h.OnFailure(op, value)
+	}
+}
+
+func (h *Hooks) retried(op Operation, value, attempt int) {
+	if h.OnRetry != nil {
+		h.OnRetry(op, value, attempt)
+	}
+}
+
+func (h *Hooks) locked(op Operation, value int) {
+	if h.OnLock != nil {
+		h.OnLock(op, value)
+	}
+}
+
+// result reports the outcome of the operation; successful Insert and Remove must be reported
+// at their linearization points instead.
+func (h *Hooks) result(op Operation, value int, ok bool) bool {
+	if !ok {
+		h.failed(op, value)
+	}
+
+	return ok
+}
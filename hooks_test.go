@@ -0,0 +1,181 @@
+package set
+
+import (
+	"sync"
+	"sync/atomic"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+// hookRecorder counts the callbacks and keeps the order of insertions and removals of every value.
+type hookRecorder struct {
+	mutex    sync.Mutex
+	events   map[int][]Operation
+	inserted int64
+	removed  int64
+	failures [OperationRemove + 1]int64
+	locks    int64
+	retries  int64
+}
+
+func newHookRecorder() *hookRecorder {
+	return &hookRecorder{events: make(map[int][]Operation)}
+}
+
+func (r *hookRecorder) record(op Operation, value int) {
+	r.mutex.Lock()
+	r.events[value] = append(r.events[value], op)
+	r.mutex.Unlock()
+}
+
+func (r *hookRecorder) hooks() Hooks {
+	return Hooks{
+		OnInsert: func(value int) {
+			atomic.AddInt64(&r.inserted, 1)
+			r.record(OperationInsert, value)
+		},
+		OnRemove: func(value int) {
+			atomic.AddInt64(&r.removed, 1)
+			r.record(OperationRemove, value)
+		},
+		OnFailure: func(op Operation, value int) { atomic.AddInt64(&r.failures[op], 1) },
+		OnRetry:   func(op Operation, value, attempt int) { atomic.AddInt64(&r.retries, 1) },
+		OnLock:    func(op Operation, value int) { atomic.AddInt64(&r.locks, 1) },
+	}
+}
+
+// TestHooks verifies that the callbacks are called exactly once per operation.
+func TestHooks(t *testing.T) {
+	f := factory{}
+
+	kinds := []setKind{
+		coarseGrained,
+		fineGrained,
+		optimistic,
+		lazy,
+		nonBlocking,
+		hazardNonBlocking,
+	}
+
+	const (
+		threads = 4
+		items   = 300
+		keys    = 16
+	)
+
+	for _, k := range kinds {
+		k := k
+
+		t.Run(k.String(), func(t *testing.T) {
+			recorder := newHookRecorder()
+			set := f.new(k, WithHooks(recorder.hooks()))
+
+			// results returned by the set: by operation and result
+			var results [OperationRemove + 1][2]int64
+
+			count := func(op Operation, ok bool) {
+				ix := 0
+				if ok {
+					ix = 1
+				}
+
+				atomic.AddInt64(&results[op][ix], 1)
+			}
+
+			wg := sync.WaitGroup{}
+			wg.Add(threads)
+
+			for i := 0; i < threads; i++ {
+				i := i
+
+				go func() {
+					defer wg.Done()
+
+					for j := 0; j < items; j++ {
+						key := (i + j) % keys
+						count(OperationInsert, set.Insert(key))
+						count(OperationContains, set.Contains(key))
+						count(OperationRemove, set.Remove(key))
+					}
+				}()
+			}
+
+			wg.Wait()
+
+			require.Equal(t, results[OperationInsert][1], recorder.inserted)
+			require.Equal(t, results[OperationRemove][1], recorder.removed)
+
+			for _, op := range []Operation{OperationInsert, OperationContains, OperationRemove} {
+				require.Equal(t, results[op][0], recorder.failures[op], op)
+			}
+
+			if k == nonBlocking || k == hazardNonBlocking {
+				require.Zero(t, recorder.locks)
+
+				return
+			}
+
+			// every operation locks its linearization point once
+			require.Equal(t, int64(threads*items*3), recorder.locks)
+
+			// lock-based sets call the hooks under the locks, so insertions and removals of a value alternate
+			for value, events := range recorder.events {
+				for i, op := range events {
+					expected := OperationInsert
+					if i%2 == 1 {
+						expected = OperationRemove
+					}
+
+					require.Equal(t, expected, op, "value %d, event %d", value, i)
+				}
+			}
+		})
+	}
+}
+
+func TestHooksSequential(t *testing.T) {
+	recorder := newHookRecorder()
+	set := NewSequentialSet(WithHooks(recorder.hooks()))
+
+	require.True(t, set.Insert(1))
+	require.False(t, set.Insert(1))
+	require.True(t, set.Contains(1))
+	require.False(t, set.Contains(2))
+	require.True(t, set.Remove(1))
+	require.False(t, set.Remove(1))
+
+	require.Equal(t, int64(1), recorder.inserted)
+	require.Equal(t, int64(1), recorder.removed)
+	require.Equal(t, [OperationRemove + 1]int64{0, 1, 1, 1}, recorder.failures)
+	require.Equal(t, map[int][]Operation{1: {OperationInsert, OperationRemove}}, recorder.events)
+	require.Zero(t, recorder.locks)
+	require.Zero(t, recorder.retries)
+}
+
+// TestHooksRetry provokes a failed validation of the lazy set deterministically:
+// the node preceding the inserted value is marked as if it was removed concurrently.
+func TestHooksRetry(t *testing.T) {
+	s, ok := NewLazySyncSet().(*lazySyncSet)
+	require.True(t, ok)
+
+	require.True(t, s.Insert(1))
+	require.True(t, s.Insert(3))
+
+	one := s.head.getNext()
+	one.marked = true
+
+	var retries []int
+
+	// the node is unlinked once the first attempt has failed
+	s.hooks.OnRetry = func(op Operation, value, attempt int) {
+		require.Equal(t, OperationInsert, op)
+		require.Equal(t, 2, value)
+
+		retries = append(retries, attempt)
+		s.head.setNext(one.getNext())
+	}
+
+	require.True(t, s.Insert(2))
+	require.Equal(t, []int{1}, retries)
+}
@@ -1,10 +1,45 @@
 // Package set provides various implementations of linked-list based sets
 package set
 
+import (
+	"math"
+)
+
 // Set contains unique integer values
 // TODO: generics.
+//
+// The values math.MinInt64, math.MinInt64+1 and math.MaxInt64 are reserved for the sentinel nodes:
+// they are never stored, so Insert, Contains and Remove of them return false.
 type Set interface {
 	Insert(value int) bool
 	Contains(value int) bool
 	Remove(value int) bool
 }
+
+// reserved reports whether the value is one of the sentinel values of the implementations.
+func reserved(value int) bool {
+	return value == math.MinInt64 || value == -math.MaxInt64 || value == math.MaxInt64
+}
+
+// Iterable is implemented by all sets of the package.
+//
+// Ascend calls yield for the values of the set in ascending order until yield returns false;
+// yield must not modify the set. Coarse-grained set holds its read lock during the walk, so the values
+// form a snapshot. Other concurrent sets don't block the writers: the walk reports every value present
+// during the whole walk and no value absent during the whole walk, while the values inserted or removed
+// concurrently may or may not be reported.
+type Iterable interface {
+	Ascend(yield func(value int) bool)
+}
+
+var (
+	_ Iterable = (*sequentialSet)(nil)
+	_ Iterable = (*coarseGrainedSyncSet)(nil)
+	_ Iterable = (*fineGrainedSyncSet)(nil)
+	_ Iterable = (*optimisticSyncSet)(nil)
+	_ Iterable = (*lazySyncSet)(nil)
+	_ Iterable = (*nonBlockingSet)(nil)
+	_ Iterable = (*hazardNonBlockingSet)(nil)
+	_ Iterable = (*stmSet)(nil)
+	_ Iterable = (*mvccSet)(nil)
+)
@@ -0,0 +1,21 @@
+//go:build !interleave
+// +build !interleave
+
+package set
+
+import (
+	"runtime"
+	"sync"
+)
+
+// Without interleave build tag yield points compile to nothing, and the sets use standard mutexes.
+type (
+	nodeMutex = sync.Mutex
+	setMutex  = sync.RWMutex
+)
+
+func yieldPoint() {}
+
+func spinWait() {
+	runtime.Gosched()
+}
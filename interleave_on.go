@@ -0,0 +1,98 @@
+//go:build interleave
+// +build interleave
+
+package set
+
+import (
+	"runtime"
+	"sync/atomic"
+)
+
+// yieldHook is installed by the controlled scheduler in tests: it suspends the calling goroutine
+// and lets the scheduler decide which goroutine runs next.
+var yieldHook func()
+
+// yieldPoint marks the place inside an operation where another goroutine may interleave.
+func yieldPoint() {
+	if yieldHook != nil {
+		yieldHook()
+	}
+}
+
+// The controlled scheduler runs a single goroutine at a time, so a goroutine must never block
+// on a lock held by a suspended one: the locks below yield to the scheduler instead of blocking.
+type (
+	nodeMutex = spinMutex
+	setMutex  = spinRWMutex
+)
+
+// the spin locks have the same size as standard mutexes to keep the node layout intact.
+type spinMutex struct {
+	state int32
+	_     int32
+}
+
+func (m *spinMutex) Lock() {
+	for !atomic.CompareAndSwapInt32(&m.state, 0, 1) {
+		spinWait()
+	}
+}
+
+func (m *spinMutex) TryLock() bool {
+	return atomic.CompareAndSwapInt32(&m.state, 0, 1)
+}
+
+func (m *spinMutex) Unlock() {
+	atomic.StoreInt32(&m.state, 0)
+}
+
+// spinRWMutex is either held by a single writer (-1) or by a number of readers.
+type spinRWMutex struct {
+	state int32
+	_     int32
+}
+
+func (m *spinRWMutex) Lock() {
+	for !atomic.CompareAndSwapInt32(&m.state, 0, -1) {
+		spinWait()
+	}
+}
+
+func (m *spinRWMutex) TryLock() bool {
+	return atomic.CompareAndSwapInt32(&m.state, 0, -1)
+}
+
+func (m *spinRWMutex) Unlock() {
+	atomic.StoreInt32(&m.state, 0)
+}
+
+func (m *spinRWMutex) RLock() {
+	for {
+		state := atomic.LoadInt32(&m.state)
+		if state >= 0 && atomic.CompareAndSwapInt32(&m.state, state, state+1) {
+			return
+		}
+
+		spinWait()
+	}
+}
+
+func (m *spinRWMutex) TryRLock() bool {
+	state := atomic.LoadInt32(&m.state)
+
+	return state >= 0 && atomic.CompareAndSwapInt32(&m.state, state, state+1)
+}
+
+func (m *spinRWMutex) RUnlock() {
+	atomic.AddInt32(&m.state, -1)
+}
+
+func spinWait() {
+	if yieldHook != nil {
+		yieldHook()
+
+		return
+	}
+
+	runtime.Gosched()
+}
@@ -0,0 +1,431 @@
+//go:build interleave
+// +build interleave
+
+package set
+
+import (
+	"errors"
+	"flag"
+	"math/rand"
+	"reflect"
+	"runtime"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+
+	"github.com/vitalyisaev2/linked_list_set/internal/linearizability"
+)
+
+var (
+	interleaveSeed       = flag.Int64("interleave.seed", 0, "replay the interleaving with the given seed")
+	interleaveIterations = flag.Int("interleave.iterations", 200, "number of random interleavings per implementation")
+)
+
+// scheduler runs a single goroutine at a time and switches between them at yield points.
+// Every decision is taken from the seeded source, so the seed fully determines the interleaving.
+type scheduler struct {
+	rng     *rand.Rand
+	yielded chan struct{}
+	// stopped releases the suspended workers once the schedule is over
+	stopped chan struct{}
+	current *worker
+	// maximal number of decisions, protects from livelocks of spinning locks
+	budget int
+}
+
+var errBudgetExhausted = errors.New("scheduling budget exhausted")
+
+type worker struct {
+	resume chan struct{}
+	done   bool
+}
+
+func newScheduler(seed int64) *scheduler {
+	return &scheduler{
+		rng:     rand.New(rand.NewSource(seed)),
+		yielded: make(chan struct{}),
+		stopped: make(chan struct{}),
+		budget:  1 << 20,
+	}
+}
+
+// yield suspends the current worker and gives control back to the scheduler.
+func (s *scheduler) yield() {
+	w := s.current
+	s.yielded <- struct{}{}
+
+	if !s.resumed(w) {
+		// the schedule is abandoned, the worker exits in the middle of its function
+		runtime.Goexit()
+	}
+}
+
+// resumed waits until the scheduler resumes the worker; it returns false once the schedule is over.
+func (s *scheduler) resumed(w *worker) bool {
+	select {
+	case <-w.resume:
+		return true
+	case <-s.stopped:
+		return false
+	}
+}
+
+// run executes the functions concurrently under control of the scheduler.
+func (s *scheduler) run(fns []func()) error {
+	workers := make([]*worker, len(fns))
+
+	var wg sync.WaitGroup
+
+	wg.Add(len(fns))
+
+	for i, fn := range fns {
+		w := &worker{resume: make(chan struct{})}
+		workers[i] = w
+		fn := fn
+
+		go func() {
+			defer wg.Done()
+
+			if !s.resumed(w) {
+				return
+			}
+
+			fn()
+
+			w.done = true
+			s.yielded <- struct{}{}
+		}()
+	}
+
+	yieldHook = s.yield
+
+	// the workers left over by an exhausted budget must not leak into the next schedules
+	defer func() {
+		close(s.stopped)
+		wg.Wait()
+
+		yieldHook = nil
+	}()
+
+	alive := append([]*worker(nil), workers...)
+
+	for len(alive) > 0 {
+		if s.budget == 0 {
+			return errBudgetExhausted
+		}
+
+		s.budget--
+
+		ix := s.rng.Intn(len(alive))
+		s.current = alive[ix]
+		s.current.resume <- struct{}{}
+		<-s.yielded
+
+		if s.current.done {
+			alive = append(alive[:ix], alive[ix+1:]...)
+		}
+	}
+
+	return nil
+}
+
+func TestSchedulerBudget(t *testing.T) {
+	s := newScheduler(1)
+	s.budget = 10
+
+	var exited int32
+
+	// the spinning workers never finish, the suspended ones are released once the budget is exhausted
+	spin := func() {
+		defer atomic.AddInt32(&exited, 1)
+
+		for {
+			yieldPoint()
+		}
+	}
+
+	require.ErrorIs(t, s.run([]func(){spin, spin, spin}), errBudgetExhausted)
+	require.Equal(t, int32(3), atomic.LoadInt32(&exited))
+}
+
+// TestInterleavings runs small concurrent scenarios under the controlled scheduler and checks
+// linearizability of every history. A failure reports the seed replaying the exact interleaving.
+func TestInterleavings(t *testing.T) {
+	f := factory{}
+
+	kinds := []setKind{
+		coarseGrained,
+		fineGrained,
+		optimistic,
+		lazy,
+		nonBlocking,
+		hazardNonBlocking,
+		stm,
+		mvcc,
+	}
+
+	const (
+		workers = 3
+		ops     = 6
+		keys    = 3
+	)
+
+	seeds := make([]int64, 0, *interleaveIterations)
+
+	if *interleaveSeed != 0 {
+		seeds = append(seeds, *interleaveSeed)
+	} else {
+		base := time.Now().UnixNano()
+		for i := 0; i < *interleaveIterations; i++ {
+			seeds = append(seeds, base+int64(i))
+		}
+	}
+
+	for _, k := range kinds {
+		k := k
+
+		t.Run(k.String(), func(t *testing.T) {
+			for _, seed := range seeds {
+				if err := runInterleaving(f.new(k), seed, workers, ops, keys); err != nil {
+					t.Fatalf(
+						"%v\nreplay: go test -tags interleave -run 'TestInterleavings/%v$' -interleave.seed=%d",
+						err, k, seed,
+					)
+				}
+			}
+		})
+	}
+}
+
+// TestInterleavingReplay verifies that the seed reproduces exactly the same history.
+func TestInterleavingReplay(t *testing.T) {
+	const seed = 42
+
+	histories := make([]linearizability.History, 2)
+
+	for i := range histories {
+		h, err := recordInterleaving(NewNonBlockingSyncSet(), seed, 3, 6, 3)
+		require.NoError(t, err)
+
+		histories[i] = h
+	}
+
+	require.Equal(t, histories[0], histories[1])
+}
+
+func runInterleaving(set Set, seed int64, workers, ops, keys int) error {
+	h, err := recordInterleaving(set, seed, workers, ops, keys)
+	if err != nil {
+		return err
+	}
+
+	if err := linearizability.Check(h); err != nil {
+		return err
+	}
+
+	if checker, ok := set.(invariantChecker); ok {
+		return checker.checkInvariants()
+	}
+
+	return nil
+}
+
+func recordInterleaving(set Set, seed int64, workers, ops, keys int) (linearizability.History, error) {
+	// the scenario is derived from the seed as well
+	rng := rand.New(rand.NewSource(seed))
+	recorder := linearizability.NewRecorder(workers)
+
+	kindsOfOps := []linearizability.Kind{
+		linearizability.Insert,
+		linearizability.Contains,
+		linearizability.Remove,
+	}
+
+	fns := make([]func(), workers)
+
+	for i := range fns {
+		client := recorder.Client(i)
+		script := make([]func(), ops)
+
+		for j := range script {
+			kind, key := kindsOfOps[rng.Intn(len(kindsOfOps))], rng.Intn(keys)
+			script[j] = func() { client.Do(set, kind, key) }
+		}
+
+		fns[i] = func() {
+			for _, op := range script {
+				op()
+				// operations of the same worker may be separated by the operations of others
+				yieldPoint()
+			}
+		}
+	}
+
+	if err := newScheduler(rng.Int63()).run(fns); err != nil {
+		return nil, err
+	}
+
+	return recorder.History(), nil
+}
+
+// TestInterleavingSnapshot takes snapshots while every writer inserts its own values in ascending order
+// and then removes them in the same order: a linearizable snapshot contains a contiguous range of the values
+// of every writer (see TestSnapshotConsistency), which is checked under many interleavings.
+func TestInterleavingSnapshot(t *testing.T) {
+	f := factory{}
+
+	kinds := []setKind{
+		coarseGrained,
+		fineGrained,
+		optimistic,
+		lazy,
+		nonBlocking,
+		stm,
+		mvcc,
+	}
+
+	const (
+		writers   = 2
+		items     = 4
+		snapshots = 3
+	)
+
+	base := time.Now().UnixNano()
+	if *interleaveSeed != 0 {
+		base = *interleaveSeed
+	}
+
+	for _, k := range kinds {
+		k := k
+
+		t.Run(k.String(), func(t *testing.T) {
+			for i := 0; i < *interleaveIterations; i++ {
+				seed := base + int64(i)
+				set := f.new(k)
+
+				snapshotter, ok := set.(Snapshotter)
+				require.True(t, ok)
+
+				var taken [][]int
+
+				fns := make([]func(), 0, writers+1)
+
+				for w := 0; w < writers; w++ {
+					w := w
+
+					fns = append(fns, func() {
+						for j := 0; j < items; j++ {
+							set.Insert(j*writers + w)
+						}
+
+						for j := 0; j < items; j++ {
+							set.Remove(j*writers + w)
+						}
+					})
+				}
+
+				fns = append(fns, func() {
+					for j := 0; j < snapshots; j++ {
+						taken = append(taken, ascend(t, snapshotter.Snapshot()))
+					}
+				})
+
+				require.NoError(t, newScheduler(seed).run(fns))
+
+				for _, values := range taken {
+					last := make([]int, writers)
+					for w := range last {
+						last[w] = -1
+					}
+
+					for _, value := range values {
+						w, j := value%writers, value/writers
+						if last[w] >= 0 && last[w]+1 != j {
+							t.Fatalf(
+								"snapshot %v is not linearizable\nreplay: go test -tags interleave -run 'TestInterleavingSnapshot/%v$' -interleave.seed=%d",
+								values, k, seed,
+							)
+						}
+
+						last[w] = j
+					}
+				}
+			}
+		})
+	}
+}
+
+// TestInterleavingVersions pins versions of MVCC set and reads them while the writers update the set and
+// the collector unlinks old versions: reading a pinned version once more after quiescence must give the same values.
+func TestInterleavingVersions(t *testing.T) {
+	const (
+		writers = 2
+		items   = 3
+		reads   = 3
+	)
+
+	base := time.Now().UnixNano()
+	if *interleaveSeed != 0 {
+		base = *interleaveSeed
+	}
+
+	for i := 0; i < *interleaveIterations; i++ {
+		seed := base + int64(i)
+		set := NewMVCCSet()
+
+		var (
+			pinned []uint64
+			read   [][]int
+		)
+
+		fns := make([]func(), 0, writers+2)
+
+		for w := 0; w < writers; w++ {
+			w := w
+
+			fns = append(fns, func() {
+				for j := 0; j < items; j++ {
+					set.Insert(j*writers + w)
+					set.Remove((j+1)%items*writers + w)
+				}
+			})
+		}
+
+		fns = append(fns,
+			func() {
+				for j := 0; j < reads; j++ {
+					version := set.Pin()
+					values, err := set.RangeAt(version)
+					require.NoError(t, err)
+
+					pinned = append(pinned, version)
+					read = append(read, values)
+				}
+			},
+			func() {
+				for j := 0; j < reads; j++ {
+					set.Collect()
+				}
+			},
+		)
+
+		require.NoError(t, newScheduler(seed).run(fns))
+
+		for j, version := range pinned {
+			values, err := set.RangeAt(version)
+			require.NoError(t, err)
+
+			if !reflect.DeepEqual(values, read[j]) {
+				t.Fatalf(
+					"version %d read as %v and then as %v\nreplay: go test -tags interleave -run 'TestInterleavingVersions$' -interleave.seed=%d",
+					version, read[j], values, seed,
+				)
+			}
+
+			set.Unpin(version)
+		}
+	}
+}
@@ -0,0 +1,231 @@
+// Package epoch implements epoch-based memory reclamation (EBR).
+//
+// Go has garbage collector, so "reclamation" here means recycling: an object removed from a concurrent
+// data structure can't be reused immediately, because the other goroutines may still traverse it.
+// Every operation pins a Guard, which publishes the global epoch observed by the operation.
+// Removed objects are retired into the limbo list of the Guard, tagged with the current epoch.
+// The global epoch advances only when every pinned Guard has observed it, so the objects retired
+// two epochs ago can't be referenced by anyone and are moved to the free list of the Guard.
+//
+// Objects of different types are kept apart by classes: every class has its own free list.
+package epoch
+
+import (
+	"math/bits"
+	"sync"
+	"sync/atomic"
+	"time"
+)
+
+const (
+	// number of limbo lists: objects retired in epoch e are safe in epoch e+2.
+	limboLists = 3
+	// every advanceInterval-th Unpin tries to advance the global epoch.
+	advanceInterval = 16
+	// DefaultFreeListSize limits the number of objects in free and limbo lists of a single Guard.
+	DefaultFreeListSize = 4096
+)
+
+// Domain is a reclamation domain, usually one per data structure instance.
+type Domain struct {
+	guards       atomic.Value // []*Guard
+	mutex        sync.Mutex   // serializes registration of new guards
+	classes      int
+	freeListSize int
+	_            [64]byte // keep the global epoch away from the fields above
+	epoch        uint64
+	_            [56]byte
+}
+
+// Guard is a participant of the reclamation protocol. A Guard is owned exclusively
+// by a single goroutine between Domain.Pin and Guard.Unpin.
+type Guard struct {
+	_      [64]byte
+	domain *Domain
+	// local is an epoch observed by the owner shifted left by one bit; the lowest bit is set while pinned.
+	local      uint64
+	inUse      uint32
+	unpins     uint32
+	limbo      [limboLists][]retired
+	limboEpoch [limboLists]uint64
+	free       [][]interface{} // per class
+	_          [64]byte
+}
+
+type retired struct {
+	obj   interface{}
+	class int
+}
+
+// NewDomain creates reclamation domain for the given number of object classes.
+// freeListSize limits the number of objects cached by every Guard in the every class;
+// non-positive value means DefaultFreeListSize.
+func NewDomain(classes, freeListSize int) *Domain {
+	if classes <= 0 {
+		classes = 1
+	}
+
+	if freeListSize <= 0 {
+		freeListSize = DefaultFreeListSize
+	}
+
+	d := &Domain{classes: classes, freeListSize: freeListSize}
+	d.guards.Store([]*Guard(nil))
+
+	return d
+}
+
+// Epoch returns current global epoch.
+func (d *Domain) Epoch() uint64 {
+	return atomic.LoadUint64(&d.epoch)
+}
+
+// Pin claims a Guard and marks it as active in the current epoch. Objects reachable from the
+// data structure at this moment won't be recycled until Unpin is called.
+// Pin is safe to call on nil Domain: it returns nil Guard, and all Guard methods are no-op for nil.
+func (d *Domain) Pin() *Guard {
+	if d == nil {
+		return nil
+	}
+
+	g := d.claim()
+
+	e := atomic.LoadUint64(&d.epoch)
+	atomic.StoreUint64(&g.local, e<<1|1)
+
+	g.collect(e)
+
+	return g
+}
+
+// claim finds a free Guard or registers a new one.
+func (d *Domain) claim() *Guard {
+	guards, _ := d.guards.Load().([]*Guard)
+
+	if n := len(guards); n > 0 {
+		// start from a pseudo-random position to spread goroutines across the guards
+		start := int(mix(uint64(time.Now().UnixNano())) % uint64(n))
+
+		for i := 0; i < n; i++ {
+			g := guards[(start+i)%n]
+			if atomic.CompareAndSwapUint32(&g.inUse, 0, 1) {
+				return g
+			}
+		}
+	}
+
+	g := &Guard{domain: d, inUse: 1, free: make([][]interface{}, d.classes)}
+
+	d.mutex.Lock()
+	guards, _ = d.guards.Load().([]*Guard)
+	extended := make([]*Guard, len(guards), len(guards)+1)
+	copy(extended, guards)
+	d.guards.Store(append(extended, g))
+	d.mutex.Unlock()
+
+	return g
+}
+
+// tryAdvance increments the global epoch if every pinned Guard has already observed it.
+func (d *Domain) tryAdvance() bool {
+	e := atomic.LoadUint64(&d.epoch)
+	guards, _ := d.guards.Load().([]*Guard)
+
+	for _, g := range guards {
+		local := atomic.LoadUint64(&g.local)
+		if local&1 == 1 && local>>1 != e {
+			return false
+		}
+	}
+
+	return atomic.CompareAndSwapUint64(&d.epoch, e, e+1)
+}
+
+// Unpin releases the Guard. The Guard must not be used after Unpin.
+func (g *Guard) Unpin() {
+	if g == nil {
+		return
+	}
+
+	atomic.StoreUint64(&g.local, 0)
+
+	g.unpins++
+	if g.unpins%advanceInterval == 0 {
+		g.domain.tryAdvance()
+	}
+
+	atomic.StoreUint32(&g.inUse, 0)
+}
+
+// Retire puts the object that has just been unlinked from the data structure into the limbo list.
+// The object will become available via Reuse with the same class after the grace period.
+func (g *Guard) Retire(class int, obj interface{}) {
+	if g == nil {
+		return
+	}
+
+	e := atomic.LoadUint64(&g.domain.epoch)
+	ix := e % limboLists
+
+	// the list contains objects retired at least limboLists epochs ago, they are safe already
+	if g.limboEpoch[ix] != e {
+		g.release(ix)
+		g.limboEpoch[ix] = e
+	}
+
+	// a stalled reader blocks the epoch, so the limbo list may grow unboundedly;
+	// it's always safe to leave the rest of the objects to the garbage collector
+	if len(g.limbo[ix]) < g.domain.freeListSize*g.domain.classes {
+		g.limbo[ix] = append(g.limbo[ix], retired{obj: obj, class: class})
+	}
+}
+
+// Reuse returns a recycled object of the given class or nil if the free list is empty.
+func (g *Guard) Reuse(class int) interface{} {
+	if g == nil {
+		return nil
+	}
+
+	free := g.free[class]
+
+	n := len(free)
+	if n == 0 {
+		return nil
+	}
+
+	obj := free[n-1]
+	free[n-1] = nil
+	g.free[class] = free[:n-1]
+
+	return obj
+}
+
+// collect moves the objects whose grace period has expired from limbo lists to the free list.
+func (g *Guard) collect(e uint64) {
+	for ix := range g.limbo {
+		if len(g.limbo[ix]) > 0 && g.limboEpoch[ix]+2 <= e {
+			g.release(uint64(ix))
+		}
+	}
+}
+
+func (g *Guard) release(ix uint64) {
+	for i, r := range g.limbo[ix] {
+		if len(g.free[r.class]) < g.domain.freeListSize {
+			g.free[r.class] = append(g.free[r.class], r.obj)
+		}
+
+		g.limbo[ix][i] = retired{}
+	}
+
+	g.limbo[ix] = g.limbo[ix][:0]
+}
+
+// mix is a cheap stateless hash used to pick a starting position.
+func mix(x uint64) uint64 {
+	x ^= x >> 33
+	x *= 0xff51afd7ed558ccd
+	x ^= x >> 33
+
+	return bits.RotateLeft64(x, 17)
+}
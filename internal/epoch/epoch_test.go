@@ -0,0 +1,151 @@
+package epoch
+
+import (
+	"sync"
+	"sync/atomic"
+	"testing"
+	"unsafe"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestNilDomain(t *testing.T) {
+	var d *Domain
+
+	g := d.Pin()
+	require.Nil(t, g)
+
+	g.Retire(0, 1)
+	require.Nil(t, g.Reuse(0))
+	g.Unpin()
+}
+
+func TestGracePeriod(t *testing.T) {
+	d := NewDomain(1, 0)
+
+	reader := d.Pin()
+
+	writer := d.Pin()
+	obj := new(int)
+	writer.Retire(0, obj)
+	writer.Unpin()
+
+	// the epoch can't advance twice while the reader is pinned in the old one
+	for i := 0; i < 10; i++ {
+		d.tryAdvance()
+	}
+
+	require.Equal(t, uint64(1), d.Epoch())
+
+	writer = d.Pin()
+	require.Nil(t, writer.Reuse(0))
+	writer.Unpin()
+
+	reader.Unpin()
+
+	require.True(t, d.tryAdvance())
+	require.True(t, d.tryAdvance())
+
+	// the object is available only to the guard that retired it;
+	// pin every registered guard to reach it
+	var found bool
+
+	guards := []*Guard{d.Pin(), d.Pin()}
+	for _, g := range guards {
+		if g.Reuse(0) == obj {
+			found = true
+		}
+	}
+
+	for _, g := range guards {
+		g.Unpin()
+	}
+
+	require.True(t, found)
+}
+
+func TestFreeListSize(t *testing.T) {
+	d := NewDomain(2, 2)
+
+	g := d.Pin()
+	for i := 0; i < 5; i++ {
+		g.Retire(i%2, i)
+	}
+	g.Unpin()
+
+	require.True(t, d.tryAdvance())
+	require.True(t, d.tryAdvance())
+
+	g = d.Pin()
+	defer g.Unpin()
+
+	// the objects of every class are kept apart
+	require.Equal(t, 3, g.Reuse(1))
+	require.Equal(t, 1, g.Reuse(1))
+	require.Nil(t, g.Reuse(1))
+	require.Equal(t, 2, g.Reuse(0))
+	require.Equal(t, 0, g.Reuse(0))
+	require.Nil(t, g.Reuse(0))
+}
+
+// TestConcurrentRecycling checks that an object is never recycled while a pinned reader holds it.
+func TestConcurrentRecycling(t *testing.T) {
+	const (
+		threads    = 8
+		iterations = 20000
+	)
+
+	type object struct {
+		generation uint64
+	}
+
+	d := NewDomain(1, 0)
+
+	shared := unsafe.Pointer(&object{})
+
+	var violations uint64
+
+	wg := sync.WaitGroup{}
+	wg.Add(threads)
+
+	for i := 0; i < threads; i++ {
+		i := i
+
+		go func() {
+			defer wg.Done()
+
+			for j := 0; j < iterations; j++ {
+				g := d.Pin()
+
+				obj := (*object)(atomic.LoadPointer(&shared))
+				before := atomic.LoadUint64(&obj.generation)
+
+				// half of the goroutines replace the shared object
+				if i%2 == 0 {
+					next, ok := g.Reuse(0).(*object)
+					if !ok {
+						next = &object{}
+					}
+
+					// recycling bumps the generation
+					atomic.AddUint64(&next.generation, 1)
+
+					if atomic.CompareAndSwapPointer(&shared, unsafe.Pointer(obj), unsafe.Pointer(next)) {
+						g.Retire(0, obj)
+					}
+				}
+
+				if atomic.LoadUint64(&obj.generation) != before {
+					atomic.AddUint64(&violations, 1)
+				}
+
+				g.Unpin()
+			}
+		}()
+	}
+
+	wg.Wait()
+
+	require.Zero(t, violations)
+	require.Positive(t, d.Epoch())
+}
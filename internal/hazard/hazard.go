@@ -0,0 +1,212 @@
+// Package hazard implements hazard-pointer based memory reclamation.
+//
+// Like package epoch, it is used to recycle objects removed from concurrent data structures,
+// but instead of the global epoch every participant publishes the exact objects it is going to
+// dereference in a small number of hazard slots. A retired object is recycled once none of the
+// slots holds it, so a descheduled reader can delay the reuse of a few objects only,
+// and never stalls reclamation as a whole.
+package hazard
+
+import (
+	"math/bits"
+	"sync"
+	"sync/atomic"
+	"time"
+	"unsafe"
+)
+
+// DefaultFreeListSize limits the number of recycled objects of every class cached by a single Record.
+const DefaultFreeListSize = 1024
+
+// Domain is a reclamation domain, usually one per data structure instance.
+type Domain struct {
+	records       atomic.Value // []*Record
+	mutex         sync.Mutex   // serializes registration of new records
+	slots         int
+	classes       int
+	scanThreshold int
+	freeListSize  int
+}
+
+// Record is a participant of the protocol: it owns hazard slots, the list of retired objects and free lists.
+// A Record is owned exclusively by a single goroutine between Domain.Acquire and Record.Release.
+type Record struct {
+	_       [64]byte
+	domain  *Domain
+	hazards []unsafe.Pointer
+	inUse   uint32
+	retired []retired
+	free    [][]unsafe.Pointer // per class
+	scratch map[unsafe.Pointer]struct{}
+	_       [64]byte
+}
+
+type retired struct {
+	ptr   unsafe.Pointer
+	class int
+}
+
+// NewDomain creates reclamation domain with the given number of hazard slots per participant
+// and object classes. Retired objects are scanned when their number reaches scanThreshold
+// (or twice as much as the total number of hazard slots, whichever is greater).
+// freeListSize limits free lists; non-positive value means DefaultFreeListSize.
+func NewDomain(slots, classes, scanThreshold, freeListSize int) *Domain {
+	if classes <= 0 {
+		classes = 1
+	}
+
+	if freeListSize <= 0 {
+		freeListSize = DefaultFreeListSize
+	}
+
+	d := &Domain{
+		slots:         slots,
+		classes:       classes,
+		scanThreshold: scanThreshold,
+		freeListSize:  freeListSize,
+	}
+	d.records.Store([]*Record(nil))
+
+	return d
+}
+
+// Acquire claims a Record for the calling goroutine.
+func (d *Domain) Acquire() *Record {
+	records, _ := d.records.Load().([]*Record)
+
+	if n := len(records); n > 0 {
+		// start from a pseudo-random position to spread goroutines across the records
+		start := int(mix(uint64(time.Now().UnixNano())) % uint64(n))
+
+		for i := 0; i < n; i++ {
+			r := records[(start+i)%n]
+			if atomic.CompareAndSwapUint32(&r.inUse, 0, 1) {
+				return r
+			}
+		}
+	}
+
+	r := &Record{
+		domain:  d,
+		hazards: make([]unsafe.Pointer, d.slots),
+		inUse:   1,
+		free:    make([][]unsafe.Pointer, d.classes),
+		scratch: make(map[unsafe.Pointer]struct{}),
+	}
+
+	d.mutex.Lock()
+	records, _ = d.records.Load().([]*Record)
+	extended := make([]*Record, len(records), len(records)+1)
+	copy(extended, records)
+	d.records.Store(append(extended, r))
+	d.mutex.Unlock()
+
+	return r
+}
+
+// Protect publishes the pointer in the hazard slot. The caller must check that the object
+// is still reachable after Protect returns: only then it is guaranteed not to be recycled.
+func (r *Record) Protect(slot int, ptr unsafe.Pointer) {
+	atomic.StorePointer(&r.hazards[slot], ptr)
+}
+
+// Clear empties the hazard slot.
+func (r *Record) Clear(slot int) {
+	atomic.StorePointer(&r.hazards[slot], nil)
+}
+
+// Release clears all hazard slots and returns the Record to the domain.
+// The Record must not be used after Release.
+func (r *Record) Release() {
+	for i := range r.hazards {
+		r.Clear(i)
+	}
+
+	atomic.StoreUint32(&r.inUse, 0)
+}
+
+// Retire puts the object that has just been unlinked from the data structure into the retire list.
+// The object will become available via Reuse with the same class once no hazard slot holds it.
+func (r *Record) Retire(class int, ptr unsafe.Pointer) {
+	r.retired = append(r.retired, retired{ptr: ptr, class: class})
+
+	if len(r.retired) >= r.threshold() {
+		r.Scan()
+	}
+}
+
+// Reuse returns a recycled object of the given class or nil if the free list is empty.
+func (r *Record) Reuse(class int) unsafe.Pointer {
+	free := r.free[class]
+
+	n := len(free)
+	if n == 0 {
+		return nil
+	}
+
+	ptr := free[n-1]
+	free[n-1] = nil
+	r.free[class] = free[:n-1]
+
+	return ptr
+}
+
+// Scan moves retired objects that are not protected by any hazard slot to the free lists.
+func (r *Record) Scan() {
+	records, _ := r.domain.records.Load().([]*Record)
+
+	for _, other := range records {
+		for i := range other.hazards {
+			if ptr := atomic.LoadPointer(&other.hazards[i]); ptr != nil {
+				r.scratch[ptr] = struct{}{}
+			}
+		}
+	}
+
+	kept := r.retired[:0]
+
+	for _, rt := range r.retired {
+		switch _, hazardous := r.scratch[rt.ptr]; {
+		case hazardous:
+			kept = append(kept, rt)
+		case len(r.free[rt.class]) < r.domain.freeListSize:
+			r.free[rt.class] = append(r.free[rt.class], rt.ptr)
+		default:
+			// free list is full: leave the object to the garbage collector
+		}
+	}
+
+	for i := len(kept); i < len(r.retired); i++ {
+		r.retired[i] = retired{}
+	}
+
+	r.retired = kept
+
+	for ptr := range r.scratch {
+		delete(r.scratch, ptr)
+	}
+}
+
+// Retired returns the number of objects waiting for the scan.
+func (r *Record) Retired() int {
+	return len(r.retired)
+}
+
+func (r *Record) threshold() int {
+	records, _ := r.domain.records.Load().([]*Record)
+
+	if t := 2 * r.domain.slots * len(records); t > r.domain.scanThreshold {
+		return t
+	}
+
+	return r.domain.scanThreshold
+}
+
+// mix is a cheap stateless hash used to pick a starting position.
+func mix(x uint64) uint64 {
+	x ^= x >> 33
+	x *= 0xff51afd7ed558ccd
+	x ^= x >> 33
+
+	return bits.RotateLeft64(x, 17)
+}
@@ -0,0 +1,147 @@
+package hazard
+
+import (
+	"sync"
+	"sync/atomic"
+	"testing"
+	"unsafe"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestProtect(t *testing.T) {
+	d := NewDomain(1, 1, 0, 0)
+
+	reader := d.Acquire()
+	writer := d.Acquire()
+
+	protected, unprotected := new(int), new(int)
+
+	reader.Protect(0, unsafe.Pointer(protected))
+
+	writer.Retire(0, unsafe.Pointer(protected))
+	writer.Retire(0, unsafe.Pointer(unprotected))
+	writer.Scan()
+
+	require.Equal(t, 1, writer.Retired())
+	require.Equal(t, unsafe.Pointer(unprotected), writer.Reuse(0))
+	require.Equal(t, unsafe.Pointer(nil), writer.Reuse(0))
+
+	reader.Release()
+	writer.Scan()
+
+	require.Zero(t, writer.Retired())
+	require.Equal(t, unsafe.Pointer(protected), writer.Reuse(0))
+
+	writer.Release()
+}
+
+func TestScanThreshold(t *testing.T) {
+	const threshold = 8
+
+	d := NewDomain(1, 1, threshold, 0)
+
+	r := d.Acquire()
+	defer r.Release()
+
+	for i := 0; i < threshold-1; i++ {
+		r.Retire(0, unsafe.Pointer(new(int)))
+	}
+
+	require.Equal(t, threshold-1, r.Retired())
+
+	// reaching threshold triggers the scan
+	r.Retire(0, unsafe.Pointer(new(int)))
+	require.Zero(t, r.Retired())
+}
+
+func TestFreeListSize(t *testing.T) {
+	d := NewDomain(1, 2, 0, 1)
+
+	r := d.Acquire()
+	defer r.Release()
+
+	for i := 0; i < 4; i++ {
+		r.Retire(i%2, unsafe.Pointer(new(int)))
+	}
+
+	r.Scan()
+
+	require.NotNil(t, r.Reuse(0))
+	require.Equal(t, unsafe.Pointer(nil), r.Reuse(0))
+	require.NotNil(t, r.Reuse(1))
+	require.Equal(t, unsafe.Pointer(nil), r.Reuse(1))
+}
+
+// TestConcurrentRecycling checks that a protected object is never recycled.
+func TestConcurrentRecycling(t *testing.T) {
+	const (
+		threads    = 8
+		iterations = 20000
+	)
+
+	type object struct {
+		generation uint64
+	}
+
+	d := NewDomain(1, 1, 0, 0)
+
+	shared := unsafe.Pointer(&object{})
+
+	var violations uint64
+
+	wg := sync.WaitGroup{}
+	wg.Add(threads)
+
+	for i := 0; i < threads; i++ {
+		i := i
+
+		go func() {
+			defer wg.Done()
+
+			r := d.Acquire()
+			defer r.Release()
+
+			for j := 0; j < iterations; j++ {
+				// protect-and-validate loop
+				var ptr unsafe.Pointer
+				for {
+					ptr = atomic.LoadPointer(&shared)
+					r.Protect(0, ptr)
+
+					if atomic.LoadPointer(&shared) == ptr {
+						break
+					}
+				}
+
+				obj := (*object)(ptr)
+				before := atomic.LoadUint64(&obj.generation)
+
+				// half of the goroutines replace the shared object
+				if i%2 == 0 {
+					next := (*object)(r.Reuse(0))
+					if next == nil {
+						next = &object{}
+					}
+
+					// recycling bumps the generation
+					atomic.AddUint64(&next.generation, 1)
+
+					if atomic.CompareAndSwapPointer(&shared, ptr, unsafe.Pointer(next)) {
+						r.Retire(0, ptr)
+					}
+				}
+
+				if atomic.LoadUint64(&obj.generation) != before {
+					atomic.AddUint64(&violations, 1)
+				}
+
+				r.Clear(0)
+			}
+		}()
+	}
+
+	wg.Wait()
+
+	require.Zero(t, violations)
+}
@@ -0,0 +1,171 @@
+// Package histogram implements a latency histogram in the spirit of HdrHistogram:
+// buckets grow exponentially, and every power of two is split into linear sub-buckets,
+// so the relative error of every recorded value is below 1/64 over the whole int64 range.
+//
+// A histogram is not safe for concurrent use: every goroutine records into its own one,
+// and the histograms are merged when the measurement is over.
+package histogram
+
+import (
+	"math"
+	"math/bits"
+	"time"
+)
+
+const (
+	// subBucketBits defines the precision: values below 2^subBucketBits are exact.
+	subBucketBits = 7
+	subBuckets    = 1 << subBucketBits
+	halfBuckets   = subBuckets / 2
+	// the highest exponent is reached by math.MaxInt64
+	maxShift   = 63 - subBucketBits
+	numBuckets = maxShift*halfBuckets + subBuckets
+)
+
+// Histogram counts non-negative values.
+type Histogram struct {
+	counts [numBuckets]int64
+	total  int64
+	sum    int64
+	min    int64
+	max    int64
+}
+
+// New returns an empty histogram.
+func New() *Histogram {
+	return &Histogram{}
+}
+
+// bucketIndex maps the value to its bucket: the values below subBuckets get a bucket each,
+// the rest keep subBucketBits significant bits.
+func bucketIndex(v int64) int {
+	if v < subBuckets {
+		return int(v)
+	}
+
+	shift := bits.Len64(uint64(v)) - subBucketBits
+
+	return shift*halfBuckets + int(v>>shift)
+}
+
+// highestEquivalentValue is the largest value that falls into the bucket.
+func highestEquivalentValue(ix int) int64 {
+	if ix < subBuckets {
+		return int64(ix)
+	}
+
+	shift := (ix - halfBuckets) / halfBuckets
+	mantissa := int64(ix - shift*halfBuckets)
+
+	return (mantissa+1)<<shift - 1
+}
+
+// Record adds the duration; negative durations are recorded as zero.
+func (h *Histogram) Record(d time.Duration) {
+	h.RecordValue(int64(d))
+}
+
+// RecordValue adds the value; negative values are recorded as zero.
+func (h *Histogram) RecordValue(v int64) {
+	if v < 0 {
+		v = 0
+	}
+
+	h.counts[bucketIndex(v)]++
+
+	if h.total == 0 || v < h.min {
+		h.min = v
+	}
+
+	if v > h.max {
+		h.max = v
+	}
+
+	h.total++
+	h.sum += v
+}
+
+// Merge adds all values of the other histogram.
+func (h *Histogram) Merge(other *Histogram) {
+	if other.total == 0 {
+		return
+	}
+
+	for i, c := range other.counts {
+		h.counts[i] += c
+	}
+
+	if h.total == 0 || other.min < h.min {
+		h.min = other.min
+	}
+
+	if other.max > h.max {
+		h.max = other.max
+	}
+
+	h.total += other.total
+	h.sum += other.sum
+}
+
+// Count returns the number of recorded values.
+func (h *Histogram) Count() int64 {
+	return h.total
+}
+
+// Min returns the exact minimal value.
+func (h *Histogram) Min() int64 {
+	return h.min
+}
+
+// Max returns the exact maximal value.
+func (h *Histogram) Max() int64 {
+	return h.max
+}
+
+// Mean returns the exact average value.
+func (h *Histogram) Mean() float64 {
+	if h.total == 0 {
+		return 0
+	}
+
+	return float64(h.sum) / float64(h.total)
+}
+
+// ValueAtQuantile returns the value below or equal to which the share q of values falls, q in [0, 1].
+// The result is the upper bound of the bucket, but never exceeds the maximal value.
+func (h *Histogram) ValueAtQuantile(q float64) int64 {
+	if h.total == 0 {
+		return 0
+	}
+
+	if q >= 1 {
+		return h.max
+	}
+
+	// the rank of the value is rounded up: the median of three values is the second one
+	rank := int64(math.Ceil(q * float64(h.total)))
+	if rank < 1 {
+		rank = 1
+	}
+
+	var seen int64
+
+	for i, c := range h.counts {
+		seen += c
+
+		if seen >= rank {
+			if v := highestEquivalentValue(i); v < h.max {
+				return v
+			}
+
+			return h.max
+		}
+	}
+
+	return h.max
+}
+
+// Reset drops all values.
+func (h *Histogram) Reset() {
+	*h = Histogram{}
+}
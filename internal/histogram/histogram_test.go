@@ -0,0 +1,101 @@
+package histogram
+
+import (
+	"math"
+	"math/rand"
+	"sort"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestBuckets(t *testing.T) {
+	// buckets are contiguous, and every value falls into the bucket bounded by its highest equivalent value
+	values := []int64{0, 1, 63, 127, 128, 129, 255, 256, 1000, 1 << 20, 1<<40 + 12345, math.MaxInt64}
+
+	for _, v := range values {
+		ix := bucketIndex(v)
+		require.Less(t, ix, numBuckets, v)
+		require.GreaterOrEqual(t, highestEquivalentValue(ix), v, v)
+
+		if ix > 0 {
+			require.Less(t, highestEquivalentValue(ix-1), v, v)
+		}
+	}
+
+	for ix := 1; ix < numBuckets; ix++ {
+		require.Equal(t, ix, bucketIndex(highestEquivalentValue(ix-1)+1), ix)
+	}
+}
+
+func TestQuantiles(t *testing.T) {
+	h := New()
+	rng := rand.New(rand.NewSource(1))
+
+	values := make([]int64, 100000)
+	for i := range values {
+		// log-uniform between 1ns and 1s
+		values[i] = int64(math.Exp(rng.Float64() * math.Log(1e9)))
+		h.RecordValue(values[i])
+	}
+
+	sort.Slice(values, func(i, j int) bool { return values[i] < values[j] })
+
+	require.Equal(t, int64(len(values)), h.Count())
+	require.Equal(t, values[0], h.Min())
+	require.Equal(t, values[len(values)-1], h.Max())
+	require.Equal(t, h.Max(), h.ValueAtQuantile(1))
+
+	for _, q := range []float64{0.5, 0.9, 0.99, 0.999} {
+		exact := float64(values[int(math.Ceil(q*float64(len(values))))-1])
+		require.InEpsilon(t, exact, float64(h.ValueAtQuantile(q)), 1.0/64, q)
+	}
+}
+
+func TestQuantilesSmall(t *testing.T) {
+	h := New()
+
+	for _, v := range []int64{1, 2, 3} {
+		h.RecordValue(v)
+	}
+
+	require.Equal(t, int64(1), h.ValueAtQuantile(0))
+	require.Equal(t, int64(1), h.ValueAtQuantile(0.3))
+	require.Equal(t, int64(2), h.ValueAtQuantile(0.5))
+	require.Equal(t, int64(3), h.ValueAtQuantile(0.9))
+
+	h.Reset()
+
+	for v := int64(1); v <= 10; v++ {
+		h.RecordValue(v)
+	}
+
+	for q, expected := range map[float64]int64{0.1: 1, 0.5: 5, 0.55: 6, 0.9: 9, 0.95: 10, 0.99: 10, 1: 10} {
+		require.Equal(t, expected, h.ValueAtQuantile(q), q)
+	}
+}
+
+func TestMerge(t *testing.T) {
+	whole, parts := New(), []*Histogram{New(), New(), New()}
+
+	for i := 0; i < 3000; i++ {
+		d := time.Duration(i*i) * time.Nanosecond
+		whole.Record(d)
+		parts[i%len(parts)].Record(d)
+	}
+
+	merged := New()
+	merged.Merge(New())
+
+	for _, p := range parts {
+		merged.Merge(p)
+	}
+
+	require.Equal(t, whole, merged)
+
+	merged.Reset()
+	require.Zero(t, merged.Count())
+	require.Zero(t, merged.ValueAtQuantile(0.5))
+	require.Zero(t, merged.Mean())
+}
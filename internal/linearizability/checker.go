@@ -0,0 +1,199 @@
+package linearizability
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+)
+
+// Error describes the partition of history that has no valid linearization.
+type Error struct {
+	Operations History
+	Key        int
+}
+
+func (e *Error) Error() string {
+	lines := make([]string, 0, len(e.Operations)+1)
+	lines = append(lines, fmt.Sprintf("history of key %d is not linearizable:", e.Key))
+
+	for _, op := range e.Operations {
+		lines = append(lines, "\t"+op.String())
+	}
+
+	return strings.Join(lines, "\n")
+}
+
+// Check verifies that the history is linearizable with respect to the sequential specification of set.
+// Operations on different keys commute, so the history is partitioned per key, and every partition
+// is checked separately with Wing & Gong search improved by Lowe's memoization of visited configurations.
+func Check(h History) error {
+	partitions := make(map[int]History)
+
+	for _, op := range h {
+		partitions[op.Key] = append(partitions[op.Key], op)
+	}
+
+	keys := make([]int, 0, len(partitions))
+	for key := range partitions {
+		keys = append(keys, key)
+	}
+
+	sort.Ints(keys)
+
+	for _, key := range keys {
+		if !checkPartition(partitions[key]) {
+			return &Error{Key: key, Operations: partitions[key]}
+		}
+	}
+
+	return nil
+}
+
+// apply executes the operation on the sequential specification of set containing a single key:
+// it returns new state or false if the result of the operation is impossible in the given state.
+func apply(present bool, op *Operation) (bool, bool) {
+	switch op.Kind {
+	case Insert:
+		return true, op.Result == !present
+	case Remove:
+		return false, op.Result == present
+	case Contains:
+		return present, op.Result == present
+	default:
+		panic("unknown Kind")
+	}
+}
+
+// entry is either invocation or response event; events form a doubly-linked list ordered by time.
+type entry struct {
+	prev, next *entry
+	match      *entry // response of the invocation
+	time       int64
+	id         int
+	call       bool
+}
+
+func buildEntries(ops History) *entry {
+	entries := make([]*entry, 0, 2*len(ops))
+
+	for i, op := range ops {
+		call := &entry{id: i, time: op.Call, call: true}
+		ret := &entry{id: i, time: op.Return}
+		call.match = ret
+		entries = append(entries, call, ret)
+	}
+
+	sort.Slice(entries, func(i, j int) bool { return entries[i].time < entries[j].time })
+
+	head := &entry{}
+	prev := head
+
+	for _, e := range entries {
+		prev.next = e
+		e.prev = prev
+		prev = e
+	}
+
+	return head
+}
+
+// lift removes the invocation and its response from the list.
+func lift(e *entry) {
+	e.prev.next = e.next
+	e.next.prev = e.prev
+
+	ret := e.match
+	ret.prev.next = ret.next
+
+	if ret.next != nil {
+		ret.next.prev = ret.prev
+	}
+}
+
+// unlift restores the invocation and its response.
+func unlift(e *entry) {
+	ret := e.match
+	ret.prev.next = ret
+
+	if ret.next != nil {
+		ret.next.prev = ret
+	}
+
+	e.prev.next = e
+	e.next.prev = e
+}
+
+type bitset []uint64
+
+func (b bitset) set(i int)   { b[i/64] |= 1 << (uint(i) % 64) }
+func (b bitset) clear(i int) { b[i/64] &^= 1 << (uint(i) % 64) }
+
+// key identifies configuration: the set of linearized operations and the resulting state.
+func (b bitset) key(present bool) string {
+	var sb strings.Builder
+
+	for _, word := range b {
+		fmt.Fprintf(&sb, "%x.", word)
+	}
+
+	fmt.Fprint(&sb, present)
+
+	return sb.String()
+}
+
+func checkPartition(ops History) bool {
+	type frame struct {
+		e       *entry
+		present bool
+	}
+
+	var (
+		head       = buildEntries(ops)
+		linearized = make(bitset, (len(ops)+63)/64)
+		visited    = make(map[string]struct{})
+		stack      []frame
+		present    bool
+	)
+
+	e := head.next
+
+	for head.next != nil {
+		if !e.call {
+			// some pending operation must have been linearized before this response: backtrack
+			if len(stack) == 0 {
+				return false
+			}
+
+			top := stack[len(stack)-1]
+			stack = stack[:len(stack)-1]
+			present = top.present
+			linearized.clear(top.e.id)
+			unlift(top.e)
+			e = top.e.next
+
+			continue
+		}
+
+		next, ok := apply(present, &ops[e.id])
+		if ok {
+			linearized.set(e.id)
+
+			key := linearized.key(next)
+			if _, seen := visited[key]; !seen {
+				visited[key] = struct{}{}
+				stack = append(stack, frame{e: e, present: present})
+				present = next
+				lift(e)
+				e = head.next
+
+				continue
+			}
+
+			linearized.clear(e.id)
+		}
+
+		e = e.next
+	}
+
+	return true
+}
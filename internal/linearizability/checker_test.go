@@ -0,0 +1,159 @@
+package linearizability
+
+import (
+	"errors"
+	"sync"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestCheck(t *testing.T) {
+	testCases := []struct {
+		name         string
+		history      History
+		linearizable bool
+	}{
+		{
+			name: "sequential",
+			history: History{
+				{Kind: Insert, Key: 1, Result: true, Call: 1, Return: 2},
+				{Kind: Contains, Key: 1, Result: true, Call: 3, Return: 4},
+				{Kind: Remove, Key: 1, Result: true, Call: 5, Return: 6},
+				{Kind: Contains, Key: 1, Result: false, Call: 7, Return: 8},
+			},
+			linearizable: true,
+		},
+		{
+			name: "concurrent insertions, one wins",
+			history: History{
+				{Client: 0, Kind: Insert, Key: 1, Result: false, Call: 1, Return: 4},
+				{Client: 1, Kind: Insert, Key: 1, Result: true, Call: 2, Return: 3},
+			},
+			linearizable: true,
+		},
+		{
+			name: "concurrent insertions, both win",
+			history: History{
+				{Client: 0, Kind: Insert, Key: 1, Result: true, Call: 1, Return: 4},
+				{Client: 1, Kind: Insert, Key: 1, Result: true, Call: 2, Return: 3},
+			},
+			linearizable: false,
+		},
+		{
+			name: "stale read after completed removal",
+			history: History{
+				{Client: 0, Kind: Insert, Key: 1, Result: true, Call: 1, Return: 2},
+				{Client: 0, Kind: Remove, Key: 1, Result: true, Call: 3, Return: 4},
+				{Client: 1, Kind: Contains, Key: 1, Result: true, Call: 5, Return: 6},
+			},
+			linearizable: false,
+		},
+		{
+			name: "read overlapping removal",
+			history: History{
+				{Client: 0, Kind: Insert, Key: 1, Result: true, Call: 1, Return: 2},
+				{Client: 0, Kind: Remove, Key: 1, Result: true, Call: 3, Return: 6},
+				{Client: 1, Kind: Contains, Key: 1, Result: true, Call: 4, Return: 5},
+			},
+			linearizable: true,
+		},
+		{
+			name: "keys are independent",
+			history: History{
+				{Client: 0, Kind: Insert, Key: 1, Result: true, Call: 1, Return: 2},
+				{Client: 1, Kind: Remove, Key: 2, Result: true, Call: 3, Return: 4},
+			},
+			linearizable: false,
+		},
+	}
+
+	for _, tc := range testCases {
+		tc := tc
+
+		t.Run(tc.name, func(t *testing.T) {
+			err := Check(tc.history)
+			if tc.linearizable {
+				require.NoError(t, err)
+
+				return
+			}
+
+			var linErr *Error
+
+			require.True(t, errors.As(err, &linErr))
+			require.NotEmpty(t, linErr.Operations)
+		})
+	}
+}
+
+// brokenSet returns wrong results from time to time.
+type brokenSet struct {
+	mutex sync.Mutex
+	items map[int]struct{}
+	calls int
+}
+
+func (s *brokenSet) Insert(value int) bool {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+
+	s.calls++
+
+	_, exists := s.items[value]
+	s.items[value] = struct{}{}
+
+	// lie every 7th call
+	return !exists || s.calls%7 == 0
+}
+
+func (s *brokenSet) Contains(value int) bool {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+
+	_, exists := s.items[value]
+
+	return exists
+}
+
+func (s *brokenSet) Remove(value int) bool {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+
+	_, exists := s.items[value]
+	delete(s.items, value)
+
+	return exists
+}
+
+func TestRecorder(t *testing.T) {
+	const (
+		clients = 4
+		ops     = 100
+	)
+
+	s := &brokenSet{items: make(map[int]struct{})}
+	r := NewRecorder(clients)
+
+	wg := sync.WaitGroup{}
+	wg.Add(clients)
+
+	for i := 0; i < clients; i++ {
+		c := r.Client(i)
+
+		go func() {
+			defer wg.Done()
+
+			for j := 0; j < ops; j++ {
+				c.Insert(s, j%3)
+				c.Contains(s, j%3)
+			}
+		}()
+	}
+
+	wg.Wait()
+
+	h := r.History()
+	require.Len(t, h, 2*clients*ops)
+	require.Error(t, Check(h))
+}
@@ -0,0 +1,150 @@
+// Package linearizability records concurrent histories of set operations and checks them
+// against the sequential specification of set.
+package linearizability
+
+import (
+	"fmt"
+	"sort"
+	"sync/atomic"
+)
+
+// Set mirrors the interface of the sets under test (it can't be imported because of import cycle).
+type Set interface {
+	Insert(value int) bool
+	Contains(value int) bool
+	Remove(value int) bool
+}
+
+// Kind is a kind of set operation.
+type Kind int8
+
+// Kinds of set operations.
+const (
+	Insert Kind = iota + 1
+	Contains
+	Remove
+)
+
+func (k Kind) String() string {
+	switch k {
+	case Insert:
+		return "Insert"
+	case Contains:
+		return "Contains"
+	case Remove:
+		return "Remove"
+	default:
+		panic("unknown Kind")
+	}
+}
+
+// Operation is a completed operation: invocation and response events with the result.
+// Call and Return are taken from the logical clock shared by all clients,
+// so they are ordered in the same way as real time events.
+type Operation struct {
+	Client int
+	Kind   Kind
+	Key    int
+	Result bool
+	Call   int64
+	Return int64
+}
+
+func (op Operation) String() string {
+	return fmt.Sprintf("[%d, %d] client %d: %v(%d) = %v", op.Call, op.Return, op.Client, op.Kind, op.Key, op.Result)
+}
+
+// History is a set of operations performed concurrently.
+type History []Operation
+
+// Recorder records operations of concurrent clients. Every client must be used by a single goroutine.
+type Recorder struct {
+	clock   int64
+	clients []*Client
+}
+
+// NewRecorder creates recorder for the given number of clients.
+func NewRecorder(clients int) *Recorder {
+	r := &Recorder{clients: make([]*Client, clients)}
+
+	for i := range r.clients {
+		r.clients[i] = &Client{id: i, recorder: r}
+	}
+
+	return r
+}
+
+// Client returns client with the given number.
+func (r *Recorder) Client(id int) *Client {
+	return r.clients[id]
+}
+
+// History merges operations of all clients. It must be called when all clients have finished.
+func (r *Recorder) History() History {
+	var h History
+
+	for _, c := range r.clients {
+		h = append(h, c.ops...)
+	}
+
+	sort.Slice(h, func(i, j int) bool { return h[i].Call < h[j].Call })
+
+	return h
+}
+
+func (r *Recorder) tick() int64 {
+	return atomic.AddInt64(&r.clock, 1)
+}
+
+// Client performs operations on a set and records them.
+type Client struct {
+	recorder *Recorder
+	ops      []Operation
+	id       int
+}
+
+// Insert calls Set.Insert and records the operation.
+func (c *Client) Insert(s Set, key int) bool {
+	return c.do(Insert, key, s.Insert)
+}
+
+// Contains calls Set.Contains and records the operation.
+func (c *Client) Contains(s Set, key int) bool {
+	return c.do(Contains, key, s.Contains)
+}
+
+// Remove calls Set.Remove and records the operation.
+func (c *Client) Remove(s Set, key int) bool {
+	return c.do(Remove, key, s.Remove)
+}
+
+// Do performs the operation of the given kind.
+func (c *Client) Do(s Set, kind Kind, key int) bool {
+	switch kind {
+	case Insert:
+		return c.Insert(s, key)
+	case Contains:
+		return c.Contains(s, key)
+	case Remove:
+		return c.Remove(s, key)
+	default:
+		panic("unknown Kind")
+	}
+}
+
+func (c *Client) do(kind Kind, key int, fn func(int) bool) bool {
+	call := c.recorder.tick()
+	result := fn(key)
+	ret := c.recorder.tick()
+
+	c.ops = append(c.ops, Operation{
+		Client: c.id,
+		Kind:   kind,
+		Key:    key,
+		Result: result,
+		Call:   call,
+		Return: ret,
+	})
+
+	return result
+}
@@ -0,0 +1,255 @@
+package report
+
+import (
+	"io"
+	"math"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// Point is the mean value measured with the given number of threads.
+type Point struct {
+	Threads int
+	Value   float64
+}
+
+// Series contains the points of a single implementation.
+type Series struct {
+	Name   string
+	Points []Point
+}
+
+// Chart shows how the unit depends on the number of threads for every implementation in the scenario.
+type Chart struct {
+	Scenario string
+	Variant  string
+	Unit     string
+	Series   []*Series
+}
+
+// DefaultUnit is the unit of the charts in README.
+const DefaultUnit = "ns/op"
+
+// Name is used as the file name of the chart; the unit is appended unless it's the default one.
+func (c *Chart) Name() string {
+	name := c.Scenario
+	if c.Variant != "" {
+		name += "_" + c.Variant
+	}
+
+	if c.Unit != DefaultUnit {
+		name += "_" + strings.ReplaceAll(c.Unit, "/", "_")
+	}
+
+	return name
+}
+
+// Title describes the chart.
+func (c *Chart) Title() string {
+	if c.Variant == "" {
+		return "Scenario: " + c.Scenario
+	}
+
+	return "Scenario: " + c.Scenario + ", data_source: " + c.Variant
+}
+
+// Charts groups the measurements of the benchmark by scenario and variant in the order of their first appearance.
+// Measurements without thread count, implementation or the unit are skipped.
+func Charts(measurements []*Measurement, benchmark, unit string) []*Chart {
+	var charts []*Chart
+
+	byName := make(map[string]*Chart)
+	series := make(map[string]*Series)
+
+	for _, m := range measurements {
+		value, ok := m.Mean(unit)
+		if !ok || m.Benchmark != benchmark || m.Threads == 0 || m.Implementation == "" {
+			continue
+		}
+
+		chart := &Chart{Scenario: m.Scenario, Variant: m.Variant, Unit: unit}
+		if existing, ok := byName[chart.Name()]; ok {
+			chart = existing
+		} else {
+			byName[chart.Name()] = chart
+			charts = append(charts, chart)
+		}
+
+		key := chart.Name() + "/" + m.Implementation
+
+		s, ok := series[key]
+		if !ok {
+			s = &Series{Name: m.Implementation}
+			series[key] = s
+			chart.Series = append(chart.Series, s)
+		}
+
+		s.Points = append(s.Points, Point{Threads: m.Threads, Value: value})
+	}
+
+	for _, chart := range charts {
+		sort.Slice(chart.Series, func(i, j int) bool { return chart.Series[i].Name < chart.Series[j].Name })
+
+		for _, s := range chart.Series {
+			points := s.Points
+			sort.Slice(points, func(i, j int) bool { return points[i].Threads < points[j].Threads })
+		}
+	}
+
+	return charts
+}
+
+// geometry of the chart in pixels.
+const (
+	chartWidth   = 640
+	chartHeight  = 400
+	marginLeft   = 80
+	marginRight  = 170
+	marginTop    = 40
+	marginBottom = 50
+	plotWidth    = chartWidth - marginLeft - marginRight
+	plotHeight   = chartHeight - marginTop - marginBottom
+)
+
+// palette is ColorBrewer Set1, the colormap of the former matplotlib charts.
+//
+//nolint:gochecknoglobals // read-only palette
+var palette = []string{"#e41a1c", "#377eb8", "#4daf4a", "#984ea3", "#ff7f00", "#a65628", "#f781bf", "#999999"}
+
+// axis maps values to pixels on a logarithmic scale.
+type axis struct {
+	min, max float64 // logarithms of the bounds
+	base     float64
+}
+
+func newAxis(values []float64, base float64, roundBounds bool) axis {
+	a := axis{min: math.Inf(1), max: math.Inf(-1), base: base}
+
+	for _, v := range values {
+		l := math.Log(v) / math.Log(base)
+		a.min = math.Min(a.min, l)
+		a.max = math.Max(a.max, l)
+	}
+
+	if roundBounds {
+		a.min, a.max = math.Floor(a.min), math.Max(math.Ceil(a.max), math.Floor(a.min)+1)
+
+		return a
+	}
+
+	if a.max-a.min < 1 {
+		a.min, a.max = a.min-0.5, a.max+0.5
+	}
+
+	// keep the markers off the frame
+	padding := (a.max - a.min) / 20
+	a.min, a.max = a.min-padding, a.max+padding
+
+	return a
+}
+
+// position returns the share of the axis length, [0, 1].
+func (a axis) position(v float64) float64 {
+	return (math.Log(v)/math.Log(a.base) - a.min) / (a.max - a.min)
+}
+
+// RenderSVG draws the chart: threads on the log2 X axis, the unit on the log10 Y axis.
+func RenderSVG(w io.Writer, c *Chart) error {
+	var (
+		threads []float64
+		values  []float64
+	)
+
+	for _, s := range c.Series {
+		for _, p := range s.Points {
+			threads = append(threads, float64(p.Threads))
+			values = append(values, p.Value)
+		}
+	}
+
+	xAxis := newAxis(threads, 2, false)
+	yAxis := newAxis(values, 10, true)
+
+	x := func(v float64) float64 { return marginLeft + xAxis.position(v)*plotWidth }
+	y := func(v float64) float64 { return marginTop + (1-yAxis.position(v))*plotHeight }
+
+	b := &svgBuilder{}
+	b.printf(`<svg xmlns="http://www.w3.org/2000/svg" width="%d" height="%d" viewBox="0 0 %d %d" font-family="sans-serif" font-size="12">`,
+		chartWidth, chartHeight, chartWidth, chartHeight)
+	b.printf(`<rect width="%d" height="%d" fill="#ffffff"/>`, chartWidth, chartHeight)
+	b.text(chartWidth/2, marginTop/2, "middle", "14", c.Title())
+
+	// horizontal grid and labels at every power of ten
+	for e := yAxis.min; e <= yAxis.max; e++ {
+		v := math.Pow(10, e)
+		b.printf(`<line x1="%d" y1="%.1f" x2="%d" y2="%.1f" stroke="#dddddd"/>`, marginLeft, y(v), marginLeft+plotWidth, y(v))
+		b.text(marginLeft-6, y(v)+4, "end", "12", formatValue(c.Unit, v))
+	}
+
+	// vertical grid and labels at every thread count
+	for _, t := range uniqueSorted(threads) {
+		b.printf(`<line x1="%.1f" y1="%d" x2="%.1f" y2="%d" stroke="#dddddd"/>`, x(t), marginTop, x(t), marginTop+plotHeight)
+		b.text(x(t), marginTop+plotHeight+16, "middle", "12", strconv.Itoa(int(t)))
+	}
+
+	b.printf(`<rect x="%d" y="%d" width="%d" height="%d" fill="none" stroke="#000000"/>`, marginLeft, marginTop, plotWidth, plotHeight)
+	b.text(marginLeft+plotWidth/2, chartHeight-10, "middle", "12", "threads")
+	b.printf(`<text x="16" y="%d" text-anchor="middle" transform="rotate(-90 16 %d)">%s</text>`,
+		marginTop+plotHeight/2, marginTop+plotHeight/2, escape(c.Unit))
+
+	for i, s := range c.Series {
+		color := palette[i%len(palette)]
+
+		b.printf(`<polyline fill="none" stroke="%s" stroke-width="2" points="`, color)
+
+		for _, p := range s.Points {
+			b.printf("%.1f,%.1f ", x(float64(p.Threads)), y(p.Value))
+		}
+
+		b.printf(`"/>`)
+
+		for _, p := range s.Points {
+			b.printf(`<circle cx="%.1f" cy="%.1f" r="3" fill="%s"/>`, x(float64(p.Threads)), y(p.Value), color)
+		}
+
+		// legend
+		ly := float64(marginTop + 10 + i*20)
+		b.printf(`<line x1="%d" y1="%.1f" x2="%d" y2="%.1f" stroke="%s" stroke-width="2"/>`,
+			marginLeft+plotWidth+10, ly, marginLeft+plotWidth+30, ly, color)
+		b.text(marginLeft+plotWidth+36, ly+4, "start", "12", s.Name)
+	}
+
+	b.printf("</svg>")
+
+	_, err := w.Write(b.buf.Bytes())
+
+	return err
+}
+
+// formatValue makes the tick labels of time units (ns/op and latencies like contains-p99-ns) human-readable.
+func formatValue(unit string, v float64) string {
+	if unit == DefaultUnit || strings.HasSuffix(unit, "-ns") {
+		return time.Duration(v).String()
+	}
+
+	return strconv.FormatFloat(v, 'g', 3, 64)
+}
+
+func uniqueSorted(values []float64) []float64 {
+	seen := make(map[float64]bool)
+
+	var result []float64
+
+	for _, v := range values {
+		if !seen[v] {
+			seen[v] = true
+			result = append(result, v)
+		}
+	}
+
+	sort.Float64s(result)
+
+	return result
+}
@@ -0,0 +1,204 @@
+package report
+
+import (
+	"math"
+	"sort"
+	"strings"
+)
+
+// Delta compares the samples of the same benchmark in two result files.
+type Delta struct {
+	Name string
+	Unit string
+	Old  []float64
+	New  []float64
+	// Change is the relative change of the mean, e.g. 0.1 for +10%.
+	Change float64
+	// P is the two-sided p-value of Mann-Whitney U test.
+	P float64
+	// Significant is true if P is below the significance level.
+	Significant bool
+	// Regression is true if the change is significant, makes the result worse and exceeds the threshold.
+	Regression bool
+}
+
+// HigherIsBetter tells whether the unit measures throughput (ops/s, MB/s) rather than cost (ns/op, B/op).
+func HigherIsBetter(unit string) bool {
+	return strings.HasSuffix(unit, "/s")
+}
+
+// Compare matches the measurements by name and compares the samples of the unit.
+// A change is considered a regression if it's significant at level alpha and worse than threshold (e.g. 0.05 for 5%).
+func Compare(oldMeasurements, newMeasurements []*Measurement, unit string, alpha, threshold float64) []*Delta {
+	byName := make(map[string]*Measurement, len(oldMeasurements))
+	for _, m := range oldMeasurements {
+		byName[m.Name] = m
+	}
+
+	var deltas []*Delta
+
+	for _, m := range newMeasurements {
+		old, ok := byName[m.Name]
+		if !ok {
+			continue
+		}
+
+		oldMean, okOld := old.Mean(unit)
+		newMean, okNew := m.Mean(unit)
+
+		if !okOld || !okNew {
+			continue
+		}
+
+		d := &Delta{
+			Name: m.Name,
+			Unit: unit,
+			Old:  old.Samples[unit],
+			New:  m.Samples[unit],
+			P:    MannWhitney(old.Samples[unit], m.Samples[unit]),
+		}
+
+		if oldMean != 0 {
+			d.Change = (newMean - oldMean) / oldMean
+		}
+
+		d.Significant = d.P < alpha
+
+		worsening := d.Change
+		if HigherIsBetter(unit) {
+			worsening = -worsening
+		}
+
+		d.Regression = d.Significant && worsening > threshold
+		deltas = append(deltas, d)
+	}
+
+	return deltas
+}
+
+// maxExactSamples limits the size of the samples for which the exact distribution of U is computed.
+const maxExactSamples = 50
+
+// MannWhitney returns the two-sided p-value of Mann-Whitney U test: the probability
+// to observe such a difference between the samples if they came from the same distribution.
+// Small samples without ties use the exact distribution of U, the rest use the normal
+// approximation with tie and continuity corrections. Too small samples never give a significant result
+// (e.g. 1 run against 1 run has p = 1).
+func MannWhitney(x, y []float64) float64 {
+	n1, n2 := len(x), len(y)
+	if n1 == 0 || n2 == 0 {
+		return 1
+	}
+
+	u, ties := statisticU(x, y)
+
+	if ties == 0 && n1+n2 <= maxExactSamples {
+		return exactP(n1, n2, u)
+	}
+
+	n := float64(n1 + n2)
+	mu := float64(n1*n2) / 2
+	sigma := math.Sqrt(float64(n1*n2) / 12 * ((n + 1) - ties/(n*(n-1))))
+
+	if sigma == 0 {
+		return 1
+	}
+
+	z := (math.Abs(u-mu) - 0.5) / sigma
+	if z < 0 {
+		return 1
+	}
+
+	return math.Erfc(z / math.Sqrt2)
+}
+
+// statisticU returns U of the first sample and the tie correction term: sum of t^3 - t over the groups of ties.
+func statisticU(x, y []float64) (u, ties float64) {
+	type item struct {
+		value float64
+		first bool
+	}
+
+	items := make([]item, 0, len(x)+len(y))
+	for _, v := range x {
+		items = append(items, item{value: v, first: true})
+	}
+
+	for _, v := range y {
+		items = append(items, item{value: v})
+	}
+
+	sort.Slice(items, func(i, j int) bool { return items[i].value < items[j].value })
+
+	var rankSum float64
+
+	for i := 0; i < len(items); {
+		j := i
+		for j < len(items) && items[j].value == items[i].value {
+			j++
+		}
+
+		// tied values get the average of their ranks (1-based)
+		rank := float64(i+j+1) / 2
+		t := float64(j - i)
+		ties += t*t*t - t
+
+		for k := i; k < j; k++ {
+			if items[k].first {
+				rankSum += rank
+			}
+		}
+
+		i = j
+	}
+
+	n1 := float64(len(x))
+
+	return rankSum - n1*(n1+1)/2, ties
+}
+
+// exactP computes the two-sided p-value from the number of arrangements of the samples giving every value of U.
+func exactP(n1, n2 int, u float64) float64 {
+	// counts[i][j][k] is the number of arrangements of i and j values with U = k,
+	// computed by adding the largest value either to the first sample (it beats all j values) or to the second one
+	counts := make([][][]float64, n1+1)
+	for i := range counts {
+		counts[i] = make([][]float64, n2+1)
+		for j := range counts[i] {
+			counts[i][j] = make([]float64, i*j+1)
+
+			switch {
+			case i == 0 || j == 0:
+				counts[i][j][0] = 1
+			default:
+				for k := range counts[i][j] {
+					if k >= j {
+						counts[i][j][k] += counts[i-1][j][k-j]
+					}
+
+					if k < len(counts[i][j-1]) {
+						counts[i][j][k] += counts[i][j-1][k]
+					}
+				}
+			}
+		}
+	}
+
+	dist := counts[n1][n2]
+
+	var total, lower, upper float64
+
+	for k, c := range dist {
+		total += c
+
+		if float64(k) <= u {
+			lower += c
+		}
+
+		if float64(k) >= u {
+			upper += c
+		}
+	}
+
+	return math.Min(1, 2*math.Min(lower, upper)/total)
+}
@@ -0,0 +1,85 @@
+package report
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestMannWhitney(t *testing.T) {
+	// exact distribution: the only arrangement out of C(10, 5) = 252 on every side
+	p := MannWhitney([]float64{1, 2, 3, 4, 5}, []float64{6, 7, 8, 9, 10})
+	require.InDelta(t, 2.0/252, p, 1e-12)
+
+	// the test is symmetric
+	require.InDelta(t, p, MannWhitney([]float64{6, 7, 8, 9, 10}, []float64{1, 2, 3, 4, 5}), 1e-12)
+
+	// interleaved samples
+	require.Equal(t, 1.0, MannWhitney([]float64{1, 4, 5, 8}, []float64{2, 3, 6, 7}))
+
+	// a single run can't be significant
+	require.Equal(t, 1.0, MannWhitney([]float64{1}, []float64{100}))
+
+	// ties use the normal approximation
+	require.Equal(t, 1.0, MannWhitney([]float64{5, 5, 5}, []float64{5, 5, 5}))
+	require.Less(t, MannWhitney([]float64{1, 1, 2, 2, 3, 3, 4, 4}, []float64{5, 5, 6, 6, 7, 7, 8, 8}), 0.01)
+
+	require.Equal(t, 1.0, MannWhitney(nil, []float64{1}))
+}
+
+func TestStatisticU(t *testing.T) {
+	u, ties := statisticU([]float64{1, 3}, []float64{2, 3})
+	// 1 beats nothing, 3 beats 2 and ties with 3
+	require.Equal(t, 1.5, u)
+	require.Equal(t, 6.0, ties)
+}
+
+func TestCompare(t *testing.T) {
+	measurement := func(name, unit string, samples ...float64) *Measurement {
+		m := newMeasurement(name)
+		m.Samples[unit] = samples
+
+		return m
+	}
+
+	oldMs := []*Measurement{
+		measurement("BenchmarkSet/2_threads/a/lazy/insert", "ns/op", 100, 101, 102, 99, 98),
+		measurement("BenchmarkSet/2_threads/a/lazy/contains", "ns/op", 100, 101, 102, 99, 98),
+		measurement("BenchmarkSet/2_threads/a/lazy/insert_and_remove", "ns/op", 100, 101, 102, 99, 98),
+		measurement("BenchmarkWorkload/2_threads/w/lazy", "ops/s", 1000, 1010, 990, 1005, 995),
+		measurement("BenchmarkSet/2_threads/a/lazy/removed", "ns/op", 100),
+	}
+
+	newMs := []*Measurement{
+		// significantly slower
+		measurement("BenchmarkSet/2_threads/a/lazy/insert", "ns/op", 120, 121, 122, 119, 118),
+		// significantly faster
+		measurement("BenchmarkSet/2_threads/a/lazy/contains", "ns/op", 80, 81, 82, 79, 78),
+		// noise
+		measurement("BenchmarkSet/2_threads/a/lazy/insert_and_remove", "ns/op", 101, 97, 103, 100, 99),
+		// lower throughput
+		measurement("BenchmarkWorkload/2_threads/w/lazy", "ops/s", 800, 810, 790, 805, 795),
+		measurement("BenchmarkSet/2_threads/a/lazy/added", "ns/op", 100),
+	}
+
+	deltas := Compare(oldMs, newMs, "ns/op", 0.05, 0.05)
+	require.Len(t, deltas, 3)
+
+	require.InDelta(t, 0.2, deltas[0].Change, 1e-9)
+	require.True(t, deltas[0].Significant)
+	require.True(t, deltas[0].Regression)
+
+	require.InDelta(t, -0.2, deltas[1].Change, 1e-9)
+	require.True(t, deltas[1].Significant)
+	require.False(t, deltas[1].Regression)
+
+	require.False(t, deltas[2].Significant)
+	require.False(t, deltas[2].Regression)
+
+	// the threshold hides significant but small regressions
+	require.False(t, Compare(oldMs, newMs, "ns/op", 0.05, 0.25)[0].Regression)
+
+	deltas = Compare(oldMs, newMs, "ops/s", 0.05, 0.05)
+	require.Len(t, deltas, 1)
+	require.True(t, deltas[0].Regression)
+}
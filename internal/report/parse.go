@@ -0,0 +1,239 @@
+// Package report turns benchmark results into SVG charts and the benchmark section of README.
+// It reads the text output of `go test -bench` as well as the JSON output of cmd/setbench.
+package report
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/json"
+	"errors"
+	"io"
+	"os"
+	"regexp"
+	"strconv"
+	"strings"
+)
+
+// Measurement collects the results of all runs of a single benchmark.
+type Measurement struct {
+	// Name is the full name of the benchmark without the GOMAXPROCS suffix.
+	Name string
+	// Benchmark is the top-level benchmark, e.g. BenchmarkSet; setbench for cmd/setbench results.
+	Benchmark string
+	// Scenario is the innermost part of the name that is neither an implementation nor a thread count,
+	// e.g. insert for BenchmarkSet/2_threads/ascending_array/lazy/insert.
+	Scenario string
+	// Variant joins the remaining parts of the name, e.g. ascending_array.
+	Variant        string
+	Implementation string
+	Threads        int
+	// Samples contains the values of every unit (ns/op, ops/s, ...), one per run.
+	Samples map[string][]float64
+}
+
+// Mean returns the average of the samples of the unit.
+func (m *Measurement) Mean(unit string) (float64, bool) {
+	samples := m.Samples[unit]
+	if len(samples) == 0 {
+		return 0, false
+	}
+
+	var sum float64
+	for _, s := range samples {
+		sum += s
+	}
+
+	return sum / float64(len(samples)), true
+}
+
+// implementations are recognized in benchmark names.
+//
+//nolint:gochecknoglobals // read-only registry
+var implementations = map[string]bool{
+	"sequential":         true,
+	"coarse_grained":     true,
+	"fine_grained":       true,
+	"optimistic":         true,
+	"lazy":               true,
+	"nonblocking":        true,
+	"nonblocking_hazard": true,
+	"stm":                true,
+	"mvcc":               true,
+}
+
+var (
+	threadsPattern = regexp.MustCompile(`^(\d+)_threads$`)
+	procsPattern   = regexp.MustCompile(`-\d+$`)
+)
+
+// newMeasurement splits the name of the benchmark into its parts.
+func newMeasurement(name string) *Measurement {
+	m := &Measurement{Name: name, Samples: make(map[string][]float64)}
+	parts := strings.Split(name, "/")
+	m.Benchmark = parts[0]
+
+	var rest []string
+
+	for _, part := range parts[1:] {
+		if match := threadsPattern.FindStringSubmatch(part); match != nil {
+			m.Threads, _ = strconv.Atoi(match[1])
+
+			continue
+		}
+
+		if implementations[part] {
+			m.Implementation = part
+
+			continue
+		}
+
+		rest = append(rest, part)
+	}
+
+	if len(rest) > 0 {
+		m.Scenario = rest[len(rest)-1]
+		m.Variant = strings.Join(rest[:len(rest)-1], "_")
+	}
+
+	return m
+}
+
+// collector keeps the measurements in the order of their first appearance.
+type collector struct {
+	byName       map[string]*Measurement
+	measurements []*Measurement
+}
+
+func (c *collector) get(name string) *Measurement {
+	if m, ok := c.byName[name]; ok {
+		return m
+	}
+
+	m := newMeasurement(name)
+	c.byName[name] = m
+	c.measurements = append(c.measurements, m)
+
+	return m
+}
+
+// ParseBench parses the output of `go test -bench`; the lines other than the benchmark results are ignored.
+// Repeated runs of the same benchmark (-count) become the samples of a single measurement.
+func ParseBench(r io.Reader) ([]*Measurement, error) {
+	c := &collector{byName: make(map[string]*Measurement)}
+	scanner := bufio.NewScanner(r)
+
+	for scanner.Scan() {
+		fields := strings.Fields(scanner.Text())
+
+		// name, iterations and at least one value with its unit
+		if len(fields) < 4 || !strings.HasPrefix(fields[0], "Benchmark") || len(fields)%2 != 0 {
+			continue
+		}
+
+		if _, err := strconv.Atoi(fields[1]); err != nil {
+			continue
+		}
+
+		m := c.get(procsPattern.ReplaceAllString(fields[0], ""))
+
+		for i := 2; i+1 < len(fields); i += 2 {
+			value, err := strconv.ParseFloat(fields[i], 64)
+			if err != nil {
+				return nil, &parseError{err: errInvalidLine, line: scanner.Text()}
+			}
+
+			m.Samples[fields[i+1]] = append(m.Samples[fields[i+1]], value)
+		}
+	}
+
+	return c.measurements, scanner.Err()
+}
+
+// setbenchResult mirrors the JSON output of cmd/setbench.
+type setbenchResult struct {
+	Implementation string  `json:"implementation"`
+	Threads        int     `json:"threads"`
+	Workload       string  `json:"workload"`
+	Seconds        float64 `json:"seconds"`
+	Operations     int64   `json:"operations"`
+	Throughput     float64 `json:"throughput"`
+	Retries        int64   `json:"retries"`
+	Latency        map[string]struct {
+		P50  float64 `json:"p50"`
+		P99  float64 `json:"p99"`
+		P999 float64 `json:"p999"`
+		Max  float64 `json:"max"`
+	} `json:"latency"`
+}
+
+// SetbenchName is the benchmark name of cmd/setbench results.
+const SetbenchName = "setbench"
+
+// ParseSetbench parses the JSON output of cmd/setbench. Every run becomes a measurement
+// named setbench/<threads>_threads/<workload>/<implementation> with units ns/op (per goroutine,
+// like in `go test -bench`), ops/s, retries and latency percentiles named like in BenchmarkWorkload,
+// e.g. contains-p99-ns.
+func ParseSetbench(r io.Reader) ([]*Measurement, error) {
+	var results []*setbenchResult
+
+	if err := json.NewDecoder(r).Decode(&results); err != nil {
+		return nil, err
+	}
+
+	c := &collector{byName: make(map[string]*Measurement)}
+
+	for _, res := range results {
+		name := SetbenchName + "/" + strconv.Itoa(res.Threads) + "_threads/" + res.Workload + "/" + res.Implementation
+		m := c.get(name)
+
+		if res.Operations > 0 {
+			nsPerOp := res.Seconds * 1e9 * float64(res.Threads) / float64(res.Operations)
+			m.Samples["ns/op"] = append(m.Samples["ns/op"], nsPerOp)
+		}
+
+		m.Samples["ops/s"] = append(m.Samples["ops/s"], res.Throughput)
+		m.Samples["retries"] = append(m.Samples["retries"], float64(res.Retries))
+
+		for op, l := range res.Latency {
+			for _, p := range []struct {
+				name  string
+				value float64
+			}{{"p50", l.P50}, {"p99", l.P99}, {"p999", l.P999}, {"max", l.Max}} {
+				unit := op + "-" + p.name + "-ns"
+				m.Samples[unit] = append(m.Samples[unit], p.value)
+			}
+		}
+	}
+
+	return c.measurements, nil
+}
+
+// Load reads the file in either of the formats: JSON arrays come from cmd/setbench.
+func Load(path string) ([]*Measurement, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+
+	if bytes.HasPrefix(bytes.TrimSpace(data), []byte("[")) {
+		return ParseSetbench(bytes.NewReader(data))
+	}
+
+	return ParseBench(bytes.NewReader(data))
+}
+
+var errInvalidLine = errors.New("invalid benchmark line")
+
+// parseError points to the line that can't be parsed.
+type parseError struct {
+	err  error
+	line string
+}
+
+func (e *parseError) Error() string {
+	return e.err.Error() + ": " + strconv.Quote(e.line)
+}
+
+func (e *parseError) Unwrap() error {
+	return e.err
+}
@@ -0,0 +1,96 @@
+package report
+
+import (
+	"bytes"
+	"errors"
+	"path"
+)
+
+// Markers delimit the generated part of README.
+const (
+	BeginMarker = "<!-- benchmark charts: begin -->"
+	EndMarker   = "<!-- benchmark charts: end -->"
+)
+
+// scenario titles and descriptions of BenchmarkSet.
+//
+//nolint:gochecknoglobals // read-only registry
+var scenarios = map[string]struct{ title, description string }{
+	"insert": {
+		title:       "Concurrent write",
+		description: "Each thread inserts items from the input array to the set.",
+	},
+	"contains": {
+		title:       "Concurrent read",
+		description: "Each thread tries to seek the items in the pre-prepared set.",
+	},
+	"insert_and_contains": {
+		title:       "Concurrent write and read",
+		description: "Half of the threads are inserting items from the input array to the set, while the other half is seeking for the items.",
+	},
+	"insert_and_remove": {
+		title:       "Concurrent write and delete",
+		description: "Half of the threads are inserting items from the input array to the set, while the other half is removing the items.",
+	},
+}
+
+var errMarkersNotFound = errors.New("README doesn't contain the markers of the benchmark section: " + BeginMarker + " ... " + EndMarker)
+
+// Section renders a subsection per scenario with the charts of all its variants.
+// dir is the path to the charts relative to README.
+func Section(charts []*Chart, dir string) []byte {
+	var (
+		buf   bytes.Buffer
+		order []string
+	)
+
+	byScenario := make(map[string][]*Chart)
+
+	for _, c := range charts {
+		if _, ok := byScenario[c.Scenario]; !ok {
+			order = append(order, c.Scenario)
+		}
+
+		byScenario[c.Scenario] = append(byScenario[c.Scenario], c)
+	}
+
+	for _, scenario := range order {
+		title, description := scenario, ""
+		if s, ok := scenarios[scenario]; ok {
+			title, description = s.title, s.description
+		}
+
+		buf.WriteString("### " + title + "\n")
+
+		if description != "" {
+			buf.WriteString("- " + description + "\n")
+		}
+
+		for _, c := range byScenario[scenario] {
+			buf.WriteString("![](" + path.Join(dir, c.Name()+".svg") + ")\n")
+		}
+
+		buf.WriteString("\n")
+	}
+
+	return buf.Bytes()
+}
+
+// UpdateReadme replaces the text between the markers with the section.
+func UpdateReadme(readme, section []byte) ([]byte, error) {
+	begin := bytes.Index(readme, []byte(BeginMarker))
+	end := bytes.Index(readme, []byte(EndMarker))
+
+	if begin < 0 || end < begin {
+		return nil, errMarkersNotFound
+	}
+
+	var buf bytes.Buffer
+
+	buf.Write(readme[:begin+len(BeginMarker)])
+	buf.WriteString("\n")
+	buf.Write(section)
+	buf.Write(readme[end:])
+
+	return buf.Bytes(), nil
+}
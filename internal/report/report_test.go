@@ -0,0 +1,158 @@
+package report
+
+import (
+	"bytes"
+	"encoding/xml"
+	"errors"
+	"io"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+const benchOutput = `goos: linux
+goarch: amd64
+pkg: github.com/vitalyisaev2/linked_list_set
+BenchmarkSet/2_threads/ascending_array/lazy/insert-8         	     100	      2000 ns/op
+BenchmarkSet/2_threads/ascending_array/lazy/insert-8         	     100	      4000 ns/op
+BenchmarkSet/8_threads/ascending_array/lazy/insert-8         	     100	      8000 ns/op
+BenchmarkSet/2_threads/ascending_array/nonblocking/insert-8  	     100	      1000 ns/op
+BenchmarkSet/2_threads/shuffled_array/lazy/contains-8        	     100	       500 ns/op
+BenchmarkContentionManager/8_threads/lazy/yield-8            	     100	       700 ns/op	 1000000 ops/s
+PASS
+ok  	github.com/vitalyisaev2/linked_list_set	1.708s
+`
+
+func TestParseBench(t *testing.T) {
+	ms, err := ParseBench(strings.NewReader(benchOutput))
+	require.NoError(t, err)
+	require.Len(t, ms, 5)
+
+	m := ms[0]
+	require.Equal(t, "BenchmarkSet/2_threads/ascending_array/lazy/insert", m.Name)
+	require.Equal(t, "BenchmarkSet", m.Benchmark)
+	require.Equal(t, "insert", m.Scenario)
+	require.Equal(t, "ascending_array", m.Variant)
+	require.Equal(t, "lazy", m.Implementation)
+	require.Equal(t, 2, m.Threads)
+	require.Equal(t, []float64{2000, 4000}, m.Samples["ns/op"])
+
+	mean, ok := m.Mean("ns/op")
+	require.True(t, ok)
+	require.Equal(t, 3000.0, mean)
+
+	m = ms[4]
+	require.Equal(t, "yield", m.Scenario)
+	require.Equal(t, "", m.Variant)
+	require.Equal(t, []float64{1e6}, m.Samples["ops/s"])
+
+	_, err = ParseBench(strings.NewReader("BenchmarkSet/2_threads/lazy/insert-8 100 fast ns/op\n"))
+	require.True(t, errors.Is(err, errInvalidLine))
+}
+
+func TestParseTransactions(t *testing.T) {
+	input := `BenchmarkTransactions/4_threads/insert_if_absent/stm-8  	     100	      1500 ns/op	        0.2500 restarts/call
+BenchmarkTransactions/4_threads/insert_if_absent/coarse_grained-8 	     100	       900 ns/op
+`
+
+	ms, err := ParseBench(strings.NewReader(input))
+	require.NoError(t, err)
+	require.Len(t, ms, 2)
+
+	m := ms[0]
+	require.Equal(t, "BenchmarkTransactions", m.Benchmark)
+	require.Equal(t, "insert_if_absent", m.Scenario)
+	require.Equal(t, "", m.Variant)
+	require.Equal(t, "stm", m.Implementation)
+	require.Equal(t, 4, m.Threads)
+	require.Equal(t, []float64{1500}, m.Samples["ns/op"])
+	require.Equal(t, []float64{0.25}, m.Samples["restarts/call"])
+
+	require.Equal(t, "coarse_grained", ms[1].Implementation)
+	require.Equal(t, "insert_if_absent", ms[1].Scenario)
+}
+
+func TestParseSetbench(t *testing.T) {
+	input := `[{"implementation": "lazy", "threads": 4, "workload": "90_9_1_uniform_1024_keys",
+		"seconds": 2, "operations": 8000000, "throughput": 4000000, "retries": 12,
+		"latency": {"contains": {"count": 7200000, "p50": 800, "p99": 3000, "p999": 9000, "max": 70000}}}]`
+
+	ms, err := ParseSetbench(strings.NewReader(input))
+	require.NoError(t, err)
+	require.Len(t, ms, 1)
+
+	m := ms[0]
+	require.Equal(t, SetbenchName, m.Benchmark)
+	require.Equal(t, "90_9_1_uniform_1024_keys", m.Scenario)
+	require.Equal(t, "lazy", m.Implementation)
+	require.Equal(t, 4, m.Threads)
+	require.Equal(t, []float64{1000}, m.Samples["ns/op"])
+	require.Equal(t, []float64{12}, m.Samples["retries"])
+	require.Equal(t, []float64{3000}, m.Samples["contains-p99-ns"])
+	require.Equal(t, []float64{70000}, m.Samples["contains-max-ns"])
+}
+
+func TestCharts(t *testing.T) {
+	ms, err := ParseBench(strings.NewReader(benchOutput))
+	require.NoError(t, err)
+
+	charts := Charts(ms, "BenchmarkSet", "ns/op")
+	require.Len(t, charts, 2)
+
+	c := charts[0]
+	require.Equal(t, "insert_ascending_array", c.Name())
+	require.Len(t, c.Series, 2)
+	require.Equal(t, "lazy", c.Series[0].Name)
+	require.Equal(t, []Point{{Threads: 2, Value: 3000}, {Threads: 8, Value: 8000}}, c.Series[0].Points)
+	require.Equal(t, "nonblocking", c.Series[1].Name)
+
+	require.Equal(t, "contains_shuffled_array", charts[1].Name())
+
+	for _, c := range charts {
+		var buf bytes.Buffer
+
+		require.NoError(t, RenderSVG(&buf, c))
+
+		// the document must be well-formed
+		dec := xml.NewDecoder(&buf)
+
+		for {
+			_, err := dec.Token()
+			if errors.Is(err, io.EOF) {
+				break
+			}
+
+			require.NoError(t, err)
+		}
+	}
+}
+
+func TestUpdateReadme(t *testing.T) {
+	ms, err := ParseBench(strings.NewReader(benchOutput))
+	require.NoError(t, err)
+
+	section := Section(Charts(ms, "BenchmarkSet", "ns/op"), "report")
+
+	expected := "### Concurrent write\n" +
+		"- Each thread inserts items from the input array to the set.\n" +
+		"![](report/insert_ascending_array.svg)\n\n" +
+		"### Concurrent read\n" +
+		"- Each thread tries to seek the items in the pre-prepared set.\n" +
+		"![](report/contains_shuffled_array.svg)\n\n"
+	require.Equal(t, expected, string(section))
+
+	readme := "# Title\n" + BeginMarker + "\nold charts\n" + EndMarker + "\n## Conclusions\n"
+
+	updated, err := UpdateReadme([]byte(readme), section)
+	require.NoError(t, err)
+	require.Equal(t, "# Title\n"+BeginMarker+"\n"+expected+EndMarker+"\n## Conclusions\n", string(updated))
+
+	// regeneration is idempotent
+	again, err := UpdateReadme(updated, section)
+	require.NoError(t, err)
+	require.Equal(t, updated, again)
+
+	_, err = UpdateReadme([]byte("# Title\n"), section)
+	require.True(t, errors.Is(err, errMarkersNotFound))
+}
@@ -0,0 +1,35 @@
+package report
+
+import (
+	"bytes"
+	"encoding/xml"
+	"fmt"
+	"strings"
+)
+
+// svgBuilder accumulates the SVG document.
+type svgBuilder struct {
+	buf bytes.Buffer
+}
+
+// printf puts every completed element on its own line to keep the diffs of charts readable.
+func (b *svgBuilder) printf(format string, args ...interface{}) {
+	fmt.Fprintf(&b.buf, format, args...)
+
+	if strings.HasSuffix(format, ">") {
+		b.buf.WriteByte('\n')
+	}
+}
+
+// text adds an escaped label.
+func (b *svgBuilder) text(x, y float64, anchor, size, s string) {
+	b.printf(`<text x="%.1f" y="%.1f" text-anchor="%s" font-size="%s">%s</text>`, x, y, anchor, size, escape(s))
+}
+
+func escape(s string) string {
+	var buf bytes.Buffer
+
+	_ = xml.EscapeText(&buf, []byte(s))
+
+	return buf.String()
+}
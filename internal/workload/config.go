@@ -0,0 +1,108 @@
+package workload
+
+import (
+	"errors"
+	"math/rand"
+	"strconv"
+	"strings"
+)
+
+// Config describes a workload.
+type Config struct {
+	Mix          Mix
+	KeySpace     int
+	InitialFill  float64 // share of the key space inserted before the run, [0, 1]
+	Distribution Distribution
+	// ZipfianTheta is the skew of Zipfian distribution, (0, 1); YCSB uses 0.99.
+	ZipfianTheta float64
+	// HotKeysFraction of the key space receives HotOpsFraction of operations in Hotspot distribution.
+	// Unset (nil) fractions take the defaults; use Fraction to set them.
+	HotKeysFraction *float64
+	HotOpsFraction  *float64
+	// Seed determines the initial contents, the popularity of keys and the streams of operations.
+	Seed int64
+}
+
+// Defaults of the optional parameters.
+const (
+	DefaultZipfianTheta    = 0.99
+	DefaultHotKeysFraction = 0.2
+	DefaultHotOpsFraction  = 0.8
+)
+
+var (
+	errKeySpace = errors.New("key space must be positive")
+	errFraction = errors.New("fraction must be in [0, 1]")
+	errTheta    = errors.New("zipfian theta must be in (0, 1)")
+)
+
+// Validate checks the config and fills optional parameters with defaults.
+func (c *Config) Validate() error {
+	if err := c.Mix.validate(); err != nil {
+		return err
+	}
+
+	if c.KeySpace <= 0 {
+		return &invalidValueError{err: errKeySpace, value: strconv.Itoa(c.KeySpace)}
+	}
+
+	if c.ZipfianTheta == 0 {
+		c.ZipfianTheta = DefaultZipfianTheta
+	}
+
+	if c.HotKeysFraction == nil {
+		c.HotKeysFraction = Fraction(DefaultHotKeysFraction)
+	}
+
+	if c.HotOpsFraction == nil {
+		c.HotOpsFraction = Fraction(DefaultHotOpsFraction)
+	}
+
+	if c.ZipfianTheta <= 0 || c.ZipfianTheta >= 1 {
+		return &invalidValueError{err: errTheta, value: formatFloat(c.ZipfianTheta)}
+	}
+
+	for _, f := range []float64{c.InitialFill, *c.HotKeysFraction, *c.HotOpsFraction} {
+		if f < 0 || f > 1 {
+			return &invalidValueError{err: errFraction, value: formatFloat(f)}
+		}
+	}
+
+	return nil
+}
+
+// Fraction returns a pointer to the value, so that an explicit zero fraction can be told from an unset one.
+func Fraction(f float64) *float64 {
+	return &f
+}
+
+// Name describes the workload in a form suitable for benchmark names (without slashes).
+func (c *Config) Name() string {
+	return strings.ReplaceAll(c.Mix.String(), "/", "_") + "_" + c.Distribution.String() + "_" + strconv.Itoa(c.KeySpace) + "_keys"
+}
+
+// InitialKeys returns the keys to be inserted before the run.
+func (c *Config) InitialKeys() []int {
+	n := int(c.InitialFill * float64(c.KeySpace))
+
+	return rand.New(rand.NewSource(c.Seed)).Perm(c.KeySpace)[:n]
+}
+
+// Fill inserts the initial keys into the set.
+func (c *Config) Fill(s Set) {
+	for _, key := range c.InitialKeys() {
+		s.Insert(key)
+	}
+}
+
+func formatFloat(f float64) string {
+	return strconv.FormatFloat(f, 'g', -1, 64)
+}
+
+// Presets are the common mixes of operations.
+var Presets = map[string]Mix{
+	"read_only":   {Contains: 100},
+	"read_mostly": {Contains: 90, Insert: 9, Remove: 1},
+	"balanced":    {Contains: 50, Insert: 25, Remove: 25},
+	"write_heavy": {Contains: 10, Insert: 45, Remove: 45},
+}
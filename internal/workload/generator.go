@@ -0,0 +1,172 @@
+package workload
+
+import (
+	"math"
+	"math/rand"
+)
+
+// Workload is a validated config shared by the generators of all goroutines.
+type Workload struct {
+	config Config
+	// permutation maps popularity ranks to keys, so that popular keys are spread over the list
+	// instead of being gathered next to its head
+	permutation []int
+	zipf        *zipfianParams
+}
+
+// New validates the config and prepares the workload.
+func New(config Config) (*Workload, error) {
+	if err := config.Validate(); err != nil {
+		return nil, err
+	}
+
+	w := &Workload{config: config}
+
+	if config.Distribution == Zipfian || config.Distribution == Hotspot {
+		// differ from the permutation of initial keys
+		w.permutation = rand.New(rand.NewSource(config.Seed + 1)).Perm(config.KeySpace)
+	}
+
+	if config.Distribution == Zipfian {
+		w.zipf = newZipfianParams(config.KeySpace, config.ZipfianTheta)
+	}
+
+	return w, nil
+}
+
+// Config returns the config of the workload.
+func (w *Workload) Config() Config {
+	return w.config
+}
+
+// Generator returns the stream of operations for the goroutine with the given number.
+// Every goroutine must use its own generator.
+func (w *Workload) Generator(id int) *Generator {
+	seed := w.config.Seed + int64(id+2)*0x9e3779b9
+
+	rng := rand.New(rand.NewSource(seed))
+
+	// sequential streams of different goroutines start from different positions
+	return &Generator{workload: w, rng: rng, next: rng.Intn(w.config.KeySpace)}
+}
+
+// Generator produces operations. It is not safe for concurrent use.
+type Generator struct {
+	workload *Workload
+	rng      *rand.Rand
+	next     int
+}
+
+// Next returns the next operation and its key.
+func (g *Generator) Next() (Op, int) {
+	op := g.workload.config.Mix.pick(g.rng.Intn(100))
+
+	return op, g.key()
+}
+
+// Execute performs n operations on the set.
+func (g *Generator) Execute(s Set, n int) {
+	for i := 0; i < n; i++ {
+		op, key := g.Next()
+		Apply(s, op, key)
+	}
+}
+
+// Apply performs the operation on the set.
+func Apply(s Set, op Op, key int) bool {
+	switch op {
+	case Contains:
+		return s.Contains(key)
+	case Insert:
+		return s.Insert(key)
+	case Remove:
+		return s.Remove(key)
+	default:
+		panic("unknown Op")
+	}
+}
+
+func (g *Generator) key() int {
+	w := g.workload
+	n := w.config.KeySpace
+
+	switch w.config.Distribution {
+	case Uniform:
+		return g.rng.Intn(n)
+	case Zipfian:
+		return w.permutation[w.zipf.next(g.rng)]
+	case Hotspot:
+		hot := int(*w.config.HotKeysFraction * float64(n))
+		if hot == 0 || hot == n {
+			return g.rng.Intn(n)
+		}
+
+		if g.rng.Float64() < *w.config.HotOpsFraction {
+			return w.permutation[g.rng.Intn(hot)]
+		}
+
+		return w.permutation[hot+g.rng.Intn(n-hot)]
+	case Sequential:
+		key := g.next
+		g.next = (g.next + 1) % n
+
+		return key
+	default:
+		panic("unknown Distribution")
+	}
+}
+
+// zipfianParams are the constants of the generator from "Quickly Generating Billion-Record
+// Synthetic Databases" by Gray et al., the one used by YCSB. Unlike rand.Zipf it supports theta < 1.
+type zipfianParams struct {
+	n     float64
+	theta float64
+	alpha float64
+	zetan float64
+	eta   float64
+	half  float64 // 1 + 0.5^theta
+}
+
+func newZipfianParams(n int, theta float64) *zipfianParams {
+	zetan := zeta(n, theta)
+	zeta2 := zeta(2, theta)
+
+	return &zipfianParams{
+		n:     float64(n),
+		theta: theta,
+		alpha: 1 / (1 - theta),
+		zetan: zetan,
+		eta:   (1 - math.Pow(2/float64(n), 1-theta)) / (1 - zeta2/zetan),
+		half:  1 + math.Pow(0.5, theta),
+	}
+}
+
+// next returns a rank in [0, n): rank 0 is the most popular one.
+func (z *zipfianParams) next(rng *rand.Rand) int {
+	u := rng.Float64()
+	uz := u * z.zetan
+
+	switch {
+	case uz < 1:
+		return 0
+	case uz < z.half:
+		return 1
+	}
+
+	rank := int(z.n * math.Pow(z.eta*u-z.eta+1, z.alpha))
+	if rank >= int(z.n) {
+		rank = int(z.n) - 1
+	}
+
+	return rank
+}
+
+func zeta(n int, theta float64) float64 {
+	var sum float64
+
+	for i := 1; i <= n; i++ {
+		sum += 1 / math.Pow(float64(i), theta)
+	}
+
+	return sum
+}
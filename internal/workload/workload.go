@@ -0,0 +1,159 @@
+// Package workload generates YCSB-style operation streams for set benchmarks:
+// a mix of operations given in percents, a key space, initial fill ratio and key distribution.
+package workload
+
+import (
+	"errors"
+	"fmt"
+	"strconv"
+	"strings"
+)
+
+// Set mirrors the interface of the sets under test (it can't be imported because of import cycle).
+type Set interface {
+	Insert(value int) bool
+	Contains(value int) bool
+	Remove(value int) bool
+}
+
+// Op is a kind of set operation.
+type Op int8
+
+// Kinds of set operations.
+const (
+	Contains Op = iota
+	Insert
+	Remove
+	opCount
+)
+
+func (op Op) String() string {
+	switch op {
+	case Contains:
+		return "contains"
+	case Insert:
+		return "insert"
+	case Remove:
+		return "remove"
+	default:
+		panic("unknown Op")
+	}
+}
+
+// Ops lists all kinds of operations.
+func Ops() []Op {
+	return []Op{Contains, Insert, Remove}
+}
+
+// Mix contains the shares of operations in percents; they must sum up to 100.
+type Mix struct {
+	Contains int
+	Insert   int
+	Remove   int
+}
+
+func (m Mix) String() string {
+	return fmt.Sprintf("%d/%d/%d", m.Contains, m.Insert, m.Remove)
+}
+
+// invalidValueError points to the value that caused the error.
+type invalidValueError struct {
+	err   error
+	value string
+}
+
+func (e *invalidValueError) Error() string {
+	return fmt.Sprintf("%v: %q", e.err, e.value)
+}
+
+func (e *invalidValueError) Unwrap() error {
+	return e.err
+}
+
+var errInvalidMix = errors.New("operation mix must consist of three non-negative percents summing up to 100")
+
+// ParseMix parses the mix in the form "contains/insert/remove", e.g. "90/9/1".
+func ParseMix(s string) (Mix, error) {
+	parts := strings.Split(s, "/")
+	if len(parts) != int(opCount) {
+		return Mix{}, &invalidValueError{err: errInvalidMix, value: s}
+	}
+
+	var values [opCount]int
+
+	for i, part := range parts {
+		v, err := strconv.Atoi(strings.TrimSpace(part))
+		if err != nil {
+			return Mix{}, &invalidValueError{err: errInvalidMix, value: s}
+		}
+
+		values[i] = v
+	}
+
+	m := Mix{Contains: values[Contains], Insert: values[Insert], Remove: values[Remove]}
+
+	return m, m.validate()
+}
+
+func (m Mix) validate() error {
+	if m.Contains < 0 || m.Insert < 0 || m.Remove < 0 || m.Contains+m.Insert+m.Remove != 100 {
+		return &invalidValueError{err: errInvalidMix, value: m.String()}
+	}
+
+	return nil
+}
+
+// pick chooses the operation for a number in [0, 100).
+func (m Mix) pick(n int) Op {
+	switch {
+	case n < m.Contains:
+		return Contains
+	case n < m.Contains+m.Insert:
+		return Insert
+	default:
+		return Remove
+	}
+}
+
+// Distribution describes how keys are chosen.
+type Distribution int8
+
+// Supported key distributions.
+const (
+	// Uniform picks every key with the same probability.
+	Uniform Distribution = iota
+	// Zipfian makes a few keys very popular (YCSB scrambled Zipfian).
+	Zipfian
+	// Hotspot sends HotOpsFraction of operations to HotKeysFraction of keys.
+	Hotspot
+	// Sequential iterates over the key space in ascending order.
+	Sequential
+)
+
+func (d Distribution) String() string {
+	switch d {
+	case Uniform:
+		return "uniform"
+	case Zipfian:
+		return "zipfian"
+	case Hotspot:
+		return "hotspot"
+	case Sequential:
+		return "sequential"
+	default:
+		panic("unknown Distribution")
+	}
+}
+
+var errUnknownDistribution = errors.New("unknown distribution")
+
+// ParseDistribution parses the name of distribution.
+func ParseDistribution(s string) (Distribution, error) {
+	for _, d := range []Distribution{Uniform, Zipfian, Hotspot, Sequential} {
+		if d.String() == s {
+			return d, nil
+		}
+	}
+
+	return 0, &invalidValueError{err: errUnknownDistribution, value: s}
+}
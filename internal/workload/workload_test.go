@@ -0,0 +1,231 @@
+package workload
+
+import (
+	"errors"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+type mapSet map[int]struct{}
+
+func (m mapSet) Insert(value int) bool {
+	_, exists := m[value]
+	m[value] = struct{}{}
+
+	return !exists
+}
+
+func (m mapSet) Contains(value int) bool {
+	_, exists := m[value]
+
+	return exists
+}
+
+func (m mapSet) Remove(value int) bool {
+	_, exists := m[value]
+	delete(m, value)
+
+	return exists
+}
+
+func TestParseMix(t *testing.T) {
+	m, err := ParseMix("90/9/1")
+	require.NoError(t, err)
+	require.Equal(t, Mix{Contains: 90, Insert: 9, Remove: 1}, m)
+	require.Equal(t, "90/9/1", m.String())
+
+	for _, s := range []string{"", "90/10", "90/9/2", "-10/100/10", "a/b/c"} {
+		_, err := ParseMix(s)
+		require.True(t, errors.Is(err, errInvalidMix), s)
+	}
+}
+
+func TestParseDistribution(t *testing.T) {
+	for _, d := range []Distribution{Uniform, Zipfian, Hotspot, Sequential} {
+		parsed, err := ParseDistribution(d.String())
+		require.NoError(t, err)
+		require.Equal(t, d, parsed)
+	}
+
+	_, err := ParseDistribution("gaussian")
+	require.True(t, errors.Is(err, errUnknownDistribution))
+}
+
+func TestValidate(t *testing.T) {
+	c := Config{Mix: Presets["read_mostly"], KeySpace: 100}
+	require.NoError(t, c.Validate())
+	require.Equal(t, DefaultZipfianTheta, c.ZipfianTheta)
+	require.Equal(t, "90_9_1_uniform_100_keys", c.Name())
+
+	c = Config{Mix: Presets["read_mostly"]}
+	require.True(t, errors.Is(c.Validate(), errKeySpace))
+
+	c = Config{Mix: Presets["read_mostly"], KeySpace: 100, InitialFill: 1.5}
+	require.True(t, errors.Is(c.Validate(), errFraction))
+
+	c = Config{Mix: Presets["read_mostly"], KeySpace: 100, ZipfianTheta: 1}
+	require.True(t, errors.Is(c.Validate(), errTheta))
+
+	// unset fractions take the defaults, explicit zeros are kept
+	c = Config{Mix: Presets["read_mostly"], KeySpace: 100, Distribution: Hotspot, HotOpsFraction: Fraction(0)}
+	require.NoError(t, c.Validate())
+	require.Equal(t, DefaultHotKeysFraction, *c.HotKeysFraction)
+	require.Equal(t, 0.0, *c.HotOpsFraction)
+
+	c = Config{Mix: Presets["read_mostly"], KeySpace: 100, HotKeysFraction: Fraction(-0.1)}
+	require.True(t, errors.Is(c.Validate(), errFraction))
+
+	c = Config{Mix: Presets["read_mostly"], KeySpace: 100, HotOpsFraction: Fraction(1.1)}
+	require.True(t, errors.Is(c.Validate(), errFraction))
+}
+
+func TestFill(t *testing.T) {
+	c := Config{Mix: Presets["balanced"], KeySpace: 1000, InitialFill: 0.5, Seed: 1}
+	s := mapSet{}
+	c.Fill(s)
+
+	require.Len(t, s, 500)
+
+	for key := range s {
+		require.True(t, key >= 0 && key < c.KeySpace, key)
+	}
+}
+
+// count runs n operations and returns the number of operations of every kind and of every key.
+func count(t *testing.T, c Config, n int) (ops map[Op]int, keys []int) {
+	t.Helper()
+
+	w, err := New(c)
+	require.NoError(t, err)
+
+	g := w.Generator(0)
+	ops = make(map[Op]int)
+	keys = make([]int, c.KeySpace)
+
+	for i := 0; i < n; i++ {
+		op, key := g.Next()
+		require.True(t, key >= 0 && key < c.KeySpace, key)
+
+		ops[op]++
+		keys[key]++
+	}
+
+	return ops, keys
+}
+
+func TestMix(t *testing.T) {
+	const n = 100000
+
+	ops, _ := count(t, Config{Mix: Presets["read_mostly"], KeySpace: 100}, n)
+
+	require.InDelta(t, 0.90, float64(ops[Contains])/n, 0.01)
+	require.InDelta(t, 0.09, float64(ops[Insert])/n, 0.01)
+	require.InDelta(t, 0.01, float64(ops[Remove])/n, 0.005)
+}
+
+func TestDistributions(t *testing.T) {
+	const (
+		n        = 200000
+		keySpace = 1000
+	)
+
+	mix := Presets["read_only"]
+
+	t.Run("uniform", func(t *testing.T) {
+		_, keys := count(t, Config{Mix: mix, KeySpace: keySpace, Distribution: Uniform}, n)
+
+		for key, hits := range keys {
+			require.InDelta(t, n/keySpace, hits, n/keySpace/2, key)
+		}
+	})
+
+	t.Run("zipfian", func(t *testing.T) {
+		_, keys := count(t, Config{Mix: mix, KeySpace: keySpace, Distribution: Zipfian}, n)
+
+		var top int
+
+		for _, hits := range keys {
+			if hits > top {
+				top = hits
+			}
+		}
+
+		// with theta 0.99 the most popular of 1000 keys gets about 1/zeta(1000) ≈ 13% of operations
+		require.InDelta(t, 0.13, float64(top)/n, 0.02)
+	})
+
+	t.Run("hotspot", func(t *testing.T) {
+		c := Config{Mix: mix, KeySpace: keySpace, Distribution: Hotspot}
+		_, keys := count(t, c, n)
+
+		w, err := New(c)
+		require.NoError(t, err)
+
+		var hot int
+		for _, key := range w.permutation[:keySpace/5] {
+			hot += keys[key]
+		}
+
+		require.InDelta(t, DefaultHotOpsFraction, float64(hot)/n, 0.01)
+
+		// no operation goes to the hot keys
+		c.HotOpsFraction = Fraction(0)
+		_, keys = count(t, c, n)
+
+		hot = 0
+		for _, key := range w.permutation[:keySpace/5] {
+			hot += keys[key]
+		}
+
+		require.Zero(t, hot)
+	})
+
+	t.Run("sequential", func(t *testing.T) {
+		w, err := New(Config{Mix: mix, KeySpace: 10, Distribution: Sequential})
+		require.NoError(t, err)
+
+		g := w.Generator(0)
+		_, prev := g.Next()
+
+		for i := 0; i < 100; i++ {
+			_, key := g.Next()
+			require.Equal(t, (prev+1)%10, key)
+
+			prev = key
+		}
+	})
+}
+
+func TestDeterminism(t *testing.T) {
+	c := Config{Mix: Presets["balanced"], KeySpace: 100, Distribution: Zipfian, Seed: 42}
+
+	w1, err := New(c)
+	require.NoError(t, err)
+
+	w2, err := New(c)
+	require.NoError(t, err)
+
+	g1, g2 := w1.Generator(3), w2.Generator(3)
+
+	for i := 0; i < 1000; i++ {
+		op1, key1 := g1.Next()
+		op2, key2 := g2.Next()
+
+		require.Equal(t, op1, op2)
+		require.Equal(t, key1, key2)
+	}
+}
+
+func TestExecute(t *testing.T) {
+	c := Config{Mix: Presets["write_heavy"], KeySpace: 64, InitialFill: 0.5}
+
+	w, err := New(c)
+	require.NoError(t, err)
+
+	s := mapSet{}
+	c.Fill(s)
+	w.Generator(0).Execute(s, 1000)
+
+	require.NotEmpty(t, s)
+}
@@ -0,0 +1,48 @@
+package set
+
+import (
+	"errors"
+	"fmt"
+)
+
+// invariantChecker verifies that the list behind a set is well-formed:
+// the values between the sentinels are strictly ascending (which also rules out cycles),
+// no logically removed node is reachable, and the list ends with the tail sentinel.
+// MVCC set keeps several versions of a value next to each other, and they must not overlap.
+// The check is meaningful only after quiescence, when there are no operations in progress.
+type invariantChecker interface {
+	checkInvariants() error
+}
+
+var (
+	_ invariantChecker = (*sequentialSet)(nil)
+	_ invariantChecker = (*coarseGrainedSyncSet)(nil)
+	_ invariantChecker = (*fineGrainedSyncSet)(nil)
+	_ invariantChecker = (*optimisticSyncSet)(nil)
+	_ invariantChecker = (*lazySyncSet)(nil)
+	_ invariantChecker = (*nonBlockingSet)(nil)
+	_ invariantChecker = (*hazardNonBlockingSet)(nil)
+)
+
+var (
+	errWrongHead       = errors.New("head is not a sentinel")
+	errNotAscending    = errors.New("values are not strictly ascending")
+	errMarkedReachable = errors.New("logically removed node is reachable")
+	errTailUnreachable = errors.New("tail sentinel is unreachable")
+	errVersionsOverlap = errors.New("versions of a value overlap")
+)
+
+// invariantError points to the node violating the invariant.
+type invariantError struct {
+	err      error
+	position int
+	value    int
+}
+
+func (e *invariantError) Error() string {
+	return fmt.Sprintf("%v: node #%d with value %d", e.err, e.position, e.value)
+}
+
+func (e *invariantError) Unwrap() error {
+	return e.err
+}
@@ -0,0 +1,95 @@
+package set
+
+import (
+	"errors"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+// TestCheckInvariants verifies that structural corruptions are detected.
+func TestCheckInvariants(t *testing.T) {
+	f := factory{}
+
+	kinds := []setKind{
+		sequential,
+		coarseGrained,
+		fineGrained,
+		optimistic,
+		lazy,
+		nonBlocking,
+		hazardNonBlocking,
+		stm,
+		mvcc,
+	}
+
+	for _, k := range kinds {
+		k := k
+
+		t.Run(k.String(), func(t *testing.T) {
+			set := f.new(k)
+
+			for _, v := range []int{3, 1, 2} {
+				require.True(t, set.Insert(v))
+			}
+
+			checker, ok := set.(invariantChecker)
+			require.True(t, ok)
+			require.NoError(t, checker.checkInvariants())
+		})
+	}
+
+	t.Run("not ascending", func(t *testing.T) {
+		s, ok := NewSequentialSet().(*sequentialSet)
+		require.True(t, ok)
+		require.True(t, s.Insert(1))
+		require.True(t, s.Insert(2))
+
+		s.head.next.value = 5
+
+		require.True(t, errors.Is(s.checkInvariants(), errNotAscending))
+	})
+
+	t.Run("cycle", func(t *testing.T) {
+		s, ok := NewFineGrainedSyncSet().(*fineGrainedSyncSet)
+		require.True(t, ok)
+		require.True(t, s.Insert(1))
+		require.True(t, s.Insert(2))
+
+		s.head.getNext().getNext().setNext(s.head.getNext())
+
+		require.True(t, errors.Is(s.checkInvariants(), errNotAscending))
+	})
+
+	t.Run("tail unreachable", func(t *testing.T) {
+		s, ok := NewOptimisticSyncSet().(*optimisticSyncSet)
+		require.True(t, ok)
+		require.True(t, s.Insert(1))
+
+		s.head.getNext().setNext(nil)
+
+		require.True(t, errors.Is(s.checkInvariants(), errTailUnreachable))
+	})
+
+	t.Run("marked lazy node", func(t *testing.T) {
+		s, ok := NewLazySyncSet().(*lazySyncSet)
+		require.True(t, ok)
+		require.True(t, s.Insert(1))
+
+		s.head.getNext().marked = true
+
+		require.True(t, errors.Is(s.checkInvariants(), errMarkedReachable))
+	})
+
+	t.Run("marked non-blocking node", func(t *testing.T) {
+		s, ok := NewNonBlockingSyncSet().(*nonBlockingSet)
+		require.True(t, ok)
+		require.True(t, s.Insert(1))
+
+		curr := s.head.next.getNode()
+		succ := curr.next.getNode()
+		require.True(t, curr.next.compareAndSet(succ, succ, false, true))
+
+		require.True(t, errors.Is(s.checkInvariants(), errMarkedReachable))
+	})
+}
@@ -0,0 +1,27 @@
+package set
+
+import (
+	"unsafe"
+)
+
+// cacheLineSize is the size of the cache line on the most of modern AMD and Intel CPUs.
+const cacheLineSize = 64
+
+// Sizes of hot fields of the list nodes. Padded layout (see layout_padded.go) completes every node
+// to the full cache line, so that the nodes allocated next to each other never share it.
+const (
+	wordSize = unsafe.Sizeof(uintptr(0))
+
+	// next + mutex + value.
+	syncNodeSize = wordSize + unsafe.Sizeof(nodeMutex{}) + wordSize
+	// next + value + mutex + marked and generation (aligned to 32 bits).
+	lazySyncNodeSize = wordSize + wordSize + unsafe.Sizeof(nodeMutex{}) + 2*unsafe.Sizeof(uint32(0))
+	// next + value + generation (aligned to the word).
+	nonBlockingNodeSize = wordSize + wordSize + wordSize
+	// ref.
+	atomicMarkableReferenceSize = wordSize
+	// versioned lock + next + value.
+	stmNodeSize = unsafe.Sizeof(uint64(0)) + wordSize + wordSize
+	// begin + end + next + value + mutex + marked (aligned to the word).
+	mvccNodeSize = 2*unsafe.Sizeof(uint64(0)) + wordSize + wordSize + unsafe.Sizeof(nodeMutex{}) + wordSize
+)
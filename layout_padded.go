@@ -0,0 +1,16 @@
+//go:build padded
+// +build padded
+
+package set
+
+// nodeLayout names the layout of list nodes chosen at build time.
+const nodeLayout = "padded"
+
+type (
+	syncNodePad                [cacheLineSize - syncNodeSize]byte
+	lazySyncNodePad            [cacheLineSize - lazySyncNodeSize]byte
+	nonBlockingNodePad         [cacheLineSize - nonBlockingNodeSize]byte
+	atomicMarkableReferencePad [cacheLineSize - atomicMarkableReferenceSize]byte
+	stmNodePad                 [cacheLineSize - stmNodeSize]byte
+	mvccNodePad                [cacheLineSize - mvccNodeSize]byte
+)
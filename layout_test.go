@@ -0,0 +1,41 @@
+package set
+
+import (
+	"testing"
+	"unsafe"
+
+	"github.com/stretchr/testify/require"
+)
+
+// TestNodeLayout verifies that padded nodes occupy whole cache lines and unpadded nodes have no overhead.
+func TestNodeLayout(t *testing.T) {
+	testCases := []struct {
+		name     string
+		actual   uintptr
+		hotBytes uintptr
+	}{
+		{name: "syncNode", actual: unsafe.Sizeof(syncNode{}), hotBytes: syncNodeSize},
+		{name: "lazySyncNode", actual: unsafe.Sizeof(lazySyncNode{}), hotBytes: lazySyncNodeSize},
+		{name: "nonBlockingNode", actual: unsafe.Sizeof(nonBlockingNode{}), hotBytes: nonBlockingNodeSize},
+		{
+			name:     "atomicMarkableReference",
+			actual:   unsafe.Sizeof(atomicMarkableReference{}),
+			hotBytes: atomicMarkableReferenceSize,
+		},
+		{name: "stmNode", actual: unsafe.Sizeof(stmNode{}), hotBytes: stmNodeSize},
+		{name: "mvccNode", actual: unsafe.Sizeof(mvccNode{}), hotBytes: mvccNodeSize},
+	}
+
+	for _, tc := range testCases {
+		tc := tc
+
+		t.Run(tc.name, func(t *testing.T) {
+			switch nodeLayout {
+			case "padded":
+				require.Equal(t, uintptr(cacheLineSize), tc.actual)
+			default:
+				require.Equal(t, tc.hotBytes, tc.actual)
+			}
+		})
+	}
+}
@@ -0,0 +1,18 @@
+//go:build !padded
+// +build !padded
+
+package set
+
+// nodeLayout names the layout of list nodes chosen at build time.
+const nodeLayout = "unpadded"
+
+// Zero-sized pads must stay the first fields of the nodes:
+// a zero-sized trailing field makes the compiler grow the struct.
+type (
+	syncNodePad                [0]byte
+	lazySyncNodePad            [0]byte
+	nonBlockingNodePad         [0]byte
+	atomicMarkableReferencePad [0]byte
+	stmNodePad                 [0]byte
+	mvccNodePad                [0]byte
+)
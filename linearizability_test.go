@@ -0,0 +1,77 @@
+package set
+
+import (
+	"math/rand"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+
+	"github.com/vitalyisaev2/linked_list_set/internal/linearizability"
+)
+
+// TestLinearizability records concurrent histories of various set implementations
+// and checks that the results of every operation are consistent with some sequential execution.
+func TestLinearizability(t *testing.T) {
+	f := factory{}
+
+	kinds := []setKind{
+		coarseGrained,
+		fineGrained,
+		optimistic,
+		lazy,
+		nonBlocking,
+		hazardNonBlocking,
+		stm,
+		mvcc,
+	}
+
+	const (
+		clients = 8
+		ops     = 500
+		// small key space makes operations on the same key overlap
+		keys = 16
+	)
+
+	seed := time.Now().UnixNano()
+
+	kindsOfOps := []linearizability.Kind{
+		linearizability.Insert,
+		linearizability.Contains,
+		linearizability.Remove,
+	}
+
+	for _, k := range kinds {
+		k := k
+
+		t.Run(k.String(), func(t *testing.T) {
+			set := f.new(k)
+			recorder := linearizability.NewRecorder(clients)
+
+			wg := sync.WaitGroup{}
+			wg.Add(clients)
+
+			for i := 0; i < clients; i++ {
+				client := recorder.Client(i)
+				rng := rand.New(rand.NewSource(seed + int64(i)))
+
+				go func() {
+					defer wg.Done()
+
+					for j := 0; j < ops; j++ {
+						client.Do(set, kindsOfOps[rng.Intn(len(kindsOfOps))], rng.Intn(keys))
+					}
+				}()
+			}
+
+			wg.Wait()
+
+			require.NoError(t, linearizability.Check(recorder.History()), "seed %d", seed)
+
+			checker, ok := set.(invariantChecker)
+			require.True(t, ok)
+			require.NoError(t, checker.checkInvariants())
+		})
+	}
+}
@@ -0,0 +1,121 @@
+package metrics
+
+import (
+	"bufio"
+	"expvar"
+	"net/http"
+	"strconv"
+	"strings"
+)
+
+// Var returns expvar variable rendering the snapshots of all instances as a JSON object keyed by name.
+func (r *Registry) Var() expvar.Var {
+	return expvar.Func(func() interface{} {
+		byName := make(map[string]Snapshot)
+		for _, s := range r.Snapshots() {
+			byName[s.Name] = s
+		}
+
+		return byName
+	})
+}
+
+// PublishExpvar publishes the registry in expvar under the name (served by expvar handler at /debug/vars).
+// Like expvar.Publish, it panics if the name is already published.
+func (r *Registry) PublishExpvar(name string) {
+	expvar.Publish(name, r.Var())
+}
+
+// namespace prefixes the names of Prometheus metrics.
+const namespace = "linked_list_set_"
+
+// Handler serves the metrics in Prometheus text exposition format.
+func (r *Registry) Handler() http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, _ *http.Request) {
+		w.Header().Set("Content-Type", "text/plain; version=0.0.4; charset=utf-8")
+
+		bw := bufio.NewWriter(w)
+		writePrometheus(bw, r.Snapshots())
+		_ = bw.Flush()
+	})
+}
+
+// family is a metric with all its samples.
+type family struct {
+	name, help, kind string
+	value            func(s *Snapshot) float64
+}
+
+//nolint:gochecknoglobals // read-only descriptions
+var families = []family{
+	{"size", "Number of elements in the set.", "gauge",
+		func(s *Snapshot) float64 { return float64(s.Size) }},
+	{"retries_total", "Retries of optimistic and non-blocking operations.", "counter",
+		func(s *Snapshot) float64 { return float64(s.Retries) }},
+	{"cas_failures_total", "Failed compare-and-set operations (requires stats build tag).", "counter",
+		func(s *Snapshot) float64 { return float64(s.CASFailures) }},
+	{"validation_failures_total", "Failed validations of optimistic and lazy sets (requires stats build tag).", "counter",
+		func(s *Snapshot) float64 { return float64(s.ValidationFailures) }},
+	{"lock_wait_seconds_total", "Time spent waiting for contended locks (requires stats build tag).", "counter",
+		func(s *Snapshot) float64 { return s.LockWaitSeconds }},
+}
+
+func writePrometheus(w *bufio.Writer, snapshots []Snapshot) {
+	name := namespace + "operations_total"
+	writeHeader(w, name, "Calls of set operations by result.", "counter")
+
+	for ix := range snapshots {
+		s := &snapshots[ix]
+
+		for _, op := range opNames {
+			for _, result := range []string{"false", "true"} {
+				writeSample(w, name, s.Calls[op][result], "set", s.Name, "operation", op, "result", result)
+			}
+		}
+	}
+
+	for _, f := range families {
+		name := namespace + f.name
+		writeHeader(w, name, f.help, f.kind)
+
+		for ix := range snapshots {
+			writeSample(w, name, f.value(&snapshots[ix]), "set", snapshots[ix].Name)
+		}
+	}
+}
+
+func writeHeader(w *bufio.Writer, name, help, kind string) {
+	w.WriteString("# HELP " + name + " " + help + "\n")
+	w.WriteString("# TYPE " + name + " " + kind + "\n")
+}
+
+// writeSample writes the sample with labels given as name-value pairs.
+func writeSample(w *bufio.Writer, name string, value interface{}, labels ...string) {
+	w.WriteString(name + "{")
+
+	for i := 0; i+1 < len(labels); i += 2 {
+		if i > 0 {
+			w.WriteString(",")
+		}
+
+		w.WriteString(labels[i] + `="` + escapeLabel(labels[i+1]) + `"`)
+	}
+
+	w.WriteString("} ")
+
+	switch v := value.(type) {
+	case int64:
+		w.WriteString(strconv.FormatInt(v, 10))
+	case float64:
+		w.WriteString(strconv.FormatFloat(v, 'g', -1, 64))
+	}
+
+	w.WriteString("\n")
+}
+
+//nolint:gochecknoglobals // read-only replacer
+var labelEscaper = strings.NewReplacer(`\`, `\\`, `"`, `\"`, "\n", `\n`)
+
+func escapeLabel(s string) string {
+	return labelEscaper.Replace(s)
+}
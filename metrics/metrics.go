@@ -0,0 +1,235 @@
+// Package metrics exposes per-instance metrics of sets through expvar and Prometheus text format.
+//
+// A set is registered under a name and wrapped, so that the wrapper counts operations and tracks the size:
+//
+//	registry := metrics.NewRegistry()
+//	retries := metrics.CountRetries(set.NewExponentialBackoff(time.Microsecond, time.Millisecond))
+//	users, err := registry.Register("users", set.NewLazySyncSet(set.WithContentionManager(retries)), metrics.WithRetries(retries))
+//	http.Handle("/metrics", registry.Handler())
+//
+// Retries are counted by the contention manager passed to the set. CAS failures, validation failures
+// and lock wait time come from set.Stats, so they stay zero unless the set package is built with `-tags stats`.
+package metrics
+
+import (
+	"errors"
+	"sort"
+	"sync"
+	"sync/atomic"
+
+	set "github.com/vitalyisaev2/linked_list_set"
+)
+
+var (
+	_ set.ContentionManager = (*RetryCounter)(nil)
+	_ set.Set               = (*Instance)(nil)
+)
+
+// RetryCounter is a contention manager counting the retries before delegating to the wrapped one.
+type RetryCounter struct {
+	next    set.ContentionManager
+	retries int64
+}
+
+// CountRetries wraps the contention manager; nil means no backoff.
+func CountRetries(next set.ContentionManager) *RetryCounter {
+	if next == nil {
+		next = set.NewNoContentionManager()
+	}
+
+	return &RetryCounter{next: next}
+}
+
+// Backoff implements set.ContentionManager.
+func (c *RetryCounter) Backoff(attempt int) {
+	atomic.AddInt64(&c.retries, 1)
+	c.next.Backoff(attempt)
+}
+
+// Retries returns the number of retries so far.
+func (c *RetryCounter) Retries() int64 {
+	return atomic.LoadInt64(&c.retries)
+}
+
+// RegisterOption configures a registered instance.
+type RegisterOption func(*Instance)
+
+// WithRetries reports the retries counted by the contention manager of the set.
+func WithRetries(c *RetryCounter) RegisterOption {
+	return func(i *Instance) {
+		i.retries = c
+	}
+}
+
+// kinds of operations; the order defines the order of exported metrics.
+const (
+	opInsert = iota
+	opContains
+	opRemove
+	opCount
+)
+
+//nolint:gochecknoglobals // read-only names
+var opNames = [opCount]string{"insert", "contains", "remove"}
+
+// Instance is a registered set: it implements set.Set and counts the calls.
+// The size is tracked from the results of the calls, so all operations must go through the instance,
+// and the set must be empty when it's registered.
+type Instance struct {
+	inner   set.Set
+	name    string
+	size    int64
+	calls   [opCount][2]int64 // by result: false, true
+	retries *RetryCounter
+}
+
+func (i *Instance) count(op int, result bool) bool {
+	ix := 0
+	if result {
+		ix = 1
+	}
+
+	atomic.AddInt64(&i.calls[op][ix], 1)
+
+	return result
+}
+
+// Insert implements set.Set.
+func (i *Instance) Insert(value int) bool {
+	result := i.inner.Insert(value)
+	if result {
+		atomic.AddInt64(&i.size, 1)
+	}
+
+	return i.count(opInsert, result)
+}
+
+// Contains implements set.Set.
+func (i *Instance) Contains(value int) bool {
+	return i.count(opContains, i.inner.Contains(value))
+}
+
+// Remove implements set.Set.
+func (i *Instance) Remove(value int) bool {
+	result := i.inner.Remove(value)
+	if result {
+		atomic.AddInt64(&i.size, -1)
+	}
+
+	return i.count(opRemove, result)
+}
+
+// Name returns the name the instance is registered under.
+func (i *Instance) Name() string {
+	return i.name
+}
+
+// Snapshot contains the metrics of an instance at some moment.
+type Snapshot struct {
+	Name string `json:"name"`
+	Size int64  `json:"size"`
+	// Calls counts the calls of every operation by result: "insert" -> "true" -> 10.
+	Calls              map[string]map[string]int64 `json:"calls"`
+	Retries            int64                       `json:"retries"`
+	CASFailures        int64                       `json:"cas_failures"`
+	ValidationFailures int64                       `json:"validation_failures"`
+	LockWaitSeconds    float64                     `json:"lock_wait_seconds"`
+}
+
+// Snapshot collects the current values of the metrics.
+func (i *Instance) Snapshot() Snapshot {
+	s := Snapshot{
+		Name:  i.name,
+		Size:  atomic.LoadInt64(&i.size),
+		Calls: make(map[string]map[string]int64, opCount),
+	}
+
+	for op, name := range opNames {
+		s.Calls[name] = map[string]int64{
+			"false": atomic.LoadInt64(&i.calls[op][0]),
+			"true":  atomic.LoadInt64(&i.calls[op][1]),
+		}
+	}
+
+	if i.retries != nil {
+		s.Retries = i.retries.Retries()
+	}
+
+	if provider, ok := i.inner.(set.StatsProvider); ok {
+		stats := provider.Stats()
+		s.CASFailures = stats.CASFailures
+		s.ValidationFailures = stats.ValidationFailures
+		s.LockWaitSeconds = stats.LockWait.Seconds()
+	}
+
+	return s
+}
+
+// ErrDuplicateName is returned if the name is already registered.
+var ErrDuplicateName = errors.New("set with this name is already registered")
+
+// ErrEmptyName is returned if the name is empty.
+var ErrEmptyName = errors.New("set name must not be empty")
+
+// Registry keeps the instances by name.
+type Registry struct {
+	mutex     sync.RWMutex
+	instances map[string]*Instance
+}
+
+// NewRegistry returns an empty registry.
+func NewRegistry() *Registry {
+	return &Registry{instances: make(map[string]*Instance)}
+}
+
+// Register wraps the set and registers it under the name.
+func (r *Registry) Register(name string, s set.Set, opts ...RegisterOption) (*Instance, error) {
+	if name == "" {
+		return nil, ErrEmptyName
+	}
+
+	i := &Instance{inner: s, name: name}
+	for _, opt := range opts {
+		opt(i)
+	}
+
+	r.mutex.Lock()
+	defer r.mutex.Unlock()
+
+	if _, ok := r.instances[name]; ok {
+		return nil, ErrDuplicateName
+	}
+
+	r.instances[name] = i
+
+	return i, nil
+}
+
+// Unregister removes the instance from the registry.
+func (r *Registry) Unregister(name string) {
+	r.mutex.Lock()
+	defer r.mutex.Unlock()
+
+	delete(r.instances, name)
+}
+
+// Snapshots returns the metrics of all instances sorted by name.
+func (r *Registry) Snapshots() []Snapshot {
+	r.mutex.RLock()
+
+	instances := make([]*Instance, 0, len(r.instances))
+	for _, i := range r.instances {
+		instances = append(instances, i)
+	}
+
+	r.mutex.RUnlock()
+
+	sort.Slice(instances, func(a, b int) bool { return instances[a].name < instances[b].name })
+
+	snapshots := make([]Snapshot, len(instances))
+	for ix, i := range instances {
+		snapshots[ix] = i.Snapshot()
+	}
+
+	return snapshots
+}
@@ -0,0 +1,145 @@
+package metrics
+
+import (
+	"encoding/json"
+	"errors"
+	"expvar"
+	"io"
+	"net/http/httptest"
+	"strings"
+	"sync"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+
+	set "github.com/vitalyisaev2/linked_list_set"
+)
+
+// fill runs concurrent writers: every writer inserts its own keys and removes every second one.
+func fill(i *Instance, writers, items int) {
+	wg := sync.WaitGroup{}
+	wg.Add(writers)
+
+	for w := 0; w < writers; w++ {
+		w := w
+
+		go func() {
+			defer wg.Done()
+
+			for j := 0; j < items; j++ {
+				key := w*items + j
+				i.Insert(key)
+				i.Insert(key)
+				i.Contains(key)
+
+				if j%2 == 0 {
+					i.Remove(key)
+				}
+			}
+		}()
+	}
+
+	wg.Wait()
+}
+
+func TestRegistry(t *testing.T) {
+	r := NewRegistry()
+	retries := CountRetries(nil)
+
+	lazy, err := r.Register("lazy", set.NewLazySyncSet(set.WithContentionManager(retries)), WithRetries(retries))
+	require.NoError(t, err)
+	require.Equal(t, "lazy", lazy.Name())
+
+	coarse, err := r.Register("coarse", set.NewCoarseGrainedSyncSet())
+	require.NoError(t, err)
+
+	_, err = r.Register("lazy", set.NewLazySyncSet())
+	require.True(t, errors.Is(err, ErrDuplicateName))
+
+	_, err = r.Register("", set.NewLazySyncSet())
+	require.True(t, errors.Is(err, ErrEmptyName))
+
+	const (
+		writers = 4
+		items   = 100
+	)
+
+	fill(lazy, writers, items)
+	fill(coarse, writers, items)
+
+	snapshots := r.Snapshots()
+	require.Len(t, snapshots, 2)
+	require.Equal(t, "coarse", snapshots[0].Name)
+
+	for _, s := range snapshots {
+		require.Equal(t, int64(writers*items/2), s.Size)
+		require.Equal(t, map[string]int64{"true": writers * items, "false": writers * items}, s.Calls["insert"])
+		require.Equal(t, map[string]int64{"true": writers * items, "false": 0}, s.Calls["contains"])
+		require.Equal(t, map[string]int64{"true": writers * items / 2, "false": 0}, s.Calls["remove"])
+	}
+
+	require.Equal(t, retries.Retries(), snapshots[1].Retries)
+
+	r.Unregister("coarse")
+	require.Len(t, r.Snapshots(), 1)
+}
+
+func TestPrometheusHandler(t *testing.T) {
+	r := NewRegistry()
+
+	i, err := r.Register(`odd "name"`, set.NewNonBlockingSyncSet())
+	require.NoError(t, err)
+
+	require.True(t, i.Insert(1))
+	require.True(t, i.Insert(2))
+	require.False(t, i.Remove(3))
+
+	server := httptest.NewServer(r.Handler())
+	defer server.Close()
+
+	resp, err := server.Client().Get(server.URL)
+	require.NoError(t, err)
+
+	defer resp.Body.Close()
+
+	require.Contains(t, resp.Header.Get("Content-Type"), "text/plain; version=0.0.4")
+
+	body, err := io.ReadAll(resp.Body)
+	require.NoError(t, err)
+
+	lines := strings.Split(string(body), "\n")
+
+	for _, expected := range []string{
+		`# TYPE linked_list_set_size gauge`,
+		`linked_list_set_size{set="odd \"name\""} 2`,
+		`# TYPE linked_list_set_operations_total counter`,
+		`linked_list_set_operations_total{set="odd \"name\"",operation="insert",result="true"} 2`,
+		`linked_list_set_operations_total{set="odd \"name\"",operation="remove",result="false"} 1`,
+		`linked_list_set_retries_total{set="odd \"name\""} 0`,
+		`linked_list_set_lock_wait_seconds_total{set="odd \"name\""} 0`,
+	} {
+		require.Contains(t, lines, expected)
+	}
+}
+
+func TestExpvar(t *testing.T) {
+	r := NewRegistry()
+
+	i, err := r.Register("fine", set.NewFineGrainedSyncSet())
+	require.NoError(t, err)
+
+	require.True(t, i.Insert(1))
+
+	r.PublishExpvar("linked_list_set_test")
+
+	rec := httptest.NewRecorder()
+	expvar.Handler().ServeHTTP(rec, httptest.NewRequest("GET", "/debug/vars", nil))
+
+	var vars struct {
+		Sets map[string]Snapshot `json:"linked_list_set_test"`
+	}
+
+	require.NoError(t, json.Unmarshal(rec.Body.Bytes(), &vars))
+	require.Equal(t, int64(1), vars.Sets["fine"].Size)
+	require.Equal(t, int64(1), vars.Sets["fine"].Calls["insert"]["true"])
+}
@@ -0,0 +1,64 @@
+package set
+
+import (
+	"github.com/vitalyisaev2/linked_list_set/internal/epoch"
+)
+
+// Option configures optional behaviour of a set instance.
+type Option func(*options)
+
+type options struct {
+	contentionManager ContentionManager
+	hooks             Hooks
+	nodeRecycling     bool
+	tracing           bool
+}
+
+func newOptions(opts []Option) *options {
+	o := &options{
+		contentionManager: NewNoContentionManager(),
+	}
+
+	for _, opt := range opts {
+		opt(o)
+	}
+
+	return o
+}
+
+// WithContentionManager sets the policy applied between retries of failed optimistic attempts.
+// It affects only implementations with retry loops (optimistic, lazy, non-blocking, STM and MVCC sets).
+func WithContentionManager(cm ContentionManager) Option {
+	return func(o *options) {
+		if cm != nil {
+			o.contentionManager = cm
+		}
+	}
+}
+
+// WithHooks installs the callbacks invoked at the key points of operations (see Hooks).
+// It affects all implementations.
+func WithHooks(h Hooks) Option {
+	return func(o *options) {
+		o.hooks = h
+	}
+}
+
+// WithNodeRecycling makes removed nodes reusable by subsequent insertions. Epoch-based reclamation
+// guarantees that a node is recycled only when no concurrent traversal can hold it.
+// It affects only lazy and non-blocking sets.
+func WithNodeRecycling() Option {
+	return func(o *options) {
+		o.nodeRecycling = true
+	}
+}
+
+// newEpochDomain returns reclamation domain for the given number of object classes if node recycling is enabled.
+// Nil domain is valid: it makes all reclamation calls no-op.
+func (o *options) newEpochDomain(classes int) *epoch.Domain {
+	if !o.nodeRecycling {
+		return nil
+	}
+
+	return epoch.NewDomain(classes, 0)
+}
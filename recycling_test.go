@@ -0,0 +1,108 @@
+package set
+
+import (
+	"sync"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+// TestNodeRecycling verifies that removed nodes are reused after the grace period.
+func TestNodeRecycling(t *testing.T) {
+	// enough operations to let the epoch advance twice
+	const idleOps = 1000
+
+	t.Run(lazy.String(), func(t *testing.T) {
+		s, ok := NewLazySyncSet(WithNodeRecycling()).(*lazySyncSet)
+		require.True(t, ok)
+
+		require.True(t, s.Insert(1))
+		removed := s.head.getNext()
+		require.True(t, s.Remove(1))
+
+		for i := 0; i < idleOps; i++ {
+			s.Contains(1)
+		}
+
+		require.True(t, s.Insert(2))
+		require.Same(t, removed, s.head.getNext())
+		require.Equal(t, 2, removed.value)
+		require.False(t, removed.marked)
+	})
+
+	t.Run(nonBlocking.String(), func(t *testing.T) {
+		s, ok := NewNonBlockingSyncSet(WithNodeRecycling()).(*nonBlockingSet)
+		require.True(t, ok)
+
+		require.True(t, s.Insert(1))
+		removed := s.head.next.getNode()
+		require.True(t, s.Remove(1))
+
+		for i := 0; i < idleOps; i++ {
+			s.Contains(1)
+		}
+
+		require.True(t, s.Insert(2))
+		require.Same(t, removed, s.head.next.getNode())
+		require.Equal(t, 2, removed.value)
+		require.False(t, removed.next.getMark())
+	})
+}
+
+// TestNodeRecyclingConcurrent stresses recycling with concurrent insertions and removals of the same keys.
+func TestNodeRecyclingConcurrent(t *testing.T) {
+	f := factory{}
+
+	kinds := []setKind{
+		lazy,
+		nonBlocking,
+	}
+
+	const (
+		threads = 8
+		items   = 256
+		rounds  = 20
+	)
+
+	for _, k := range kinds {
+		k := k
+
+		t.Run(k.String(), func(t *testing.T) {
+			set := f.new(k, WithNodeRecycling())
+
+			wg := sync.WaitGroup{}
+			wg.Add(threads)
+
+			for i := 0; i < threads; i++ {
+				i := i
+
+				go func() {
+					defer wg.Done()
+
+					for r := 0; r < rounds; r++ {
+						for j := 0; j < items; j++ {
+							if (i+j)%2 == 0 {
+								set.Insert(j)
+							} else {
+								set.Remove(j)
+							}
+						}
+					}
+				}()
+			}
+
+			wg.Wait()
+
+			// the structure must stay consistent after churn
+			for j := 0; j < items; j++ {
+				set.Insert(j)
+			}
+
+			for j := 0; j < items; j++ {
+				require.True(t, set.Contains(j), j)
+				require.True(t, set.Remove(j), j)
+				require.False(t, set.Contains(j), j)
+			}
+		})
+	}
+}
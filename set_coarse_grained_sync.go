@@ -1,40 +1,147 @@
 package set
 
-import (
-	"sync"
-)
-
 var _ Set = (*coarseGrainedSyncSet)(nil)
 
 type coarseGrainedSyncSet struct {
 	sequentialSet Set
-	mutex         sync.RWMutex
+	stats         statsCounters
+	mutex         setMutex
+	hooks         Hooks
 }
 
 func (c *coarseGrainedSyncSet) Insert(value int) bool {
-	c.mutex.Lock()
+	c.stats.operation()
+	c.stats.lockSet(&c.mutex)
 	defer c.mutex.Unlock()
 
-	return c.sequentialSet.Insert(value)
+	c.hooks.locked(OperationInsert, value)
+
+	if !c.sequentialSet.Insert(value) {
+		c.hooks.failed(OperationInsert, value)
+
+		return false
+	}
+
+	c.hooks.inserted(value)
+
+	return true
 }
 
 func (c *coarseGrainedSyncSet) Contains(value int) bool {
-	c.mutex.RLock()
+	c.stats.operation()
+	c.stats.rlockSet(&c.mutex)
 	defer c.mutex.RUnlock()
 
-	return c.sequentialSet.Contains(value)
+	c.hooks.locked(OperationContains, value)
+
+	return c.hooks.result(OperationContains, value, c.sequentialSet.Contains(value))
 }
 
 func (c *coarseGrainedSyncSet) Remove(value int) bool {
-	c.mutex.Lock()
+	c.stats.operation()
+	c.stats.lockSet(&c.mutex)
 	defer c.mutex.Unlock()
 
-	return c.sequentialSet.Remove(value)
+	c.hooks.locked(OperationRemove, value)
+
+	if !c.sequentialSet.Remove(value) {
+		c.hooks.failed(OperationRemove, value)
+
+		return false
+	}
+
+	c.hooks.removed(value)
+
+	return true
+}
+
+// Ascend holds the read lock during the whole walk, so the values form a snapshot of the set.
+func (c *coarseGrainedSyncSet) Ascend(yield func(value int) bool) {
+	c.mutex.RLock()
+	defer c.mutex.RUnlock()
+
+	if iterable, ok := c.sequentialSet.(Iterable); ok {
+		iterable.Ascend(yield)
+	}
+}
+
+// appender takes no lock: the new set is not shared until it's built.
+func (c *coarseGrainedSyncSet) appender() func(value int) {
+	appendValue := appenderOf(c.sequentialSet)
+
+	// the inner set has no hooks
+	return func(value int) {
+		appendValue(value)
+		c.hooks.inserted(value)
+	}
+}
+
+// applyBatch holds the lock once for the whole batch.
+func (c *coarseGrainedSyncSet) applyBatch(op Operation, keys []batchKey, results []bool) {
+	if op == OperationContains {
+		c.stats.rlockSet(&c.mutex)
+		defer c.mutex.RUnlock()
+	} else {
+		c.stats.lockSet(&c.mutex)
+		defer c.mutex.Unlock()
+	}
+
+	if batch, ok := c.sequentialSet.(batchSet); ok {
+		batch.applyBatch(op, keys, results)
+	} else {
+		applyOneByOne(c.sequentialSet, op, keys, results)
+	}
+
+	// the inner set has no hooks, so the results are reported here, still under the lock
+	for _, key := range keys {
+		c.stats.operation()
+		c.hooks.locked(op, key.value)
+
+		switch {
+		case !results[key.index]:
+			c.hooks.failed(op, key.value)
+		case op == OperationInsert:
+			c.hooks.inserted(key.value)
+		case op == OperationRemove:
+			c.hooks.removed(key.value)
+		}
+	}
+}
+
+// Snapshot copies the list under the read lock.
+func (c *coarseGrainedSyncSet) Snapshot() Set {
+	c.mutex.RLock()
+	defer c.mutex.RUnlock()
+
+	iterable, ok := c.sequentialSet.(Iterable)
+	if !ok {
+		return &snapshotSet{values: []int{}}
+	}
+
+	return collectValues(iterable)
+}
+
+func (c *coarseGrainedSyncSet) checkInvariants() error {
+	c.mutex.RLock()
+	defer c.mutex.RUnlock()
+
+	checker, ok := c.sequentialSet.(invariantChecker)
+	if !ok {
+		return nil
+	}
+
+	return checker.checkInvariants()
 }
 
 // NewCoarseGrainedSyncSet provides thread-safe implementation of set, utilizing pessimistic locks.
-func NewCoarseGrainedSyncSet() Set {
-	return &coarseGrainedSyncSet{
+func NewCoarseGrainedSyncSet(opts ...Option) Set {
+	o := newOptions(opts)
+
+	// the hooks are called by the wrapper under the lock, the inner set has none
+	s := &coarseGrainedSyncSet{
 		sequentialSet: NewSequentialSet(),
+		hooks:         o.hooks,
 	}
+
+	return o.trace(s, coarseGrainedName)
 }
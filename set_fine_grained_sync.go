@@ -2,37 +2,60 @@ package set
 
 import (
 	"math"
-	"sync"
+	"sync/atomic"
+	"unsafe"
 )
 
 type syncNode struct {
-	next *syncNode
-	sync.Mutex
+	_ syncNodePad
+	// optimistic traversals read next without locks, so it is always accessed atomically
+	next unsafe.Pointer // *syncNode
+	nodeMutex
 	value int
 }
 
+func (n *syncNode) getNext() *syncNode {
+	return (*syncNode)(atomic.LoadPointer(&n.next))
+}
+
+func (n *syncNode) setNext(next *syncNode) {
+	atomic.StorePointer(&n.next, unsafe.Pointer(next))
+}
+
 var _ Set = (*fineGrainedSyncSet)(nil)
 
 type fineGrainedSyncSet struct {
-	head *syncNode
+	head  *syncNode
+	stats statsCounters
+	hooks Hooks
 }
 
 func (s *fineGrainedSyncSet) Insert(value int) bool {
+	if reserved(value) {
+		s.hooks.failed(OperationInsert, value)
+
+		return false
+	}
+
+	s.stats.operation()
+
 	// it looks impossible to use defers here
-	s.head.Lock()
+	s.stats.lock(&s.head.nodeMutex)
 
 	pred := s.head
-	curr := pred.next
+	curr := pred.getNext()
 
-	curr.Lock()
+	s.stats.lock(&curr.nodeMutex)
 
 	for curr.value < value {
+		yieldPoint()
+
 		pred.Unlock()
 
 		pred = curr
-		curr = curr.next
+		curr = curr.getNext()
 
-		curr.Lock()
+		s.stats.lock(&curr.nodeMutex)
 	}
 
 	defer func() {
@@ -40,31 +63,44 @@ func (s *fineGrainedSyncSet) Insert(value int) bool {
 		pred.Unlock()
 	}()
 
+	s.hooks.locked(OperationInsert, value)
+	yieldPoint()
+
 	if curr.value == value {
+		s.hooks.failed(OperationInsert, value)
+
 		return false
 	}
 
-	newNode := &syncNode{value: value, next: curr}
-	pred.next = newNode
+	newNode := &syncNode{value: value, next: unsafe.Pointer(curr)}
+	pred.setNext(newNode)
+	s.hooks.inserted(value)
 
 	return true
 }
 
-func (s fineGrainedSyncSet) Contains(value int) bool {
-	s.head.Lock()
+func (s *fineGrainedSyncSet) Contains(value int) bool {
+	if reserved(value) {
+		return s.hooks.result(OperationContains, value, false)
+	}
+
+	s.stats.operation()
+	s.stats.lock(&s.head.nodeMutex)
 
 	pred := s.head
-	curr := pred.next
+	curr := pred.getNext()
 
-	curr.Lock()
+	s.stats.lock(&curr.nodeMutex)
 
 	for curr.value < value {
+		yieldPoint()
+
 		pred.Unlock()
 
 		pred = curr
-		curr = curr.next
+		curr = curr.getNext()
 
-		curr.Lock()
+		s.stats.lock(&curr.nodeMutex)
 	}
 
 	defer func() {
@@ -72,22 +108,33 @@ func (s fineGrainedSyncSet) Contains(value int) bool {
 		pred.Unlock()
 	}()
 
-	return curr.value == value
+	s.hooks.locked(OperationContains, value)
+
+	return s.hooks.result(OperationContains, value, curr.value == value)
 }
 
 func (s *fineGrainedSyncSet) Remove(value int) bool {
-	s.head.Lock()
+	if reserved(value) {
+		s.hooks.failed(OperationRemove, value)
+
+		return false
+	}
+
+	s.stats.operation()
+	s.stats.lock(&s.head.nodeMutex)
 
 	pred := s.head
-	curr := pred.next
+	curr := pred.getNext()
 
-	curr.Lock()
+	s.stats.lock(&curr.nodeMutex)
 
 	for curr.value < value {
+		yieldPoint()
+
 		pred.Unlock()
 		pred = curr
-		curr = pred.next
-		curr.Lock()
+		curr = pred.getNext()
+		s.stats.lock(&curr.nodeMutex)
 	}
 
 	defer func() {
@@ -95,21 +142,166 @@ func (s *fineGrainedSyncSet) Remove(value int) bool {
 		pred.Unlock()
 	}()
 
+	s.hooks.locked(OperationRemove, value)
+	yieldPoint()
+
 	if curr.value == value {
-		pred.next = curr.next
+		pred.setNext(curr.getNext())
+		s.hooks.removed(value)
 
 		return true
 	}
 
+	s.hooks.failed(OperationRemove, value)
+
 	return false
 }
 
+func (s *fineGrainedSyncSet) appender() func(value int) {
+	last := s.head
+
+	return func(value int) {
+		newNode := &syncNode{value: value, next: unsafe.Pointer(last.getNext())}
+		last.setNext(newNode)
+		last = newNode
+		s.hooks.inserted(value)
+	}
+}
+
+// Ascend doesn't lock the nodes: a removed node keeps its next reference, so the walk always reaches the tail.
+func (s *fineGrainedSyncSet) Ascend(yield func(value int) bool) {
+	for curr := s.head.getNext(); curr.value != math.MaxInt64; curr = curr.getNext() {
+		if !yield(curr.value) {
+			return
+		}
+	}
+}
+
+// applyBatch sweeps the list hand-over-hand once: the window of a key is the starting point for the next one.
+//
+//nolint:funlen // the sweep can't be split without passing the locked window around
+func (s *fineGrainedSyncSet) applyBatch(op Operation, keys []batchKey, results []bool) {
+	s.stats.lock(&s.head.nodeMutex)
+
+	pred := s.head
+	curr := pred.getNext()
+
+	s.stats.lock(&curr.nodeMutex)
+
+	defer func() {
+		curr.Unlock()
+		pred.Unlock()
+	}()
+
+	for _, key := range keys {
+		value := key.value
+
+		s.stats.operation()
+
+		for curr.value < value {
+			yieldPoint()
+
+			pred.Unlock()
+
+			pred = curr
+			curr = curr.getNext()
+
+			s.stats.lock(&curr.nodeMutex)
+		}
+
+		s.hooks.locked(op, value)
+		yieldPoint()
+
+		switch {
+		case op == OperationContains:
+			results[key.index] = s.hooks.result(op, value, curr.value == value)
+		case op == OperationInsert && curr.value != value:
+			newNode := &syncNode{value: value, next: unsafe.Pointer(curr)}
+			pred.setNext(newNode)
+			results[key.index] = true
+			s.hooks.inserted(value)
+
+			// the new node becomes curr, so that a duplicate of the value fails;
+			// it's reachable only through locked pred, so it's locked without contention
+			newNode.Lock()
+			curr.Unlock()
+			curr = newNode
+		case op == OperationRemove && curr.value == value:
+			succ := curr.getNext()
+			pred.setNext(succ)
+			results[key.index] = true
+			s.hooks.removed(value)
+
+			s.stats.lock(&succ.nodeMutex)
+			curr.Unlock()
+			curr = succ
+		default:
+			s.hooks.failed(op, value)
+		}
+	}
+}
+
+// Snapshot locks all nodes in list order, like a traversal that never releases the locks:
+// the writers need the locks of their windows, so the list can't change once the tail is locked.
+func (s *fineGrainedSyncSet) Snapshot() Set {
+	snapshot := &snapshotSet{values: []int{}}
+	locked := []*syncNode{s.head}
+
+	s.stats.lock(&s.head.nodeMutex)
+
+	for curr := s.head.getNext(); ; curr = curr.getNext() {
+		yieldPoint()
+
+		s.stats.lock(&curr.nodeMutex)
+		locked = append(locked, curr)
+
+		if curr.value == math.MaxInt64 {
+			break
+		}
+
+		snapshot.values = append(snapshot.values, curr.value)
+	}
+
+	for _, n := range locked {
+		n.Unlock()
+	}
+
+	return snapshot
+}
+
+func (s *fineGrainedSyncSet) checkInvariants() error {
+	if s.head.value != -math.MaxInt64 {
+		return &invariantError{err: errWrongHead, value: s.head.value}
+	}
+
+	pred := s.head
+
+	for i := 1; ; i++ {
+		curr := pred.getNext()
+		if curr == nil {
+			return &invariantError{err: errTailUnreachable, position: i - 1, value: pred.value}
+		}
+
+		if curr.value <= pred.value {
+			return &invariantError{err: errNotAscending, position: i, value: curr.value}
+		}
+
+		if curr.value == math.MaxInt64 {
+			return nil
+		}
+
+		pred = curr
+	}
+}
+
 // NewFineGrainedSyncSet provides more optimal thread-safe set implementation with a mutex in every list node.
-func NewFineGrainedSyncSet() Set {
+func NewFineGrainedSyncSet(opts ...Option) Set {
+	o := newOptions(opts)
+
 	// set must contain sentinel nodes with minimal and maximal values
-	s := &fineGrainedSyncSet{}
+	s := &fineGrainedSyncSet{hooks: o.hooks}
 	s.head = &syncNode{value: -math.MaxInt64}
-	s.head.next = &syncNode{value: math.MaxInt64}
+	s.head.setNext(&syncNode{value: math.MaxInt64})
 
-	return s
+	return o.trace(s, fineGrainedName)
 }
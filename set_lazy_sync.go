@@ -2,54 +2,105 @@ package set
 
 import (
 	"math"
-	"sync"
+	"sync/atomic"
+	"unsafe"
+
+	"github.com/vitalyisaev2/linked_list_set/internal/epoch"
 )
 
 type lazySyncNode struct {
-	next  *lazySyncNode
+	_ lazySyncNodePad
+	// traversals read next without locks, so it is always accessed atomically
+	next  unsafe.Pointer // *lazySyncNode
 	value int
-	sync.Mutex
+	nodeMutex
+	// marked is read and written only under the lock of the node
 	marked bool
+	// generation counts the reuses of the node, so that snapshots tell its incarnations apart
+	generation uint32
+}
+
+func (n *lazySyncNode) getNext() *lazySyncNode {
+	return (*lazySyncNode)(atomic.LoadPointer(&n.next))
+}
+
+func (n *lazySyncNode) setNext(next *lazySyncNode) {
+	atomic.StorePointer(&n.next, unsafe.Pointer(next))
 }
 
 type lazySyncSet struct {
-	head *lazySyncNode
+	head              *lazySyncNode
+	stats             statsCounters
+	snapshots         snapshots
+	contentionManager ContentionManager
+	hooks             Hooks
+	domain            *epoch.Domain
 }
 
 func (s *lazySyncSet) Insert(value int) bool {
-	for {
-		result, repeat := s.insertLoopBody(value)
+	if reserved(value) {
+		s.hooks.failed(OperationInsert, value)
+
+		return false
+	}
+
+	g := s.domain.Pin()
+	defer g.Unpin()
+
+	for attempt := 1; ; attempt++ {
+		result, repeat := s.insertLoopBody(g, value)
 		if !repeat {
+			s.stats.operation()
+
 			return result
 		}
+
+		s.stats.validationFailure()
+		s.hooks.retried(OperationInsert, value, attempt)
+		s.contentionManager.Backoff(attempt)
 	}
 }
 
 //nolint:dupl // it's better to copy-paste code than messing with inheritance
-func (s *lazySyncSet) insertLoopBody(value int) (result, repeat bool) {
+func (s *lazySyncSet) insertLoopBody(g *epoch.Guard, value int) (result, repeat bool) {
 	pred := s.head
-	curr := pred.next
+	curr := pred.getNext()
+	traversed := 1
 
 	for curr.value < value {
+		yieldPoint()
+
 		pred = curr
-		curr = curr.next
+		curr = curr.getNext()
+		traversed++
 	}
 
-	pred.Lock()
-	curr.Lock()
+	s.stats.traverse(traversed)
+
+	s.stats.lock(&pred.nodeMutex)
+	s.stats.lock(&curr.nodeMutex)
 
 	defer func() {
 		curr.Unlock()
 		pred.Unlock()
 	}()
 
+	yieldPoint()
+
 	if s.validate(pred, curr) {
+		s.hooks.locked(OperationInsert, value)
+
 		if curr.value == value {
+			s.snapshots.reportInsert(unsafe.Pointer(curr), curr.generation, value)
+			s.hooks.failed(OperationInsert, value)
+
 			return false, false
 		}
 
-		newNode := &lazySyncNode{value: value, next: curr}
-		pred.next = newNode
+		newNode := s.newNode(g, value, curr)
+		pred.setNext(newNode)
+		s.snapshots.reportInsert(unsafe.Pointer(newNode), newNode.generation, value)
+		s.hooks.inserted(value)
 
 		return true, false
 	}
@@ -58,88 +109,241 @@ func (s *lazySyncSet) insertLoopBody(value int) (result, repeat bool) {
 }
 
 func (s *lazySyncSet) Contains(value int) bool {
-	for {
+	if reserved(value) {
+		return s.hooks.result(OperationContains, value, false)
+	}
+
+	g := s.domain.Pin()
+	defer g.Unpin()
+
+	for attempt := 1; ; attempt++ {
 		result, repeat := s.containsLoopBody(value)
 		if !repeat {
+			s.stats.operation()
+
 			return result
 		}
+
+		s.stats.validationFailure()
+		s.hooks.retried(OperationContains, value, attempt)
+		s.contentionManager.Backoff(attempt)
 	}
 }
 
 func (s *lazySyncSet) containsLoopBody(value int) (result, repeat bool) {
 	pred := s.head
-	curr := pred.next
+	curr := pred.getNext()
+	traversed := 1
 
 	for curr.value < value {
+		yieldPoint()
+
 		pred = curr
-		curr = curr.next
+		curr = curr.getNext()
+		traversed++
 	}
 
-	pred.Lock()
-	curr.Lock()
+	s.stats.traverse(traversed)
+
+	s.stats.lock(&pred.nodeMutex)
+	s.stats.lock(&curr.nodeMutex)
 
 	defer func() {
 		curr.Unlock()
 		pred.Unlock()
 	}()
 
+	yieldPoint()
+
 	if s.validate(pred, curr) {
-		return curr.value == value, false
+		s.hooks.locked(OperationContains, value)
+
+		if curr.value == value {
+			s.snapshots.reportInsert(unsafe.Pointer(curr), curr.generation, value)
+		}
+
+		return s.hooks.result(OperationContains, value, curr.value == value), false
 	}
 
 	return false, true
 }
 
 func (s *lazySyncSet) Remove(value int) bool {
-	for {
-		result, repeat := s.removeLoopBody(value)
+	if reserved(value) {
+		s.hooks.failed(OperationRemove, value)
+
+		return false
+	}
+
+	g := s.domain.Pin()
+	defer g.Unpin()
+
+	for attempt := 1; ; attempt++ {
+		result, repeat := s.removeLoopBody(g, value)
 		if !repeat {
+			s.stats.operation()
+
 			return result
 		}
+
+		s.stats.validationFailure()
+		s.hooks.retried(OperationRemove, value, attempt)
+		s.contentionManager.Backoff(attempt)
 	}
 }
 
-func (s *lazySyncSet) removeLoopBody(value int) (result, repeat bool) {
+func (s *lazySyncSet) removeLoopBody(g *epoch.Guard, value int) (result, repeat bool) {
 	pred := s.head
-	curr := s.head.next
+	curr := s.head.getNext()
+	traversed := 1
 
 	for curr.value < value {
+		yieldPoint()
+
 		pred = curr
-		curr = curr.next
+		curr = curr.getNext()
+		traversed++
 	}
 
-	pred.Lock()
-	curr.Lock()
+	s.stats.traverse(traversed)
+
+	s.stats.lock(&pred.nodeMutex)
+	s.stats.lock(&curr.nodeMutex)
 
 	defer func() {
 		curr.Unlock()
 		pred.Unlock()
 	}()
 
+	yieldPoint()
+
 	if s.validate(pred, curr) {
+		s.hooks.locked(OperationRemove, value)
+
 		if curr.value == value {
 			curr.marked = true
-			pred.next = curr.next
+			s.snapshots.reportDelete(unsafe.Pointer(curr), curr.generation, value)
+			pred.setNext(curr.getNext())
+			g.Retire(0, curr)
+			s.hooks.removed(value)
 
 			return true, false
 		}
 
+		s.hooks.failed(OperationRemove, value)
+
 		return false, false
 	}
 
 	return false, true
 }
 
+// newNode takes a node from the free list of the guard or allocates a new one.
+func (s *lazySyncSet) newNode(g *epoch.Guard, value int, next *lazySyncNode) *lazySyncNode {
+	if n, ok := g.Reuse(0).(*lazySyncNode); ok {
+		n.value = value
+		n.setNext(next)
+		n.marked = false
+		n.generation++
+
+		return n
+	}
+
+	return &lazySyncNode{value: value, next: unsafe.Pointer(next)}
+}
+
 func (s *lazySyncSet) validate(pred, curr *lazySyncNode) bool {
-	return !pred.marked && !curr.marked && pred.next == curr
+	return !pred.marked && !curr.marked && pred.getNext() == curr
+}
+
+func (s *lazySyncSet) appender() func(value int) {
+	last := s.head
+
+	return func(value int) {
+		newNode := &lazySyncNode{value: value, next: unsafe.Pointer(last.getNext())}
+		last.setNext(newNode)
+		last = newNode
+		s.hooks.inserted(value)
+	}
+}
+
+// Ascend skips the marked nodes; the mark is read under the lock of the node.
+func (s *lazySyncSet) Ascend(yield func(value int) bool) {
+	g := s.domain.Pin()
+	defer g.Unpin()
+
+	for curr := s.head.getNext(); curr.value != math.MaxInt64; curr = curr.getNext() {
+		curr.Lock()
+		marked := curr.marked
+		curr.Unlock()
+
+		if !marked && !yield(curr.value) {
+			return
+		}
+	}
+}
+
+// Snapshot collects the unmarked nodes while the concurrent operations report their changes.
+func (s *lazySyncSet) Snapshot() Set {
+	g := s.domain.Pin()
+	defer g.Unpin()
+
+	return s.snapshots.collect(func(add func(node unsafe.Pointer, generation uint32, value int)) {
+		for curr := s.head.getNext(); curr.value != math.MaxInt64; curr = curr.getNext() {
+			yieldPoint()
+
+			curr.Lock()
+			marked := curr.marked
+			curr.Unlock()
+
+			if !marked {
+				add(unsafe.Pointer(curr), curr.generation, curr.value)
+			}
+		}
+	})
+}
+
+func (s *lazySyncSet) checkInvariants() error {
+	if s.head.value != -math.MaxInt64 {
+		return &invariantError{err: errWrongHead, value: s.head.value}
+	}
+
+	pred := s.head
+
+	for i := 1; ; i++ {
+		curr := pred.getNext()
+		if curr == nil {
+			return &invariantError{err: errTailUnreachable, position: i - 1, value: pred.value}
+		}
+
+		if curr.value <= pred.value {
+			return &invariantError{err: errNotAscending, position: i, value: curr.value}
+		}
+
+		if curr.marked {
+			return &invariantError{err: errMarkedReachable, position: i, value: curr.value}
+		}
+
+		if curr.value == math.MaxInt64 {
+			return nil
+		}
+
+		pred = curr
+	}
 }
 
 // NewLazySyncSet provides lazy thread-safe set implementation with a mutex in every list node.
-func NewLazySyncSet() Set {
+func NewLazySyncSet(opts ...Option) Set {
+	o := newOptions(opts)
+
 	// set must contain sentinel nodes with minimal and maximal values
-	s := &lazySyncSet{}
+	s := &lazySyncSet{
+		contentionManager: o.contentionManager,
+		hooks:             o.hooks,
+		domain:            o.newEpochDomain(1),
+	}
 	s.head = &lazySyncNode{value: -math.MaxInt64}
-	s.head.next = &lazySyncNode{value: math.MaxInt64}
+	s.head.setNext(&lazySyncNode{value: math.MaxInt64})
 
-	return s
+	return o.trace(s, lazyName)
 }
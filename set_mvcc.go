@@ -0,0 +1,524 @@
+package set
+
+import (
+	"errors"
+	"math"
+	"sync"
+	"sync/atomic"
+	"unsafe"
+)
+
+var (
+	// ErrFutureVersion is returned for the versions that have not been reached yet.
+	ErrFutureVersion = errors.New("version has not been reached yet")
+	// ErrVersionCollected is returned for the versions below the watermark of garbage collection.
+	ErrVersionCollected = errors.New("version has been garbage collected")
+)
+
+// VersionedSet is a multi-version set: every successful Insert and Remove gets the next version,
+// and the set can be read as it was at any version that has not been garbage collected yet.
+type VersionedSet interface {
+	Set
+	Iterable
+	Snapshotter
+	// Version returns the version of the latest successful Insert or Remove; the empty set has version 0.
+	Version() uint64
+	// ContainsAt reports whether the value was present at the version.
+	ContainsAt(value int, version uint64) (bool, error)
+	// RangeAt returns the sorted values present at the version.
+	RangeAt(version uint64) ([]int, error)
+	// Pin returns the latest version and protects it from garbage collection until Unpin is called.
+	Pin() uint64
+	// Unpin releases the version returned by Pin.
+	Unpin(version uint64)
+	// Collect raises the watermark to the oldest pinned version (or to the latest one if none is pinned)
+	// and unlinks the versions removed at or before it, which can't be observed any more.
+	// It returns the number of unlinked versions.
+	Collect() int
+}
+
+const (
+	// versionPending is the version of an update that has changed the list but has not taken its version yet.
+	versionPending uint64 = math.MaxUint64
+	// versionInfinity is the end of the version that has not been removed.
+	versionInfinity uint64 = math.MaxUint64 - 1
+)
+
+// mvccNode is a version of the value present from begin (inclusive) to end (exclusive).
+type mvccNode struct {
+	_ mvccNodePad
+	// the versions go first to be 64-bit aligned on 32-bit platforms; they are always accessed atomically
+	begin uint64
+	end   uint64
+	// traversals read next without locks, so it is always accessed atomically
+	next  unsafe.Pointer // *mvccNode
+	value int
+	nodeMutex
+	// marked is read and written only under the lock of the node
+	marked bool
+}
+
+func (n *mvccNode) getNext() *mvccNode {
+	return (*mvccNode)(atomic.LoadPointer(&n.next))
+}
+
+func (n *mvccNode) setNext(next *mvccNode) {
+	atomic.StorePointer(&n.next, unsafe.Pointer(next))
+}
+
+// waitVersion loads the version, waiting for the update that has changed the list but has not taken
+// its version yet. The update takes the version right after that, still holding the locks, so the wait is short.
+func waitVersion(version *uint64) uint64 {
+	for {
+		if v := atomic.LoadUint64(version); v != versionPending {
+			return v
+		}
+
+		spinWait()
+	}
+}
+
+var (
+	_ VersionedSet     = (*mvccSet)(nil)
+	_ StatsProvider    = (*mvccSet)(nil)
+	_ invariantChecker = (*mvccSet)(nil)
+)
+
+// mvccSet is the list of lazy set keeping the versions of every value: they follow each other from
+// the newest to the oldest, and only the newest one may be present. The updates lock the window like
+// lazy set does, change the list and only then take the next version of the clock, so a reader that has
+// seen the clock at the version finds all the updates up to it in the list, waiting for their versions if needed.
+// Removed versions stay in the list until Collect unlinks them.
+type mvccSet struct {
+	// clock goes first to be 64-bit aligned on 32-bit platforms
+	clock uint64
+	// collected is the watermark of the latest collection: the versions below it can't be read any more
+	collected         uint64
+	head              *mvccNode
+	stats             statsCounters
+	contentionManager ContentionManager
+	hooks             Hooks
+	// pins counts the readers of the pinned versions; the mutex also orders Pin with the collections
+	pins  map[uint64]int
+	mutex sync.Mutex
+}
+
+func (s *mvccSet) Version() uint64 {
+	return atomic.LoadUint64(&s.clock)
+}
+
+func (s *mvccSet) Insert(value int) bool {
+	if reserved(value) {
+		s.hooks.failed(OperationInsert, value)
+
+		return false
+	}
+
+	for attempt := 1; ; attempt++ {
+		result, repeat := s.insertLoopBody(value)
+		if !repeat {
+			s.stats.operation()
+
+			return result
+		}
+
+		s.stats.validationFailure()
+		s.hooks.retried(OperationInsert, value, attempt)
+		s.contentionManager.Backoff(attempt)
+	}
+}
+
+func (s *mvccSet) insertLoopBody(value int) (result, repeat bool) {
+	pred, curr := s.find(value)
+
+	s.stats.lock(&pred.nodeMutex)
+	s.stats.lock(&curr.nodeMutex)
+
+	defer func() {
+		curr.Unlock()
+		pred.Unlock()
+	}()
+
+	yieldPoint()
+
+	if !s.validate(pred, curr) {
+		return false, true
+	}
+
+	s.hooks.locked(OperationInsert, value)
+
+	// curr is the newest version of the value, if any
+	if curr.value == value && atomic.LoadUint64(&curr.end) == versionInfinity {
+		s.hooks.failed(OperationInsert, value)
+
+		return false, false
+	}
+
+	newNode := &mvccNode{value: value, begin: versionPending, end: versionInfinity, next: unsafe.Pointer(curr)}
+	pred.setNext(newNode)
+
+	yieldPoint()
+
+	atomic.StoreUint64(&newNode.begin, atomic.AddUint64(&s.clock, 1))
+	s.hooks.inserted(value)
+
+	return true, false
+}
+
+// Contains reads the latest version. It takes no locks and repeats the read only if
+// a concurrent collection has passed the version.
+func (s *mvccSet) Contains(value int) bool {
+	for attempt := 1; ; attempt++ {
+		version := s.Version()
+		result := s.containsAt(value, version)
+
+		if version >= atomic.LoadUint64(&s.collected) {
+			s.stats.operation()
+
+			return s.hooks.result(OperationContains, value, result)
+		}
+
+		s.stats.validationFailure()
+		s.hooks.retried(OperationContains, value, attempt)
+		s.contentionManager.Backoff(attempt)
+	}
+}
+
+func (s *mvccSet) Remove(value int) bool {
+	if reserved(value) {
+		s.hooks.failed(OperationRemove, value)
+
+		return false
+	}
+
+	for attempt := 1; ; attempt++ {
+		result, repeat := s.removeLoopBody(value)
+		if !repeat {
+			s.stats.operation()
+
+			return result
+		}
+
+		s.stats.validationFailure()
+		s.hooks.retried(OperationRemove, value, attempt)
+		s.contentionManager.Backoff(attempt)
+	}
+}
+
+func (s *mvccSet) removeLoopBody(value int) (result, repeat bool) {
+	pred, curr := s.find(value)
+
+	s.stats.lock(&pred.nodeMutex)
+	s.stats.lock(&curr.nodeMutex)
+
+	defer func() {
+		curr.Unlock()
+		pred.Unlock()
+	}()
+
+	yieldPoint()
+
+	if !s.validate(pred, curr) {
+		return false, true
+	}
+
+	s.hooks.locked(OperationRemove, value)
+
+	if curr.value != value || atomic.LoadUint64(&curr.end) != versionInfinity {
+		s.hooks.failed(OperationRemove, value)
+
+		return false, false
+	}
+
+	// the version stays in the list: the readers of the older versions may still need it
+	atomic.StoreUint64(&curr.end, versionPending)
+
+	yieldPoint()
+
+	atomic.StoreUint64(&curr.end, atomic.AddUint64(&s.clock, 1))
+	s.hooks.removed(value)
+
+	return true, false
+}
+
+// find returns the window whose curr is the newest version of the value or the first node with a greater value.
+func (s *mvccSet) find(value int) (pred, curr *mvccNode) {
+	pred = s.head
+	curr = pred.getNext()
+	traversed := 1
+
+	for curr.value < value {
+		yieldPoint()
+
+		pred = curr
+		curr = curr.getNext()
+		traversed++
+	}
+
+	s.stats.traverse(traversed)
+
+	return pred, curr
+}
+
+func (s *mvccSet) validate(pred, curr *mvccNode) bool {
+	return !pred.marked && !curr.marked && pred.getNext() == curr
+}
+
+func (s *mvccSet) ContainsAt(value int, version uint64) (bool, error) {
+	if err := s.checkVersion(version); err != nil {
+		return false, err
+	}
+
+	result := s.containsAt(value, version)
+
+	// a concurrent collection may have unlinked the versions read meanwhile
+	if err := s.checkVersion(version); err != nil {
+		return false, err
+	}
+
+	return result, nil
+}
+
+func (s *mvccSet) containsAt(value int, version uint64) bool {
+	if reserved(value) {
+		return false
+	}
+
+	_, curr := s.find(value)
+
+	// the newest version of the value begun at the version decides
+	for ; curr.value == value; curr = curr.getNext() {
+		yieldPoint()
+
+		if waitVersion(&curr.begin) <= version {
+			return version < waitVersion(&curr.end)
+		}
+	}
+
+	return false
+}
+
+func (s *mvccSet) RangeAt(version uint64) ([]int, error) {
+	if err := s.checkVersion(version); err != nil {
+		return nil, err
+	}
+
+	values := s.rangeAt(version)
+
+	if err := s.checkVersion(version); err != nil {
+		return nil, err
+	}
+
+	return values, nil
+}
+
+func (s *mvccSet) rangeAt(version uint64) []int {
+	values := []int{}
+	// the value whose version at the given one has been found already
+	decided := s.head.value
+
+	for curr := s.head.getNext(); curr.value != math.MaxInt64; curr = curr.getNext() {
+		yieldPoint()
+
+		if curr.value == decided || waitVersion(&curr.begin) > version {
+			continue
+		}
+
+		decided = curr.value
+
+		if version < waitVersion(&curr.end) {
+			values = append(values, curr.value)
+		}
+	}
+
+	return values
+}
+
+func (s *mvccSet) checkVersion(version uint64) error {
+	if version > s.Version() {
+		return ErrFutureVersion
+	}
+
+	if version < atomic.LoadUint64(&s.collected) {
+		return ErrVersionCollected
+	}
+
+	return nil
+}
+
+func (s *mvccSet) Pin() uint64 {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+
+	version := s.Version()
+	s.pins[version]++
+
+	return version
+}
+
+func (s *mvccSet) Unpin(version uint64) {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+
+	switch s.pins[version] {
+	case 0:
+		panic("version is not pinned")
+	case 1:
+		delete(s.pins, version)
+	default:
+		s.pins[version]--
+	}
+}
+
+func (s *mvccSet) Collect() int {
+	s.mutex.Lock()
+
+	watermark := s.Version()
+
+	for version := range s.pins {
+		if version < watermark {
+			watermark = version
+		}
+	}
+
+	// the watermark never goes back, even if an older version has been pinned since the previous collection:
+	// it has been pinned when the watermark had been raised already, so it can't be read anyway
+	if watermark > atomic.LoadUint64(&s.collected) {
+		atomic.StoreUint64(&s.collected, watermark)
+	}
+
+	s.mutex.Unlock()
+
+	unlinked := 0
+	pred := s.head
+	curr := pred.getNext()
+
+	for curr.value != math.MaxInt64 {
+		yieldPoint()
+
+		// the pending versions are greater than the watermark too
+		if end := atomic.LoadUint64(&curr.end); end > watermark {
+			pred, curr = curr, curr.getNext()
+
+			continue
+		}
+
+		if !s.unlink(pred, curr) {
+			s.stats.validationFailure()
+
+			pred = s.head
+			curr = pred.getNext()
+
+			continue
+		}
+
+		unlinked++
+		curr = pred.getNext()
+	}
+
+	return unlinked
+}
+
+// unlink removes the version from the list like lazy set removes a node.
+func (s *mvccSet) unlink(pred, curr *mvccNode) bool {
+	s.stats.lock(&pred.nodeMutex)
+	s.stats.lock(&curr.nodeMutex)
+
+	defer func() {
+		curr.Unlock()
+		pred.Unlock()
+	}()
+
+	if !s.validate(pred, curr) {
+		return false
+	}
+
+	curr.marked = true
+	pred.setNext(curr.getNext())
+	s.stats.snip()
+
+	return true
+}
+
+// appender makes every value a version of its own, like Insert does.
+func (s *mvccSet) appender() func(value int) {
+	last := s.head
+
+	return func(value int) {
+		newNode := &mvccNode{value: value, end: versionInfinity, next: unsafe.Pointer(last.getNext())}
+		newNode.begin = atomic.AddUint64(&s.clock, 1)
+		last.setNext(newNode)
+		last = newNode
+		s.hooks.inserted(value)
+	}
+}
+
+// Ascend reads the latest version pinned for the whole walk, so the values form a snapshot of the set.
+func (s *mvccSet) Ascend(yield func(value int) bool) {
+	version := s.Pin()
+	defer s.Unpin(version)
+
+	for _, value := range s.rangeAt(version) {
+		if !yield(value) {
+			return
+		}
+	}
+}
+
+// Snapshot reads the latest version.
+func (s *mvccSet) Snapshot() Set {
+	return collectValues(s)
+}
+
+func (s *mvccSet) Stats() Stats { return s.stats.snapshot() }
+
+func (s *mvccSet) checkInvariants() error {
+	if s.head.value != -math.MaxInt64 {
+		return &invariantError{err: errWrongHead, value: s.head.value}
+	}
+
+	pred := s.head
+
+	for i := 1; ; i++ {
+		curr := pred.getNext()
+		if curr == nil {
+			return &invariantError{err: errTailUnreachable, position: i - 1, value: pred.value}
+		}
+
+		if curr.value < pred.value {
+			return &invariantError{err: errNotAscending, position: i, value: curr.value}
+		}
+
+		if curr.marked {
+			return &invariantError{err: errMarkedReachable, position: i, value: curr.value}
+		}
+
+		// the older version of the value must end before the newer one begins
+		if curr.value == pred.value && atomic.LoadUint64(&curr.end) > atomic.LoadUint64(&pred.begin) {
+			return &invariantError{err: errVersionsOverlap, position: i, value: curr.value}
+		}
+
+		if curr.value == math.MaxInt64 {
+			return nil
+		}
+
+		pred = curr
+	}
+}
+
+// NewMVCCSet provides multi-version set built on the list of lazy set. The nodes are never recycled,
+// because the readers of old versions may traverse them.
+func NewMVCCSet(opts ...Option) VersionedSet {
+	o := newOptions(opts)
+
+	// set must contain sentinel nodes with minimal and maximal values; they are present at all versions
+	s := &mvccSet{
+		contentionManager: o.contentionManager,
+		hooks:             o.hooks,
+		pins:              make(map[uint64]int),
+	}
+	s.head = &mvccNode{value: -math.MaxInt64, end: versionInfinity}
+	s.head.setNext(&mvccNode{value: math.MaxInt64, end: versionInfinity})
+
+	return o.traceVersioned(s)
+}
@@ -0,0 +1,198 @@
+package set
+
+import (
+	"math"
+	"math/rand"
+	"sync"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+// versions counts the nodes of the list of MVCC set between the sentinels.
+func versions(s VersionedSet) int {
+	set, ok := s.(*mvccSet)
+	if !ok {
+		return 0
+	}
+
+	n := 0
+
+	for curr := set.head.getNext(); curr.value != math.MaxInt64; curr = curr.getNext() {
+		n++
+	}
+
+	return n
+}
+
+func TestMVCCVersions(t *testing.T) {
+	set := NewMVCCSet()
+	require.Equal(t, uint64(0), set.Version())
+
+	require.True(t, set.Insert(1))  // 1
+	require.True(t, set.Insert(2))  // 2
+	require.False(t, set.Insert(2)) // failed updates don't take versions
+	require.True(t, set.Remove(1))  // 3
+	require.False(t, set.Remove(1))
+	require.True(t, set.Insert(1)) // 4
+	require.True(t, set.Remove(2)) // 5
+	require.Equal(t, uint64(5), set.Version())
+
+	history := [][]int{{}, {1}, {1, 2}, {2}, {1, 2}, {1}}
+
+	for version, expected := range history {
+		values, err := set.RangeAt(uint64(version))
+		require.NoError(t, err)
+		require.Equal(t, expected, values, version)
+
+		for _, value := range []int{1, 2} {
+			ok, err := set.ContainsAt(value, uint64(version))
+			require.NoError(t, err)
+			require.Equal(t, (&snapshotSet{values: expected}).Contains(value), ok, version)
+		}
+	}
+
+	_, err := set.ContainsAt(1, 6)
+	require.ErrorIs(t, err, ErrFutureVersion)
+
+	_, err = set.RangeAt(6)
+	require.ErrorIs(t, err, ErrFutureVersion)
+
+	checker, ok := set.(invariantChecker)
+	require.True(t, ok)
+	require.NoError(t, checker.checkInvariants())
+}
+
+func TestMVCCCollect(t *testing.T) {
+	set := NewMVCCSet()
+
+	require.True(t, set.Insert(1)) // 1
+	require.True(t, set.Insert(2)) // 2
+
+	pinned := set.Pin()
+	require.Equal(t, uint64(2), pinned)
+
+	require.True(t, set.Remove(1)) // 3
+	require.True(t, set.Insert(1)) // 4
+	require.True(t, set.Remove(2)) // 5
+	require.Equal(t, 3, versions(set))
+
+	// the pinned version still needs all the versions removed after it
+	require.Equal(t, 0, set.Collect())
+
+	values, err := set.RangeAt(pinned)
+	require.NoError(t, err)
+	require.Equal(t, []int{1, 2}, values)
+
+	_, err = set.RangeAt(1)
+	require.ErrorIs(t, err, ErrVersionCollected)
+
+	set.Unpin(pinned)
+	require.Panics(t, func() { set.Unpin(pinned) })
+
+	// nobody can observe the removed versions any more
+	require.Equal(t, 2, set.Collect())
+	require.Equal(t, 1, versions(set))
+
+	_, err = set.ContainsAt(1, pinned)
+	require.ErrorIs(t, err, ErrVersionCollected)
+
+	values, err = set.RangeAt(set.Version())
+	require.NoError(t, err)
+	require.Equal(t, []int{1}, values)
+	require.True(t, set.Contains(1))
+	require.False(t, set.Contains(2))
+
+	checker, ok := set.(invariantChecker)
+	require.True(t, ok)
+	require.NoError(t, checker.checkInvariants())
+}
+
+// TestMVCCConcurrent reads pinned versions while the writers update the set and the collector
+// unlinks old versions: the contents of a pinned version must never change.
+func TestMVCCConcurrent(t *testing.T) {
+	const (
+		keys    = 16
+		threads = 4
+		updates = 2000
+		reads   = 200
+	)
+
+	set := NewMVCCSet()
+
+	var writers, others sync.WaitGroup
+
+	writers.Add(threads)
+
+	for i := 0; i < threads; i++ {
+		seed := int64(i)
+
+		go func() {
+			defer writers.Done()
+
+			rnd := rand.New(rand.NewSource(seed)) //nolint:gosec // reproducible workload
+
+			for j := 0; j < updates; j++ {
+				if value := rnd.Intn(keys); rnd.Intn(2) == 0 {
+					set.Insert(value)
+				} else {
+					set.Remove(value)
+				}
+			}
+		}()
+	}
+
+	done := make(chan struct{})
+
+	others.Add(1)
+
+	go func() {
+		defer others.Done()
+
+		for {
+			select {
+			case <-done:
+				return
+			default:
+				set.Collect()
+			}
+		}
+	}()
+
+	for i := 0; i < reads; i++ {
+		version := set.Pin()
+
+		values, err := set.RangeAt(version)
+		require.NoError(t, err)
+
+		for value := 0; value < keys; value++ {
+			ok, err := set.ContainsAt(value, version)
+			require.NoError(t, err)
+			require.Equal(t, (&snapshotSet{values: values}).Contains(value), ok, value)
+		}
+
+		again, err := set.RangeAt(version)
+		require.NoError(t, err)
+		require.Equal(t, values, again)
+
+		set.Unpin(version)
+	}
+
+	writers.Wait()
+	close(done)
+	others.Wait()
+
+	// only the present versions remain after the last collection
+	set.Collect()
+
+	values := ascend(t, set)
+	require.Equal(t, len(values), versions(set))
+
+	for value := 0; value < keys; value++ {
+		require.Equal(t, (&snapshotSet{values: values}).Contains(value), set.Contains(value), value)
+	}
+
+	checker, ok := set.(invariantChecker)
+	require.True(t, ok)
+	require.NoError(t, checker.checkInvariants())
+}
@@ -4,11 +4,23 @@ import (
 	"math"
 	"sync/atomic"
 	"unsafe"
+
+	"github.com/vitalyisaev2/linked_list_set/internal/epoch"
+)
+
+// classes of objects recycled by nonBlockingSet.
+const (
+	nonBlockingNodeClass = iota
+	markableReferenceClass
+	nonBlockingClasses
 )
 
 type nonBlockingNode struct {
+	_     nonBlockingNodePad
 	next  *atomicMarkableReference
 	value int
+	// generation counts the reuses of the node, so that snapshots tell its incarnations apart
+	generation uint32
 }
 
 type markableReference struct {
@@ -17,6 +29,7 @@ type markableReference struct {
 }
 
 type atomicMarkableReference struct {
+	_   atomicMarkableReferencePad
 	ref unsafe.Pointer // *markableReference
 }
 
@@ -51,6 +64,16 @@ func (amr *atomicMarkableReference) getBoth() (*nonBlockingNode, bool) {
 }
 
 func (amr *atomicMarkableReference) compareAndSet(expectedNode, desiredNode *nonBlockingNode, expectedMark, desiredMark bool) bool {
+	return amr.compareAndSetGuarded(nil, expectedNode, desiredNode, expectedMark, desiredMark)
+}
+
+// compareAndSetGuarded is compareAndSet recycling markable references through the guard:
+// the replaced reference is retired, and the new one is taken from the free list.
+func (amr *atomicMarkableReference) compareAndSetGuarded(
+	g *epoch.Guard,
+	expectedNode, desiredNode *nonBlockingNode,
+	expectedMark, desiredMark bool,
+) bool {
 	if amr == nil {
 		return false
 	}
@@ -58,12 +81,40 @@ func (amr *atomicMarkableReference) compareAndSet(expectedNode, desiredNode *non
 	existingRefValue := atomic.LoadPointer(&amr.ref)
 	existingRef := (*markableReference)(existingRefValue)
 
-	newRef := &markableReference{node: desiredNode, mark: desiredMark}
+	// don't allocate new reference if CAS is doomed to fail
+	if existingRef.node != expectedNode || existingRef.mark != expectedMark {
+		return false
+	}
+
+	newRef := newMarkableReference(g, desiredNode, desiredMark)
 	newRefValue := unsafe.Pointer(newRef)
 
-	return existingRef.node == expectedNode &&
-		existingRef.mark == expectedMark &&
-		atomic.CompareAndSwapPointer(&amr.ref, existingRefValue, newRefValue)
+	if !atomic.CompareAndSwapPointer(&amr.ref, existingRefValue, newRefValue) {
+		return false
+	}
+
+	g.Retire(markableReferenceClass, existingRef)
+
+	return true
+}
+
+// set replaces the reference unconditionally; it's allowed only for the nodes that are not published yet.
+func (amr *atomicMarkableReference) set(g *epoch.Guard, node *nonBlockingNode, mark bool) {
+	ref := newMarkableReference(g, node, mark)
+	existingRef := (*markableReference)(atomic.SwapPointer(&amr.ref, unsafe.Pointer(ref)))
+
+	g.Retire(markableReferenceClass, existingRef)
+}
+
+// newMarkableReference takes a reference from the free list of the guard or allocates a new one.
+func newMarkableReference(g *epoch.Guard, node *nonBlockingNode, mark bool) *markableReference {
+	if ref, ok := g.Reuse(markableReferenceClass).(*markableReference); ok {
+		ref.node, ref.mark = node, mark
+
+		return ref
+	}
+
+	return &markableReference{node: node, mark: mark}
 }
 
 func newAtomicMarkableReference(node *nonBlockingNode, mark bool) *atomicMarkableReference {
@@ -75,98 +126,372 @@ type window struct {
 	pred, curr *nonBlockingNode
 }
 
-func findWindow(head *nonBlockingNode, val int) *window {
+func (s *nonBlockingSet) findWindow(g *epoch.Guard, val int) window {
+	return s.findWindowFrom(g, s.head, val)
+}
+
+// findWindowFrom starts the traversal from the given node if it's still unmarked, i.e. reachable.
+// Restarts always begin from head.
+func (s *nonBlockingSet) findWindowFrom(g *epoch.Guard, start *nonBlockingNode, val int) window {
 	var (
 		pred, curr, succ *nonBlockingNode
 		snip             bool
 		marked           bool
+		attempt          int
+		traversed        int
 	)
 
+	if start.next.getMark() {
+		start = s.head
+	}
+
 LOOP:
 	for {
-		pred = head
+		pred = start
+		start = s.head
 		curr = pred.next.getNode()
 		for {
+			traversed++
+
 			succ, marked = curr.next.getBoth()
 			for marked {
-				snip = pred.next.compareAndSet(curr, succ, false, false)
+				yieldPoint()
+
+				// the node may be unreachable once snipped, so its deletion is reported before
+				s.snapshots.reportDelete(unsafe.Pointer(curr), curr.generation, curr.value)
+
+				snip = pred.next.compareAndSetGuarded(g, curr, succ, false, false)
 				if !snip {
+					s.stats.casFailure()
+					s.stats.restart()
+
+					attempt++
+					s.contentionManager.Backoff(attempt)
+
 					continue LOOP
 				}
 
+				s.stats.snip()
+				g.Retire(nonBlockingNodeClass, curr)
+
 				curr = succ
 				succ, marked = curr.next.getBoth()
+				traversed++
 			}
 
 			if curr.value >= val {
-				return &window{pred: pred, curr: curr}
+				s.stats.traverse(traversed)
+
+				return window{pred: pred, curr: curr}
 			}
 
 			pred = curr
 			curr = succ
+
+			yieldPoint()
 		}
 	}
 }
 
 type nonBlockingSet struct {
-	head *nonBlockingNode
+	head              *nonBlockingNode
+	stats             statsCounters
+	snapshots         snapshots
+	contentionManager ContentionManager
+	hooks             Hooks
+	domain            *epoch.Domain
 }
 
 func (s *nonBlockingSet) Insert(value int) bool {
-	for {
-		w := findWindow(s.head, value)
+	if reserved(value) {
+		s.hooks.failed(OperationInsert, value)
+
+		return false
+	}
+
+	g := s.domain.Pin()
+	defer g.Unpin()
+
+	result, _ := s.insert(g, s.head, value)
+
+	return result
+}
+
+// insert searches the window from start; it returns the predecessor of the value,
+// so that the search for a greater value may resume from it.
+func (s *nonBlockingSet) insert(g *epoch.Guard, start *nonBlockingNode, value int) (bool, *nonBlockingNode) {
+	for attempt := 1; ; attempt++ {
+		w := s.findWindowFrom(g, start, value)
 		pred := w.pred
 		curr := w.curr
 
 		if curr.value == value {
-			return false
+			s.snapshots.reportInsert(unsafe.Pointer(curr), curr.generation, value)
+			s.stats.operation()
+			s.hooks.failed(OperationInsert, value)
+
+			return false, pred
 		}
 
-		newNode := &nonBlockingNode{value: value}
-		newNode.next = newAtomicMarkableReference(curr, false)
+		newNode := s.newNode(g, value, curr)
+
+		yieldPoint()
+
+		if pred.next.compareAndSetGuarded(g, curr, newNode, false, false) {
+			s.snapshots.reportInsert(unsafe.Pointer(newNode), newNode.generation, value)
+			s.stats.operation()
+			s.hooks.inserted(value)
 
-		if pred.next.compareAndSet(curr, newNode, false, false) {
-			return true
+			return true, pred
 		}
+
+		s.stats.casFailure()
+		s.hooks.retried(OperationInsert, value, attempt)
+		s.contentionManager.Backoff(attempt)
 	}
 }
 
 func (s *nonBlockingSet) Contains(value int) bool {
-	curr := s.head
+	if reserved(value) {
+		return s.hooks.result(OperationContains, value, false)
+	}
+
+	g := s.domain.Pin()
+	defer g.Unpin()
+
+	result, _ := s.contains(s.head, value)
+
+	return result
+}
+
+// contains traverses the list from start if it's still unmarked; it returns the last node preceding the value.
+func (s *nonBlockingSet) contains(start *nonBlockingNode, value int) (bool, *nonBlockingNode) {
+	if start.next.getMark() {
+		start = s.head
+	}
+
+	pred := start
+	curr := start
+	traversed := 0
 
 	for curr.value < value {
+		yieldPoint()
+
+		pred = curr
 		curr = curr.next.getNode()
+		traversed++
 	}
 
-	return curr.value == value && !curr.next.getMark()
+	s.stats.traverse(traversed)
+	s.stats.operation()
+
+	if curr.value != value {
+		return s.hooks.result(OperationContains, value, false), pred
+	}
+
+	if curr.next.getMark() {
+		s.snapshots.reportDelete(unsafe.Pointer(curr), curr.generation, value)
+
+		return s.hooks.result(OperationContains, value, false), pred
+	}
+
+	s.snapshots.reportInsert(unsafe.Pointer(curr), curr.generation, value)
+
+	return s.hooks.result(OperationContains, value, true), pred
 }
 
 func (s *nonBlockingSet) Remove(value int) bool {
-	for {
-		w := findWindow(s.head, value)
+	if reserved(value) {
+		s.hooks.failed(OperationRemove, value)
+
+		return false
+	}
+
+	g := s.domain.Pin()
+	defer g.Unpin()
+
+	result, _ := s.remove(g, s.head, value)
+
+	return result
+}
+
+// remove searches the window from start; it returns the predecessor of the value,
+// so that the search for a greater value may resume from it.
+func (s *nonBlockingSet) remove(g *epoch.Guard, start *nonBlockingNode, value int) (bool, *nonBlockingNode) {
+	for attempt := 1; ; attempt++ {
+		w := s.findWindowFrom(g, start, value)
 		pred := w.pred
 		curr := w.curr
 
 		if curr.value != value {
-			return false
+			s.stats.operation()
+			s.hooks.failed(OperationRemove, value)
+
+			return false, pred
 		}
 
 		succ := curr.next.getNode()
-		snip := curr.next.compareAndSet(succ, succ, false, true)
+
+		yieldPoint()
+
+		snip := curr.next.compareAndSetGuarded(g, succ, succ, false, true)
 
 		if !snip {
+			s.stats.casFailure()
+			s.hooks.retried(OperationRemove, value, attempt)
+			s.contentionManager.Backoff(attempt)
+
 			continue
 		}
 
-		pred.next.compareAndSet(curr, succ, false, false)
+		// the node is removed logically
+		s.snapshots.reportDelete(unsafe.Pointer(curr), curr.generation, value)
+		s.hooks.removed(value)
+
+		yieldPoint()
+
+		// if physical removal fails, let findWindow snip (and retire) the node,
+		// so that no marked node stays reachable after the operation completes
+		if pred.next.compareAndSetGuarded(g, curr, succ, false, false) {
+			g.Retire(nonBlockingNodeClass, curr)
+		} else {
+			s.stats.casFailure()
+			s.findWindow(g, value)
+		}
+
+		s.stats.operation()
+
+		return true, pred
+	}
+}
+
+// applyBatch resumes the search for every key from the predecessor of the previous one.
+func (s *nonBlockingSet) applyBatch(op Operation, keys []batchKey, results []bool) {
+	g := s.domain.Pin()
+	defer g.Unpin()
+
+	start := s.head
+
+	for _, key := range keys {
+		switch op {
+		case OperationInsert:
+			results[key.index], start = s.insert(g, start, key.value)
+		case OperationContains:
+			results[key.index], start = s.contains(start, key.value)
+		case OperationRemove:
+			results[key.index], start = s.remove(g, start, key.value)
+		default:
+			panic("unknown Operation")
+		}
+	}
+}
+
+// newNode takes a node from the free list of the guard or allocates a new one.
+func (s *nonBlockingSet) newNode(g *epoch.Guard, value int, next *nonBlockingNode) *nonBlockingNode {
+	if n, ok := g.Reuse(nonBlockingNodeClass).(*nonBlockingNode); ok {
+		n.value = value
+		n.next.set(g, next, false)
+		n.generation++
+
+		return n
+	}
+
+	return &nonBlockingNode{value: value, next: newAtomicMarkableReference(next, false)}
+}
+
+// appender replaces the references unconditionally: the nodes are not published yet.
+func (s *nonBlockingSet) appender() func(value int) {
+	return appendNonBlocking(s.head, s.hooks)
+}
+
+func appendNonBlocking(head *nonBlockingNode, hooks Hooks) func(value int) {
+	last := head
 
-		return true
+	return func(value int) {
+		newNode := &nonBlockingNode{value: value, next: newAtomicMarkableReference(last.next.getNode(), false)}
+		last.next = newAtomicMarkableReference(newNode, false)
+		last = newNode
+		hooks.inserted(value)
+	}
+}
+
+// Ascend skips the marked nodes.
+func (s *nonBlockingSet) Ascend(yield func(value int) bool) {
+	g := s.domain.Pin()
+	defer g.Unpin()
+
+	curr := s.head.next.getNode()
+
+	for curr.value != math.MaxInt64 {
+		succ, marked := curr.next.getBoth()
+
+		if !marked && !yield(curr.value) {
+			return
+		}
+
+		curr = succ
+	}
+}
+
+// Snapshot collects the unmarked nodes while the concurrent operations report their changes.
+func (s *nonBlockingSet) Snapshot() Set {
+	g := s.domain.Pin()
+	defer g.Unpin()
+
+	return s.snapshots.collect(func(add func(node unsafe.Pointer, generation uint32, value int)) {
+		curr := s.head.next.getNode()
+
+		for curr.value != math.MaxInt64 {
+			yieldPoint()
+
+			succ, marked := curr.next.getBoth()
+			if !marked {
+				add(unsafe.Pointer(curr), curr.generation, curr.value)
+			}
+
+			curr = succ
+		}
+	})
+}
+
+func (s *nonBlockingSet) checkInvariants() error {
+	if s.head.value != math.MinInt64 {
+		return &invariantError{err: errWrongHead, value: s.head.value}
+	}
+
+	pred := s.head
+
+	for i := 1; ; i++ {
+		curr := pred.next.getNode()
+		if curr == nil {
+			return &invariantError{err: errTailUnreachable, position: i - 1, value: pred.value}
+		}
+
+		if curr.value <= pred.value {
+			return &invariantError{err: errNotAscending, position: i, value: curr.value}
+		}
+
+		if curr.next.getMark() {
+			return &invariantError{err: errMarkedReachable, position: i, value: curr.value}
+		}
+
+		if curr.value == math.MaxInt64 {
+			return nil
+		}
+
+		pred = curr
 	}
 }
 
 // NewNonBlockingSyncSet builds wait-free implementation of set.
-func NewNonBlockingSyncSet() Set {
-	s := &nonBlockingSet{}
+func NewNonBlockingSyncSet(opts ...Option) Set {
+	o := newOptions(opts)
+
+	s := &nonBlockingSet{
+		contentionManager: o.contentionManager,
+		hooks:             o.hooks,
+		domain:            o.newEpochDomain(nonBlockingClasses),
+	}
 
 	head := &nonBlockingNode{value: math.MinInt64}
 	tail := &nonBlockingNode{value: math.MaxInt64}
@@ -176,5 +501,5 @@ func NewNonBlockingSyncSet() Set {
 
 	s.head = head
 
-	return s
+	return o.trace(s, nonBlockingName)
 }
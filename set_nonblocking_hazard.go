@@ -0,0 +1,325 @@
+package set
+
+import (
+	"math"
+	"unsafe"
+
+	"github.com/vitalyisaev2/linked_list_set/internal/hazard"
+)
+
+// hazard slots: findWindow uses the first three, and the roles of these slots rotate during traversal;
+// Ascend keeps the current node in the last one.
+const (
+	ascendSlot  = 3
+	hazardSlots = 4
+	// the node is retired only once, so the scan threshold may be small
+	hazardScanThreshold = 64
+)
+
+var _ Set = (*hazardNonBlockingSet)(nil)
+
+// hazardNonBlockingSet is nonBlockingSet that protects the nodes with hazard pointers and recycles them.
+// Unlike the original, Contains also relies on findWindow: an unprotected traversal
+// could follow the next reference of a node that has already been reused.
+type hazardNonBlockingSet struct {
+	head              *nonBlockingNode
+	stats             statsCounters
+	contentionManager ContentionManager
+	hooks             Hooks
+	domain            *hazard.Domain
+}
+
+// findWindow returns a window with pred and curr protected by the hazard slots of the record.
+// Every protected node is validated to be still reachable before it is dereferenced.
+//
+//nolint:gocognit // splitting the traversal would obscure the order of protection and validation
+func (s *hazardNonBlockingSet) findWindow(r *hazard.Record, val int) window {
+	var attempt, traversed int
+
+LOOP:
+	for {
+		predSlot, currSlot, succSlot := 0, 1, 2
+
+		// head is never removed, so it needs no protection
+		pred := s.head
+		curr := pred.next.getNode()
+		r.Protect(currSlot, unsafe.Pointer(curr))
+		yieldPoint()
+
+		if node, mark := pred.next.getBoth(); node != curr || mark {
+			s.stats.restart()
+
+			attempt++
+			s.contentionManager.Backoff(attempt)
+
+			continue LOOP
+		}
+
+		for {
+			traversed++
+
+			succ, marked := curr.next.getBoth()
+			r.Protect(succSlot, unsafe.Pointer(succ))
+			yieldPoint()
+
+			// succ is safe only if curr is still linked to pred and still points to succ
+			if node, mark := pred.next.getBoth(); node != curr || mark {
+				s.stats.restart()
+
+				attempt++
+				s.contentionManager.Backoff(attempt)
+
+				continue LOOP
+			}
+
+			if node, mark := curr.next.getBoth(); node != succ || mark != marked {
+				s.stats.restart()
+
+				attempt++
+				s.contentionManager.Backoff(attempt)
+
+				continue LOOP
+			}
+
+			if marked {
+				yieldPoint()
+
+				if !pred.next.compareAndSet(curr, succ, false, false) {
+					s.stats.casFailure()
+					s.stats.restart()
+
+					attempt++
+					s.contentionManager.Backoff(attempt)
+
+					continue LOOP
+				}
+
+				s.stats.snip()
+				r.Retire(nonBlockingNodeClass, unsafe.Pointer(curr))
+
+				curr = succ
+				currSlot, succSlot = succSlot, currSlot
+
+				continue
+			}
+
+			if curr.value >= val {
+				s.stats.traverse(traversed)
+
+				return window{pred: pred, curr: curr}
+			}
+
+			pred = curr
+			curr = succ
+			predSlot, currSlot, succSlot = currSlot, succSlot, predSlot
+		}
+	}
+}
+
+func (s *hazardNonBlockingSet) Insert(value int) bool {
+	if reserved(value) {
+		s.hooks.failed(OperationInsert, value)
+
+		return false
+	}
+
+	r := s.domain.Acquire()
+	defer r.Release()
+
+	for attempt := 1; ; attempt++ {
+		w := s.findWindow(r, value)
+		pred := w.pred
+		curr := w.curr
+
+		if curr.value == value {
+			s.stats.operation()
+			s.hooks.failed(OperationInsert, value)
+
+			return false
+		}
+
+		newNode := s.newNode(r, value, curr)
+
+		yieldPoint()
+
+		if pred.next.compareAndSet(curr, newNode, false, false) {
+			s.stats.operation()
+			s.hooks.inserted(value)
+
+			return true
+		}
+
+		s.stats.casFailure()
+		s.hooks.retried(OperationInsert, value, attempt)
+		s.contentionManager.Backoff(attempt)
+	}
+}
+
+func (s *hazardNonBlockingSet) Contains(value int) bool {
+	if reserved(value) {
+		return s.hooks.result(OperationContains, value, false)
+	}
+
+	r := s.domain.Acquire()
+	defer r.Release()
+
+	w := s.findWindow(r, value)
+
+	s.stats.operation()
+
+	return s.hooks.result(OperationContains, value, w.curr.value == value)
+}
+
+func (s *hazardNonBlockingSet) Remove(value int) bool {
+	if reserved(value) {
+		s.hooks.failed(OperationRemove, value)
+
+		return false
+	}
+
+	r := s.domain.Acquire()
+	defer r.Release()
+
+	for attempt := 1; ; attempt++ {
+		w := s.findWindow(r, value)
+		pred := w.pred
+		curr := w.curr
+
+		if curr.value != value {
+			s.stats.operation()
+			s.hooks.failed(OperationRemove, value)
+
+			return false
+		}
+
+		// succ can't be unlinked while marked curr stays in the list, so it needs no protection
+		succ := curr.next.getNode()
+
+		yieldPoint()
+
+		snip := curr.next.compareAndSet(succ, succ, false, true)
+
+		if !snip {
+			s.stats.casFailure()
+			s.hooks.retried(OperationRemove, value, attempt)
+			s.contentionManager.Backoff(attempt)
+
+			continue
+		}
+
+		// the node is removed logically
+		s.hooks.removed(value)
+
+		yieldPoint()
+
+		// if physical removal fails, let findWindow snip (and retire) the node,
+		// so that no marked node stays reachable after the operation completes
+		if pred.next.compareAndSet(curr, succ, false, false) {
+			r.Retire(nonBlockingNodeClass, unsafe.Pointer(curr))
+		} else {
+			s.stats.casFailure()
+			s.findWindow(r, value)
+		}
+
+		s.stats.operation()
+
+		return true
+	}
+}
+
+// newNode takes a node from the free list of the record or allocates a new one.
+func (s *hazardNonBlockingSet) newNode(r *hazard.Record, value int, next *nonBlockingNode) *nonBlockingNode {
+	if n := (*nonBlockingNode)(r.Reuse(nonBlockingNodeClass)); n != nil {
+		n.value = value
+		n.next.set(nil, next, false)
+
+		return n
+	}
+
+	return &nonBlockingNode{value: value, next: newAtomicMarkableReference(next, false)}
+}
+
+func (s *hazardNonBlockingSet) appender() func(value int) {
+	return appendNonBlocking(s.head, s.hooks)
+}
+
+// Ascend keeps the current node in its own hazard slot. The successor is safe when the current node
+// still points to it and is not marked; otherwise the walk resumes with findWindow after the last reported value.
+func (s *hazardNonBlockingSet) Ascend(yield func(value int) bool) {
+	r := s.domain.Acquire()
+	defer r.Release()
+
+	// curr is protected by findWindow until it's copied to ascendSlot
+	curr := s.findWindow(r, math.MinInt64+1).curr
+
+	for {
+		r.Protect(ascendSlot, unsafe.Pointer(curr))
+
+		if curr.value == math.MaxInt64 || !yield(curr.value) {
+			return
+		}
+
+		succ, marked := curr.next.getBoth()
+		r.Protect(0, unsafe.Pointer(succ))
+		yieldPoint()
+
+		if node, mark := curr.next.getBoth(); marked || mark || node != succ {
+			curr = s.findWindow(r, curr.value+1).curr
+
+			continue
+		}
+
+		curr = succ
+	}
+}
+
+func (s *hazardNonBlockingSet) checkInvariants() error {
+	if s.head.value != math.MinInt64 {
+		return &invariantError{err: errWrongHead, value: s.head.value}
+	}
+
+	pred := s.head
+
+	for i := 1; ; i++ {
+		curr := pred.next.getNode()
+		if curr == nil {
+			return &invariantError{err: errTailUnreachable, position: i - 1, value: pred.value}
+		}
+
+		if curr.value <= pred.value {
+			return &invariantError{err: errNotAscending, position: i, value: curr.value}
+		}
+
+		if curr.next.getMark() {
+			return &invariantError{err: errMarkedReachable, position: i, value: curr.value}
+		}
+
+		if curr.value == math.MaxInt64 {
+			return nil
+		}
+
+		pred = curr
+	}
+}
+
+// NewHazardNonBlockingSyncSet builds lock-free implementation of set that recycles removed nodes
+// with hazard pointers protection.
+func NewHazardNonBlockingSyncSet(opts ...Option) Set {
+	o := newOptions(opts)
+
+	s := &hazardNonBlockingSet{
+		contentionManager: o.contentionManager,
+		hooks:             o.hooks,
+		domain:            hazard.NewDomain(hazardSlots, 1, hazardScanThreshold, 0),
+	}
+
+	head := &nonBlockingNode{value: math.MinInt64}
+	tail := &nonBlockingNode{value: math.MaxInt64}
+
+	head.next = newAtomicMarkableReference(tail, false)
+	tail.next = newAtomicMarkableReference(nil, false)
+
+	s.head = head
+
+	return o.trace(s, hazardNonBlockingName)
+}
@@ -0,0 +1,102 @@
+package set
+
+import (
+	"sync"
+	"sync/atomic"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestHazardNonBlockingSetRecycling(t *testing.T) {
+	s, ok := NewHazardNonBlockingSyncSet().(*hazardNonBlockingSet)
+	require.True(t, ok)
+
+	// retire more nodes than scan threshold
+	const items = 2 * hazardScanThreshold
+
+	removed := make(map[*nonBlockingNode]struct{}, items)
+
+	for i := 0; i < items; i++ {
+		require.True(t, s.Insert(i))
+		removed[s.head.next.getNode()] = struct{}{}
+		require.True(t, s.Remove(i))
+	}
+
+	require.True(t, s.Insert(items))
+
+	_, reused := removed[s.head.next.getNode()]
+	require.True(t, reused)
+	require.True(t, s.Contains(items))
+	require.False(t, s.Contains(0))
+}
+
+// TestHazardNonBlockingSetChurn stresses reclamation with heavy Remove churn:
+// writers keep inserting and removing the same keys, while readers check that
+// the keys that are never removed stay visible.
+func TestHazardNonBlockingSetChurn(t *testing.T) {
+	const (
+		writers = 8
+		readers = 4
+		items   = 128
+		rounds  = 200
+	)
+
+	set := NewHazardNonBlockingSyncSet()
+
+	// odd keys are stable, even keys are churned
+	for j := 1; j < items; j += 2 {
+		require.True(t, set.Insert(j))
+	}
+
+	var (
+		stop       int32
+		violations int64
+	)
+
+	readersWG := sync.WaitGroup{}
+	readersWG.Add(readers)
+
+	for i := 0; i < readers; i++ {
+		go func() {
+			defer readersWG.Done()
+
+			for atomic.LoadInt32(&stop) == 0 {
+				for j := 1; j < items; j += 2 {
+					if !set.Contains(j) {
+						atomic.AddInt64(&violations, 1)
+					}
+				}
+			}
+		}()
+	}
+
+	writersWG := sync.WaitGroup{}
+	writersWG.Add(writers)
+
+	for i := 0; i < writers; i++ {
+		go func() {
+			defer writersWG.Done()
+
+			for r := 0; r < rounds; r++ {
+				for j := 0; j < items; j += 2 {
+					set.Insert(j)
+				}
+
+				for j := 0; j < items; j += 2 {
+					set.Remove(j)
+				}
+			}
+		}()
+	}
+
+	writersWG.Wait()
+	atomic.StoreInt32(&stop, 1)
+	readersWG.Wait()
+
+	require.Zero(t, atomic.LoadInt64(&violations))
+
+	for j := 0; j < items; j++ {
+		require.Equal(t, j%2 == 1, set.Contains(j), j)
+	}
+}
@@ -2,48 +2,77 @@ package set
 
 import (
 	"math"
+	"unsafe"
 )
 
 var _ Set = (*optimisticSyncSet)(nil)
 
 type optimisticSyncSet struct {
-	head *syncNode
+	head              *syncNode
+	stats             statsCounters
+	contentionManager ContentionManager
+	hooks             Hooks
 }
 
 func (s *optimisticSyncSet) Insert(value int) bool {
-	for {
+	if reserved(value) {
+		s.hooks.failed(OperationInsert, value)
+
+		return false
+	}
+
+	for attempt := 1; ; attempt++ {
 		result, repeat := s.insertLoopBody(value)
 		if !repeat {
+			s.stats.operation()
+
 			return result
 		}
+
+		s.stats.validationFailure()
+		s.hooks.retried(OperationInsert, value, attempt)
+		s.contentionManager.Backoff(attempt)
 	}
 }
 
 //nolint:dupl // it's better to copy-paste code than messing with inheritance
 func (s *optimisticSyncSet) insertLoopBody(value int) (result, repeat bool) {
 	pred := s.head
-	curr := pred.next
+	curr := pred.getNext()
+	traversed := 1
 
 	for curr.value < value {
+		yieldPoint()
+
 		pred = curr
-		curr = curr.next
+		curr = curr.getNext()
+		traversed++
 	}
 
-	pred.Lock()
-	curr.Lock()
+	s.stats.traverse(traversed)
+
+	s.stats.lock(&pred.nodeMutex)
+	s.stats.lock(&curr.nodeMutex)
 
 	defer func() {
 		curr.Unlock()
 		pred.Unlock()
 	}()
 
+	yieldPoint()
+
 	if s.validate(pred, curr) {
+		s.hooks.locked(OperationInsert, value)
+
 		if curr.value == value {
+			s.hooks.failed(OperationInsert, value)
+
 			return false, false
 		}
 
-		newNode := &syncNode{value: value, next: curr}
-		pred.next = newNode
+		newNode := &syncNode{value: value, next: unsafe.Pointer(curr)}
+		pred.setNext(newNode)
+		s.hooks.inserted(value)
 
 		return true, false
 	}
@@ -52,71 +81,116 @@ func (s *optimisticSyncSet) insertLoopBody(value int) (result, repeat bool) {
 }
 
 func (s *optimisticSyncSet) Contains(value int) bool {
-	for {
+	if reserved(value) {
+		return s.hooks.result(OperationContains, value, false)
+	}
+
+	for attempt := 1; ; attempt++ {
 		result, repeat := s.containsLoopBody(value)
 		if !repeat {
+			s.stats.operation()
+
 			return result
 		}
+
+		s.stats.validationFailure()
+		s.hooks.retried(OperationContains, value, attempt)
+		s.contentionManager.Backoff(attempt)
 	}
 }
 
 func (s *optimisticSyncSet) containsLoopBody(value int) (result, repeat bool) {
 	pred := s.head
-	curr := pred.next
+	curr := pred.getNext()
+	traversed := 1
 
 	for curr.value < value {
+		yieldPoint()
+
 		pred = curr
-		curr = curr.next
+		curr = curr.getNext()
+		traversed++
 	}
 
-	pred.Lock()
-	curr.Lock()
+	s.stats.traverse(traversed)
+
+	s.stats.lock(&pred.nodeMutex)
+	s.stats.lock(&curr.nodeMutex)
 
 	defer func() {
 		curr.Unlock()
 		pred.Unlock()
 	}()
 
+	yieldPoint()
+
 	if s.validate(pred, curr) {
-		return curr.value == value, false
+		s.hooks.locked(OperationContains, value)
+
+		return s.hooks.result(OperationContains, value, curr.value == value), false
 	}
 
 	return false, true
 }
 
 func (s *optimisticSyncSet) Remove(value int) bool {
-	for {
+	if reserved(value) {
+		s.hooks.failed(OperationRemove, value)
+
+		return false
+	}
+
+	for attempt := 1; ; attempt++ {
 		result, repeat := s.removeLoopBody(value)
 		if !repeat {
+			s.stats.operation()
+
 			return result
 		}
+
+		s.stats.validationFailure()
+		s.hooks.retried(OperationRemove, value, attempt)
+		s.contentionManager.Backoff(attempt)
 	}
 }
 
 func (s *optimisticSyncSet) removeLoopBody(value int) (result, repeat bool) {
 	pred := s.head
-	curr := s.head.next
+	curr := s.head.getNext()
+	traversed := 1
 
 	for curr.value < value {
+		yieldPoint()
+
 		pred = curr
-		curr = curr.next
+		curr = curr.getNext()
+		traversed++
 	}
 
-	pred.Lock()
-	curr.Lock()
+	s.stats.traverse(traversed)
+
+	s.stats.lock(&pred.nodeMutex)
+	s.stats.lock(&curr.nodeMutex)
 
 	defer func() {
 		curr.Unlock()
 		pred.Unlock()
 	}()
 
+	yieldPoint()
+
 	if s.validate(pred, curr) {
+		s.hooks.locked(OperationRemove, value)
+
 		if curr.value == value {
-			pred.next = curr.next
+			pred.setNext(curr.getNext())
+			s.hooks.removed(value)
 
 			return true, false
 		}
 
+		s.hooks.failed(OperationRemove, value)
+
 		return false, false
 	}
 
@@ -124,21 +198,106 @@ func (s *optimisticSyncSet) removeLoopBody(value int) (result, repeat bool) {
 }
 
 func (s *optimisticSyncSet) validate(pred, curr *syncNode) bool {
-	for n := s.head; n.value <= pred.value; n = n.next {
+	traversed := 0
+
+	for n := s.head; n.value <= pred.value; n = n.getNext() {
+		yieldPoint()
+
+		traversed++
+
 		if n == pred {
-			return pred.next == curr
+			s.stats.traverse(traversed)
+
+			return pred.getNext() == curr
 		}
 	}
 
+	s.stats.traverse(traversed)
+
 	return false
 }
 
+func (s *optimisticSyncSet) appender() func(value int) {
+	last := s.head
+
+	return func(value int) {
+		newNode := &syncNode{value: value, next: unsafe.Pointer(last.getNext())}
+		last.setNext(newNode)
+		last = newNode
+		s.hooks.inserted(value)
+	}
+}
+
+// Ascend doesn't lock the nodes: a removed node keeps its next reference, so the walk always reaches the tail.
+func (s *optimisticSyncSet) Ascend(yield func(value int) bool) {
+	for curr := s.head.getNext(); curr.value != math.MaxInt64; curr = curr.getNext() {
+		if !yield(curr.value) {
+			return
+		}
+	}
+}
+
+// Snapshot locks all nodes in list order, like a traversal that never releases the locks:
+// the writers need the locks of their windows, so the list can't change once the tail is locked.
+func (s *optimisticSyncSet) Snapshot() Set {
+	snapshot := &snapshotSet{values: []int{}}
+	locked := []*syncNode{s.head}
+
+	s.stats.lock(&s.head.nodeMutex)
+
+	for curr := s.head.getNext(); ; curr = curr.getNext() {
+		yieldPoint()
+
+		s.stats.lock(&curr.nodeMutex)
+		locked = append(locked, curr)
+
+		if curr.value == math.MaxInt64 {
+			break
+		}
+
+		snapshot.values = append(snapshot.values, curr.value)
+	}
+
+	for _, n := range locked {
+		n.Unlock()
+	}
+
+	return snapshot
+}
+
+func (s *optimisticSyncSet) checkInvariants() error {
+	if s.head.value != -math.MaxInt64 {
+		return &invariantError{err: errWrongHead, value: s.head.value}
+	}
+
+	pred := s.head
+
+	for i := 1; ; i++ {
+		curr := pred.getNext()
+		if curr == nil {
+			return &invariantError{err: errTailUnreachable, position: i - 1, value: pred.value}
+		}
+
+		if curr.value <= pred.value {
+			return &invariantError{err: errNotAscending, position: i, value: curr.value}
+		}
+
+		if curr.value == math.MaxInt64 {
+			return nil
+		}
+
+		pred = curr
+	}
+}
+
 // NewOptimisticSyncSet provides optimistic thread-safe set implementation with a mutex in every list node.
-func NewOptimisticSyncSet() Set {
+func NewOptimisticSyncSet(opts ...Option) Set {
+	o := newOptions(opts)
+
 	// set must contain sentinel nodes with minimal and maximal values
-	s := &optimisticSyncSet{}
+	s := &optimisticSyncSet{contentionManager: o.contentionManager, hooks: o.hooks}
 	s.head = &syncNode{value: -math.MaxInt64}
-	s.head.next = &syncNode{value: math.MaxInt64}
+	s.head.setNext(&syncNode{value: math.MaxInt64})
 
-	return s
+	return o.trace(s, optimisticName)
 }
@@ -13,66 +13,179 @@ var _ Set = (*sequentialSet)(nil)
 
 // thread-unsafe implementation of linked-list based set.
 type sequentialSet struct {
-	head *node
+	head  *node
+	hooks Hooks
 }
 
 func (s *sequentialSet) Insert(value int) bool {
+	if reserved(value) {
+		s.hooks.failed(OperationInsert, value)
+
+		return false
+	}
+
 	pred := s.head
 	curr := pred.next
 
 	for curr.value < value {
+		yieldPoint()
+
 		pred = curr
 		curr = pred.next
 	}
 
 	if curr.value == value {
+		s.hooks.failed(OperationInsert, value)
+
 		return false
 	}
 
 	newNode := &node{value: value, next: curr}
 	pred.next = newNode
+	s.hooks.inserted(value)
 
 	return true
 }
 
-func (s sequentialSet) Contains(value int) bool {
+func (s *sequentialSet) Contains(value int) bool {
+	if reserved(value) {
+		return s.hooks.result(OperationContains, value, false)
+	}
+
 	var (
 		pred *node
 		curr = s.head.next
 	)
 
 	for curr.value < value {
+		yieldPoint()
+
 		pred = curr
 		curr = pred.next
 	}
 
-	return curr.value == value
+	return s.hooks.result(OperationContains, value, curr.value == value)
 }
 
 func (s *sequentialSet) Remove(value int) bool {
+	if reserved(value) {
+		s.hooks.failed(OperationRemove, value)
+
+		return false
+	}
+
 	pred := s.head
 	curr := s.head.next
 
 	for curr.value < value {
+		yieldPoint()
+
 		pred = curr
 		curr = pred.next
 	}
 
 	if curr.value == value {
 		pred.next = curr.next
+		s.hooks.removed(value)
 
 		return true
 	}
 
+	s.hooks.failed(OperationRemove, value)
+
 	return false
 }
 
+func (s *sequentialSet) Ascend(yield func(value int) bool) {
+	for curr := s.head.next; curr.value != math.MaxInt64; curr = curr.next {
+		if !yield(curr.value) {
+			return
+		}
+	}
+}
+
+func (s *sequentialSet) appender() func(value int) {
+	last := s.head
+
+	return func(value int) {
+		last.next = &node{value: value, next: last.next}
+		last = last.next
+		s.hooks.inserted(value)
+	}
+}
+
+// applyBatch continues the traversal from the window of the previous key.
+func (s *sequentialSet) applyBatch(op Operation, keys []batchKey, results []bool) {
+	pred := s.head
+	curr := pred.next
+
+	for _, key := range keys {
+		value := key.value
+
+		for curr.value < value {
+			yieldPoint()
+
+			pred = curr
+			curr = pred.next
+		}
+
+		switch {
+		case op == OperationContains:
+			results[key.index] = s.hooks.result(op, value, curr.value == value)
+		case op == OperationInsert && curr.value != value:
+			// the new node becomes curr, so that a duplicate of the value fails
+			curr = &node{value: value, next: curr}
+			pred.next = curr
+			results[key.index] = true
+			s.hooks.inserted(value)
+		case op == OperationRemove && curr.value == value:
+			pred.next = curr.next
+			curr = curr.next
+			results[key.index] = true
+			s.hooks.removed(value)
+		default:
+			s.hooks.failed(op, value)
+		}
+	}
+}
+
+func (s *sequentialSet) Snapshot() Set {
+	return collectValues(s)
+}
+
+func (s *sequentialSet) checkInvariants() error {
+	if s.head.value != -math.MaxInt64 {
+		return &invariantError{err: errWrongHead, value: s.head.value}
+	}
+
+	pred := s.head
+
+	for i := 1; ; i++ {
+		curr := pred.next
+		if curr == nil {
+			return &invariantError{err: errTailUnreachable, position: i - 1, value: pred.value}
+		}
+
+		if curr.value <= pred.value {
+			return &invariantError{err: errNotAscending, position: i, value: curr.value}
+		}
+
+		if curr.value == math.MaxInt64 {
+			return nil
+		}
+
+		pred = curr
+	}
+}
+
 // NewSequentialSet provides simple thread-unsafe implementation of linked list based set.
-func NewSequentialSet() Set {
+func NewSequentialSet(opts ...Option) Set {
+	o := newOptions(opts)
+
 	// set must contain sentinel nodes with minimal and maximal values
-	s := &sequentialSet{}
+	s := &sequentialSet{hooks: o.hooks}
 	s.head = &node{value: -math.MaxInt64}
 	s.head.next = &node{value: math.MaxInt64}
 
-	return s
+	return o.trace(s, sequentialName)
 }
@@ -0,0 +1,448 @@
+package set
+
+import (
+	"math"
+	"sync"
+	"sync/atomic"
+	"unsafe"
+)
+
+// Tx is a transaction of TransactionalSet. Its operations see the effects of the previous operations
+// of the same transaction and nothing written by concurrent transactions after the transaction began.
+type Tx interface {
+	Insert(value int) bool
+	Contains(value int) bool
+	Remove(value int) bool
+}
+
+// TransactionalSet is a set supporting atomic multi-key transactions.
+type TransactionalSet interface {
+	Set
+	// Atomically runs f in a transaction. If f returns nil, all its operations take effect atomically;
+	// if it returns an error, none of them takes effect, and the error is returned.
+	// f may be run several times, when the transaction conflicts with concurrent ones, so it must have
+	// no side effects except the operations of tx. f must not recover panics and must not use tx after return.
+	Atomically(f func(tx Tx) error) error
+}
+
+// stmNode is protected by a versioned lock: the lowest bit is the lock, the rest is the version,
+// i.e. the value of the global clock at the last commit that changed next.
+type stmNode struct {
+	_ stmNodePad
+	// the lock goes first to be 64-bit aligned for atomic operations on 32-bit platforms
+	lock  uint64
+	next  unsafe.Pointer // *stmNode
+	value int
+}
+
+func (n *stmNode) getNext() *stmNode {
+	return (*stmNode)(atomic.LoadPointer(&n.next))
+}
+
+// tryLock acquires the lock and returns the version it protected.
+func (n *stmNode) tryLock() (uint64, bool) {
+	l := atomic.LoadUint64(&n.lock)
+	if l&1 != 0 || !atomic.CompareAndSwapUint64(&n.lock, l, l|1) {
+		return 0, false
+	}
+
+	return l >> 1, true
+}
+
+// unlock releases the lock setting the given version.
+func (n *stmNode) unlock(version uint64) {
+	atomic.StoreUint64(&n.lock, version<<1)
+}
+
+// stmConflict aborts the transaction that has read inconsistent data; Atomically recovers it and retries.
+type stmConflict struct{}
+
+// stmWrite is an entry of the write set: the new next of the node.
+type stmWrite struct {
+	node    *stmNode
+	next    *stmNode
+	version uint64 // the version of the node when it was locked by commit
+}
+
+// stmEvent is an operation of the transaction reported to hooks once it commits.
+type stmEvent struct {
+	op    Operation
+	value int
+	ok    bool
+}
+
+// stmTx implements TL2: it reads the nodes whose version does not exceed the clock at the beginning
+// of the transaction, buffers the writes, and on commit locks the written nodes, advances the clock
+// and validates that none of the read nodes has changed since then.
+type stmTx struct {
+	set         *stmSet
+	readVersion uint64
+	reads       []*stmNode
+	writes      []stmWrite
+	events      []stmEvent
+}
+
+func (tx *stmTx) begin() {
+	tx.readVersion = atomic.LoadUint64(&tx.set.clock)
+	tx.reads = tx.reads[:0]
+	tx.writes = tx.writes[:0]
+	tx.events = tx.events[:0]
+}
+
+// read returns next of the node as seen by the transaction.
+func (tx *stmTx) read(n *stmNode) *stmNode {
+	for i := range tx.writes {
+		if tx.writes[i].node == n {
+			return tx.writes[i].next
+		}
+	}
+
+	before := atomic.LoadUint64(&n.lock)
+	next := n.getNext()
+	after := atomic.LoadUint64(&n.lock)
+
+	if before != after || before&1 != 0 || before>>1 > tx.readVersion {
+		panic(stmConflict{})
+	}
+
+	tx.reads = append(tx.reads, n)
+
+	return next
+}
+
+func (tx *stmTx) write(n, next *stmNode) {
+	for i := range tx.writes {
+		if tx.writes[i].node == n {
+			tx.writes[i].next = next
+
+			return
+		}
+	}
+
+	tx.writes = append(tx.writes, stmWrite{node: n, next: next})
+}
+
+func (tx *stmTx) find(value int) (pred, curr *stmNode) {
+	pred = tx.set.head
+	curr = tx.read(pred)
+
+	for curr.value < value {
+		yieldPoint()
+
+		pred = curr
+		curr = tx.read(curr)
+	}
+
+	return pred, curr
+}
+
+func (tx *stmTx) Insert(value int) bool {
+	pred, curr := tx.find(value)
+	ok := curr.value != value && !reserved(value)
+
+	if ok {
+		// the new node is private until commit, so its next needs no versioning
+		tx.write(pred, &stmNode{value: value, next: unsafe.Pointer(curr)})
+	}
+
+	tx.events = append(tx.events, stmEvent{op: OperationInsert, value: value, ok: ok})
+
+	return ok
+}
+
+func (tx *stmTx) Contains(value int) bool {
+	_, curr := tx.find(value)
+	ok := curr.value == value && !reserved(value)
+
+	tx.events = append(tx.events, stmEvent{op: OperationContains, value: value, ok: ok})
+
+	return ok
+}
+
+func (tx *stmTx) Remove(value int) bool {
+	pred, curr := tx.find(value)
+	ok := curr.value == value && !reserved(value)
+
+	if ok {
+		succ := tx.read(curr)
+		tx.write(pred, succ)
+		// the removed node is written too, so that transactions inserting right after it conflict
+		tx.write(curr, succ)
+	}
+
+	tx.events = append(tx.events, stmEvent{op: OperationRemove, value: value, ok: ok})
+
+	return ok
+}
+
+// run executes f once; it returns false if the transaction has to be retried.
+func (tx *stmTx) run(f func(tx *stmTx) error) (committed bool, err error) {
+	defer func() {
+		if r := recover(); r != nil {
+			if _, ok := r.(stmConflict); !ok {
+				panic(r)
+			}
+
+			committed, err = false, nil
+		}
+	}()
+
+	if err := f(tx); err != nil {
+		return false, err
+	}
+
+	return tx.commit(), nil
+}
+
+func (tx *stmTx) commit() bool {
+	// read-only transaction is consistent already: every read has been validated against readVersion
+	if len(tx.writes) == 0 {
+		tx.report()
+
+		return true
+	}
+
+	for i := range tx.writes {
+		version, ok := tx.writes[i].node.tryLock()
+		if !ok {
+			tx.release(i)
+
+			return false
+		}
+
+		tx.writes[i].version = version
+	}
+
+	yieldPoint()
+
+	writeVersion := atomic.AddUint64(&tx.set.clock, 1)
+
+	// if nobody has committed since the transaction began, the read set can't have changed
+	if writeVersion != tx.readVersion+1 && !tx.validate() {
+		tx.release(len(tx.writes))
+
+		return false
+	}
+
+	for i := range tx.writes {
+		atomic.StorePointer(&tx.writes[i].node.next, unsafe.Pointer(tx.writes[i].next))
+	}
+
+	// the hooks are called under the locks, so conflicting transactions report their operations in order
+	tx.report()
+
+	for i := range tx.writes {
+		tx.writes[i].node.unlock(writeVersion)
+	}
+
+	return true
+}
+
+// validate checks that the read nodes have not been changed or locked by other transactions.
+func (tx *stmTx) validate() bool {
+	for _, n := range tx.reads {
+		l := atomic.LoadUint64(&n.lock)
+		version := l >> 1
+
+		if l&1 != 0 {
+			own := false
+
+			for i := range tx.writes {
+				if tx.writes[i].node == n {
+					own, version = true, tx.writes[i].version
+
+					break
+				}
+			}
+
+			if !own {
+				return false
+			}
+		}
+
+		if version > tx.readVersion {
+			return false
+		}
+	}
+
+	return true
+}
+
+// release unlocks the first n nodes of the write set restoring their versions.
+func (tx *stmTx) release(n int) {
+	for i := 0; i < n; i++ {
+		tx.writes[i].node.unlock(tx.writes[i].version)
+	}
+}
+
+func (tx *stmTx) report() {
+	for _, e := range tx.events {
+		tx.set.stats.operation()
+
+		switch {
+		case !e.ok:
+			tx.set.hooks.failed(e.op, e.value)
+		case e.op == OperationInsert:
+			tx.set.hooks.inserted(e.value)
+		case e.op == OperationRemove:
+			tx.set.hooks.removed(e.value)
+		}
+	}
+}
+
+var (
+	_ TransactionalSet = (*stmSet)(nil)
+	_ Iterable         = (*stmSet)(nil)
+	_ StatsProvider    = (*stmSet)(nil)
+	_ invariantChecker = (*stmSet)(nil)
+)
+
+// stmSet is a sorted list whose nodes are accessed only by transactions of software transactional memory.
+type stmSet struct {
+	// clock is the global version clock of TL2; it goes first to be 64-bit aligned on 32-bit platforms
+	clock             uint64
+	head              *stmNode
+	stats             statsCounters
+	contentionManager ContentionManager
+	hooks             Hooks
+	txs               sync.Pool
+}
+
+func (s *stmSet) Atomically(f func(tx Tx) error) error {
+	return s.atomically(func(tx *stmTx) error { return f(tx) })
+}
+
+func (s *stmSet) atomically(f func(tx *stmTx) error) error {
+	tx, ok := s.txs.Get().(*stmTx)
+	if !ok {
+		tx = &stmTx{set: s}
+	}
+
+	defer s.txs.Put(tx)
+
+	for attempt := 1; ; attempt++ {
+		tx.begin()
+
+		committed, err := tx.run(f)
+		if err != nil {
+			return err
+		}
+
+		if committed {
+			s.stats.traverse(len(tx.reads))
+
+			return nil
+		}
+
+		s.stats.validationFailure()
+		s.contentionManager.Backoff(attempt)
+
+		// a conflicting transaction may hold the lock of head, let it proceed
+		yieldPoint()
+	}
+}
+
+func (s *stmSet) Insert(value int) bool {
+	return s.single(func(tx Tx) bool { return tx.Insert(value) })
+}
+
+func (s *stmSet) Contains(value int) bool {
+	return s.single(func(tx Tx) bool { return tx.Contains(value) })
+}
+
+func (s *stmSet) Remove(value int) bool {
+	return s.single(func(tx Tx) bool { return tx.Remove(value) })
+}
+
+// single runs a transaction of a single operation.
+func (s *stmSet) single(op func(tx Tx) bool) bool {
+	var result bool
+
+	_ = s.Atomically(func(tx Tx) error {
+		result = op(tx)
+
+		return nil
+	})
+
+	return result
+}
+
+func (s *stmSet) appender() func(value int) {
+	last := s.head
+
+	return func(value int) {
+		newNode := &stmNode{value: value, next: last.next}
+		last.next = unsafe.Pointer(newNode)
+		last = newNode
+		s.hooks.inserted(value)
+	}
+}
+
+// Ascend reads the values in a single read-only transaction, so they form a snapshot of the set.
+func (s *stmSet) Ascend(yield func(value int) bool) {
+	var values []int
+
+	_ = s.atomically(func(tx *stmTx) error {
+		values = values[:0]
+
+		for curr := tx.read(s.head); curr.value != math.MaxInt64; curr = tx.read(curr) {
+			values = append(values, curr.value)
+		}
+
+		return nil
+	})
+
+	for _, value := range values {
+		if !yield(value) {
+			return
+		}
+	}
+}
+
+func (s *stmSet) Stats() Stats { return s.stats.snapshot() }
+
+// Snapshot reads the list in a single read-only transaction.
+func (s *stmSet) Snapshot() Set {
+	return collectValues(s)
+}
+
+func (s *stmSet) checkInvariants() error {
+	if s.head.value != -math.MaxInt64 {
+		return &invariantError{err: errWrongHead, value: s.head.value}
+	}
+
+	pred := s.head
+
+	for i := 1; ; i++ {
+		curr := pred.getNext()
+		if curr == nil {
+			return &invariantError{err: errTailUnreachable, position: i - 1, value: pred.value}
+		}
+
+		if curr.value <= pred.value {
+			return &invariantError{err: errNotAscending, position: i, value: curr.value}
+		}
+
+		if curr.value == math.MaxInt64 {
+			return nil
+		}
+
+		pred = curr
+	}
+}
+
+// NewSTMSet builds a set supporting atomic multi-key transactions with TL2 software transactional memory.
+// The operations of Set interface are single-operation transactions.
+func NewSTMSet(opts ...Option) TransactionalSet {
+	o := newOptions(opts)
+
+	// set must contain sentinel nodes with minimal and maximal values
+	s := &stmSet{
+		contentionManager: o.contentionManager,
+		hooks:             o.hooks,
+	}
+	s.head = &stmNode{value: -math.MaxInt64}
+	s.head.next = unsafe.Pointer(&stmNode{value: math.MaxInt64})
+
+	return o.traceTransactional(s)
+}
@@ -0,0 +1,180 @@
+package set
+
+import (
+	"errors"
+	"math/rand"
+	"sync"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+var errTestAbort = errors.New("abort")
+
+// move transfers the value from one key to another, if the first one is present and the second is absent.
+func move(tx Tx, from, to int) error {
+	if !tx.Contains(from) || tx.Contains(to) {
+		return nil
+	}
+
+	tx.Remove(from)
+	tx.Insert(to)
+
+	return nil
+}
+
+func TestSTMAtomically(t *testing.T) {
+	set := NewSTMSet()
+
+	require.True(t, set.Insert(1))
+
+	// the operations of the transaction see each other
+	require.NoError(t, set.Atomically(func(tx Tx) error {
+		require.True(t, tx.Insert(2))
+		require.True(t, tx.Contains(2))
+		require.True(t, tx.Remove(1))
+		require.False(t, tx.Contains(1))
+		require.True(t, tx.Insert(3))
+		require.True(t, tx.Remove(3))
+
+		return nil
+	}))
+	require.Equal(t, []int{2}, ascend(t, set))
+
+	// an error discards all operations of the transaction
+	err := set.Atomically(func(tx Tx) error {
+		require.True(t, tx.Insert(5))
+		require.True(t, tx.Remove(2))
+
+		return errTestAbort
+	})
+	require.ErrorIs(t, err, errTestAbort)
+	require.Equal(t, []int{2}, ascend(t, set))
+
+	// insert X only if Y is absent
+	insertIfAbsent := func(x, y int) error {
+		return set.Atomically(func(tx Tx) error {
+			if !tx.Contains(y) {
+				tx.Insert(x)
+			}
+
+			return nil
+		})
+	}
+
+	require.NoError(t, insertIfAbsent(7, 2))
+	require.NoError(t, insertIfAbsent(8, 9))
+	require.Equal(t, []int{2, 8}, ascend(t, set))
+
+	checker, ok := set.(invariantChecker)
+	require.True(t, ok)
+	require.NoError(t, checker.checkInvariants())
+}
+
+// TestSTMConcurrentMoves moves a fixed number of tokens between keys concurrently:
+// the transactions observing the whole set must always see all of them.
+func TestSTMConcurrentMoves(t *testing.T) {
+	const (
+		tokens  = 8
+		keys    = 32
+		threads = 4
+		moves   = 2000
+	)
+
+	set := NewSTMSet()
+
+	for i := 0; i < tokens; i++ {
+		require.True(t, set.Insert(i))
+	}
+
+	wg := sync.WaitGroup{}
+	wg.Add(threads)
+
+	for i := 0; i < threads; i++ {
+		rnd := rand.New(rand.NewSource(int64(i))) //nolint:gosec // reproducible test data
+
+		go func() {
+			defer wg.Done()
+
+			for j := 0; j < moves; j++ {
+				from, to := rnd.Intn(keys), rnd.Intn(keys)
+				require.NoError(t, set.Atomically(func(tx Tx) error { return move(tx, from, to) }))
+			}
+		}()
+	}
+
+	done := make(chan struct{})
+
+	go func() {
+		wg.Wait()
+		close(done)
+	}()
+
+	for observed := false; !observed; {
+		select {
+		case <-done:
+			observed = true
+		default:
+		}
+
+		require.Len(t, ascend(t, set), tokens)
+
+		count := 0
+
+		require.NoError(t, set.Atomically(func(tx Tx) error {
+			count = 0
+
+			for key := 0; key < keys; key++ {
+				if tx.Contains(key) {
+					count++
+				}
+			}
+
+			return nil
+		}))
+		require.Equal(t, tokens, count)
+	}
+
+	checker, ok := set.(invariantChecker)
+	require.True(t, ok)
+	require.NoError(t, checker.checkInvariants())
+}
+
+// TestSTMHooks verifies that the hooks report only the operations of committed transactions.
+func TestSTMHooks(t *testing.T) {
+	recorder := newHookRecorder()
+	set := NewSTMSet(WithHooks(recorder.hooks()))
+
+	require.NoError(t, set.Atomically(func(tx Tx) error {
+		tx.Insert(1)
+		tx.Insert(2)
+		tx.Remove(2)
+		tx.Insert(1)
+
+		return nil
+	}))
+
+	require.ErrorIs(t, set.Atomically(func(tx Tx) error {
+		tx.Remove(1)
+
+		return errTestAbort
+	}), errTestAbort)
+
+	require.Equal(t, int64(2), recorder.inserted)
+	require.Equal(t, int64(1), recorder.removed)
+	require.Equal(t, [OperationRemove + 1]int64{0, 1, 0, 0}, recorder.failures)
+	require.Equal(t, map[int][]Operation{1: {OperationInsert}, 2: {OperationInsert, OperationRemove}}, recorder.events)
+}
+
+func TestSTMPanic(t *testing.T) {
+	set := NewSTMSet()
+
+	require.PanicsWithValue(t, "boom", func() {
+		_ = set.Atomically(func(tx Tx) error {
+			tx.Insert(1)
+			panic("boom")
+		})
+	})
+
+	require.False(t, set.Contains(1))
+}
@@ -1,6 +1,7 @@
 package set
 
 import (
+	"flag"
 	"sync"
 	"testing"
 
@@ -16,6 +17,9 @@ const (
 	optimistic
 	lazy
 	nonBlocking
+	hazardNonBlocking
+	stm
+	mvcc
 )
 
 func (k setKind) String() string {
@@ -32,6 +36,12 @@ func (k setKind) String() string {
 		return "lazy"
 	case nonBlocking:
 		return "nonblocking"
+	case hazardNonBlocking:
+		return "nonblocking_hazard"
+	case stm:
+		return "stm"
+	case mvcc:
+		return "mvcc"
 	default:
 		panic("unknown setKind")
 	}
@@ -39,20 +49,26 @@ func (k setKind) String() string {
 
 type factory struct{}
 
-func (factory) new(k setKind) Set {
+func (factory) new(k setKind, opts ...Option) Set {
 	switch k {
 	case sequential:
-		return NewSequentialSet()
+		return NewSequentialSet(opts...)
 	case coarseGrained:
-		return NewCoarseGrainedSyncSet()
+		return NewCoarseGrainedSyncSet(opts...)
 	case fineGrained:
-		return NewFineGrainedSyncSet()
+		return NewFineGrainedSyncSet(opts...)
 	case optimistic:
-		return NewOptimisticSyncSet()
+		return NewOptimisticSyncSet(opts...)
 	case lazy:
-		return NewLazySyncSet()
+		return NewLazySyncSet(opts...)
 	case nonBlocking:
-		return NewNonBlockingSyncSet()
+		return NewNonBlockingSyncSet(opts...)
+	case hazardNonBlocking:
+		return NewHazardNonBlockingSyncSet(opts...)
+	case stm:
+		return NewSTMSet(opts...)
+	case mvcc:
+		return NewMVCCSet(opts...)
 	default:
 		panic("unknown setKind")
 	}
@@ -69,6 +85,9 @@ func TestSequential(t *testing.T) {
 		optimistic,
 		lazy,
 		nonBlocking,
+		hazardNonBlocking,
+		stm,
+		mvcc,
 	}
 
 	for _, k := range kinds {
@@ -149,6 +168,11 @@ func TestSequential(t *testing.T) {
 	}
 }
 
+var (
+	concurrentThreads = flag.Int("concurrent.threads", 8, "number of goroutines in TestConcurrent")
+	concurrentItems   = flag.Int("concurrent.items", 1000, "number of items in TestConcurrent")
+)
+
 // TestConcurrent verifies concurrent CRUD operations of various set implementations.
 func TestConcurrent(t *testing.T) {
 	f := factory{}
@@ -159,16 +183,16 @@ func TestConcurrent(t *testing.T) {
 		optimistic,
 		lazy,
 		nonBlocking,
+		hazardNonBlocking,
+		stm,
+		mvcc,
 	}
 
+	threads, items := *concurrentThreads, *concurrentItems
+
 	for _, k := range kinds {
 		k := k
 
-		const (
-			threads = 8
-			items   = 1000
-		)
-
 		t.Run(k.String(), func(t *testing.T) {
 			t.Run("concurrent operations", func(t *testing.T) {
 				set := f.new(k)
@@ -210,6 +234,10 @@ func TestConcurrent(t *testing.T) {
 				}
 
 				wg.Wait()
+
+				checker, ok := set.(invariantChecker)
+				require.True(t, ok)
+				require.NoError(t, checker.checkInvariants())
 			})
 		})
 	}
@@ -0,0 +1,212 @@
+package set
+
+import (
+	"sort"
+	"sync"
+	"sync/atomic"
+	"unsafe"
+)
+
+// Snapshotter is implemented by all sets except HazardNonBlockingSyncSet.
+//
+// Snapshot returns an immutable sorted copy of the set. The snapshot is linearizable: it contains exactly
+// the values present at some moment between the call and the return, consistently with the results
+// of concurrent operations. Coarse-grained set copies the list under its read lock, fine-grained
+// and optimistic sets lock all nodes in list order, STM set reads the list in a transaction, MVCC set reads
+// its latest version, and lazy and non-blocking sets collect the snapshot without blocking the writers (see snapshotCollector).
+type Snapshotter interface {
+	Snapshot() Set
+}
+
+var (
+	_ Snapshotter = (*sequentialSet)(nil)
+	_ Snapshotter = (*coarseGrainedSyncSet)(nil)
+	_ Snapshotter = (*fineGrainedSyncSet)(nil)
+	_ Snapshotter = (*optimisticSyncSet)(nil)
+	_ Snapshotter = (*lazySyncSet)(nil)
+	_ Snapshotter = (*nonBlockingSet)(nil)
+	_ Snapshotter = (*stmSet)(nil)
+	_ Snapshotter = (*mvccSet)(nil)
+	_ Snapshotter = (*snapshotSet)(nil)
+	_ Snapshotter = (*tracedSnapshotter)(nil)
+	_ Snapshotter = (*tracedTransactionalSet)(nil)
+)
+
+var (
+	_ Set      = (*snapshotSet)(nil)
+	_ Iterable = (*snapshotSet)(nil)
+)
+
+// snapshotSet is an immutable set backed by a sorted slice.
+type snapshotSet struct {
+	values []int
+}
+
+func (s *snapshotSet) Insert(int) bool {
+	panic("snapshot is immutable")
+}
+
+func (s *snapshotSet) Contains(value int) bool {
+	i := sort.SearchInts(s.values, value)
+
+	return i < len(s.values) && s.values[i] == value
+}
+
+func (s *snapshotSet) Remove(int) bool {
+	panic("snapshot is immutable")
+}
+
+func (s *snapshotSet) Ascend(yield func(value int) bool) {
+	for _, value := range s.values {
+		if !yield(value) {
+			return
+		}
+	}
+}
+
+func (s *snapshotSet) Snapshot() Set { return s }
+
+// collectValues builds a snapshot of the values reported by Ascend of the set, which must not change meanwhile.
+func collectValues(s Iterable) *snapshotSet {
+	snapshot := &snapshotSet{values: []int{}}
+
+	s.Ascend(func(value int) bool {
+		snapshot.values = append(snapshot.values, value)
+
+		return true
+	})
+
+	return snapshot
+}
+
+// snapshotReport tells the collector about a node inserted or deleted concurrently with the collection.
+// A recycled node is reused under the same address, so the reports identify the node by its generation too.
+type snapshotReport struct {
+	node       unsafe.Pointer
+	next       *snapshotReport
+	value      int
+	generation uint32
+	deleted    bool
+}
+
+// snapshotNode identifies an incarnation of a node.
+type snapshotNode struct {
+	node       unsafe.Pointer
+	generation uint32
+}
+
+// blockedReports closes the list of reports of a finished collection.
+//
+//nolint:gochecknoglobals // sentinel
+var blockedReports = &snapshotReport{}
+
+// snapshotCollector implements the snapshot algorithm of Petrank and Timnat (Lock-Free Data-Structure Iterators, 2013).
+//
+// The taker publishes an active collector and traverses the list collecting the unmarked nodes. Meanwhile,
+// every operation that relies on a node being in the list (successful Insert, failed Insert and successful Contains)
+// reports the node as inserted, and every operation that marks a node or relies on its mark (successful Remove,
+// unlinking of a marked node, Contains finding a marked node) reports it as deleted. Then the taker deactivates
+// the collector, which is the linearization point of the snapshot, and blocks further reports. The snapshot
+// consists of the collected and reported-inserted nodes except the reported-deleted ones.
+type snapshotCollector struct {
+	reports unsafe.Pointer // *snapshotReport
+	active  int32
+}
+
+// push adds the report unless the reports are already blocked.
+func (c *snapshotCollector) push(r *snapshotReport) {
+	for {
+		head := atomic.LoadPointer(&c.reports)
+		if head == unsafe.Pointer(blockedReports) {
+			return
+		}
+
+		r.next = (*snapshotReport)(head)
+
+		if atomic.CompareAndSwapPointer(&c.reports, head, unsafe.Pointer(r)) {
+			return
+		}
+	}
+}
+
+// block returns the reports pushed so far and rejects the further ones.
+func (c *snapshotCollector) block() *snapshotReport {
+	return (*snapshotReport)(atomic.SwapPointer(&c.reports, unsafe.Pointer(blockedReports)))
+}
+
+// snapshots is embedded into the sets taking snapshots with snapshotCollector.
+type snapshots struct {
+	collector unsafe.Pointer // *snapshotCollector
+	// the snapshots are taken one at a time
+	mutex sync.Mutex
+}
+
+func (s *snapshots) reportInsert(node unsafe.Pointer, generation uint32, value int) {
+	s.report(node, generation, value, false)
+}
+
+func (s *snapshots) reportDelete(node unsafe.Pointer, generation uint32, value int) {
+	s.report(node, generation, value, true)
+}
+
+func (s *snapshots) report(node unsafe.Pointer, generation uint32, value int, deleted bool) {
+	c := (*snapshotCollector)(atomic.LoadPointer(&s.collector))
+	if c == nil || atomic.LoadInt32(&c.active) == 0 {
+		return
+	}
+
+	c.push(&snapshotReport{node: node, value: value, generation: generation, deleted: deleted})
+}
+
+// collect takes a snapshot; traverse must call add for every unmarked node of the list.
+func (s *snapshots) collect(traverse func(add func(node unsafe.Pointer, generation uint32, value int))) *snapshotSet {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+
+	c := &snapshotCollector{active: 1}
+	atomic.StorePointer(&s.collector, unsafe.Pointer(c))
+
+	var collected []snapshotReport
+
+	traverse(func(node unsafe.Pointer, generation uint32, value int) {
+		collected = append(collected, snapshotReport{node: node, value: value, generation: generation})
+	})
+
+	atomic.StoreInt32(&c.active, 0)
+	yieldPoint()
+
+	reports := c.block()
+
+	atomic.StorePointer(&s.collector, nil)
+
+	deleted := make(map[snapshotNode]bool)
+
+	for r := reports; r != nil; r = r.next {
+		if r.deleted {
+			deleted[snapshotNode{node: r.node, generation: r.generation}] = true
+		} else {
+			collected = append(collected, *r)
+		}
+	}
+
+	values := make([]int, 0, len(collected))
+
+	for _, r := range collected {
+		if !deleted[snapshotNode{node: r.node, generation: r.generation}] {
+			values = append(values, r.value)
+		}
+	}
+
+	sort.Ints(values)
+
+	// a node may be both collected and reported
+	unique := values[:0]
+
+	for _, value := range values {
+		if len(unique) == 0 || value != unique[len(unique)-1] {
+			unique = append(unique, value)
+		}
+	}
+
+	return &snapshotSet{values: unique}
+}
@@ -0,0 +1,309 @@
+package set
+
+import (
+	"sync"
+	"testing"
+	"unsafe"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestSnapshot(t *testing.T) {
+	f := factory{}
+
+	kinds := []setKind{
+		sequential,
+		coarseGrained,
+		fineGrained,
+		optimistic,
+		lazy,
+		nonBlocking,
+		stm,
+		mvcc,
+	}
+
+	for _, k := range kinds {
+		k := k
+
+		t.Run(k.String(), func(t *testing.T) {
+			set := f.new(k, WithTracing())
+
+			snapshotter, ok := set.(Snapshotter)
+			require.True(t, ok)
+
+			empty := snapshotter.Snapshot()
+			require.Empty(t, ascend(t, empty))
+
+			for _, value := range []int{3, 1, 2} {
+				require.True(t, set.Insert(value))
+			}
+
+			snapshot := snapshotter.Snapshot()
+
+			require.True(t, set.Remove(2))
+			require.True(t, set.Insert(4))
+
+			// the snapshot doesn't follow the set
+			require.Equal(t, []int{1, 2, 3}, ascend(t, snapshot))
+			require.True(t, snapshot.Contains(2))
+			require.False(t, snapshot.Contains(4))
+			require.Panics(t, func() { snapshot.Insert(5) })
+			require.Panics(t, func() { snapshot.Remove(1) })
+
+			require.Equal(t, []int{1, 3, 4}, ascend(t, snapshotter.Snapshot()))
+
+			checker, ok := set.(invariantChecker)
+			require.True(t, ok)
+			require.NoError(t, checker.checkInvariants())
+		})
+	}
+}
+
+// TestSnapshotConsistency takes snapshots while every writer inserts its own values in ascending order
+// and then removes them in ascending order. The writers complete the operations one after another, so a linearizable
+// snapshot must contain a contiguous range of the values of every writer, even though the values of different writers
+// are interleaved in the list. A weakly consistent iteration would see gaps.
+func TestSnapshotConsistency(t *testing.T) {
+	f := factory{}
+
+	kinds := []setKind{
+		coarseGrained,
+		fineGrained,
+		optimistic,
+		lazy,
+		nonBlocking,
+		stm,
+		mvcc,
+	}
+
+	const (
+		writers = 4
+		items   = 200
+		rounds  = 3
+	)
+
+	for _, k := range kinds {
+		k := k
+
+		t.Run(k.String(), func(t *testing.T) {
+			set := f.new(k, WithNodeRecycling())
+
+			snapshotter, ok := set.(Snapshotter)
+			require.True(t, ok)
+
+			wg := sync.WaitGroup{}
+			wg.Add(writers)
+
+			for i := 0; i < writers; i++ {
+				i := i
+
+				go func() {
+					defer wg.Done()
+
+					for r := 0; r < rounds; r++ {
+						for j := 0; j < items; j++ {
+							set.Insert(j*writers + i)
+						}
+
+						for j := 0; j < items; j++ {
+							set.Remove(j*writers + i)
+						}
+					}
+				}()
+			}
+
+			done := make(chan struct{})
+
+			go func() {
+				wg.Wait()
+				close(done)
+			}()
+
+			for finished := false; !finished; {
+				select {
+				case <-done:
+					finished = true
+				default:
+				}
+
+				values := ascend(t, snapshotter.Snapshot())
+
+				// the indices of the values of every writer
+				indices := make([][]int, writers)
+				for _, value := range values {
+					indices[value%writers] = append(indices[value%writers], value/writers)
+				}
+
+				for i, own := range indices {
+					for j := 1; j < len(own); j++ {
+						require.Equal(t, own[j-1]+1, own[j], "writer %d: %v", i, own)
+					}
+				}
+			}
+
+			require.Empty(t, ascend(t, snapshotter.Snapshot()))
+
+			checker, ok := set.(invariantChecker)
+			require.True(t, ok)
+			require.NoError(t, checker.checkInvariants())
+		})
+	}
+}
+
+// TestSnapshotContains verifies that a snapshot contains the values inserted before it and never removed,
+// while the neighbouring values are inserted and removed concurrently.
+func TestSnapshotContains(t *testing.T) {
+	f := factory{}
+
+	kinds := []setKind{
+		lazy,
+		nonBlocking,
+	}
+
+	const (
+		keys   = 64
+		rounds = 200
+	)
+
+	for _, k := range kinds {
+		k := k
+
+		t.Run(k.String(), func(t *testing.T) {
+			set := f.new(k)
+
+			snapshotter, ok := set.(Snapshotter)
+			require.True(t, ok)
+
+			done := make(chan struct{})
+			wg := sync.WaitGroup{}
+			wg.Add(1)
+
+			// the writer flips the odd values, the even values are always present
+			go func() {
+				defer wg.Done()
+
+				for j := 1; ; j = (j + 2) % keys {
+					select {
+					case <-done:
+						return
+					default:
+					}
+
+					set.Insert(j)
+					set.Remove(j)
+				}
+			}()
+
+			for value := 0; value < keys; value += 2 {
+				require.True(t, set.Insert(value))
+			}
+
+			for r := 0; r < rounds; r++ {
+				snapshot := snapshotter.Snapshot()
+
+				for value := 0; value < keys; value += 2 {
+					require.True(t, snapshot.Contains(value))
+				}
+			}
+
+			close(done)
+			wg.Wait()
+		})
+	}
+}
+
+// TestSnapshotRecycling takes snapshots while the writers insert and remove the same keys with node recycling,
+// so the nodes reported to the collector are reused under the same addresses during the collection.
+func TestSnapshotRecycling(t *testing.T) {
+	f := factory{}
+
+	kinds := []setKind{
+		lazy,
+		nonBlocking,
+	}
+
+	const (
+		writers = 4
+		keys    = 64
+		rounds  = 500
+	)
+
+	for _, k := range kinds {
+		k := k
+
+		t.Run(k.String(), func(t *testing.T) {
+			set := f.new(k, WithNodeRecycling())
+
+			snapshotter, ok := set.(Snapshotter)
+			require.True(t, ok)
+
+			// the even values are inserted after some churn, so they are stored in recycled nodes too
+			for value := 1; value < keys; value += 2 {
+				require.True(t, set.Insert(value))
+				require.True(t, set.Remove(value))
+			}
+
+			for value := 0; value < keys; value += 2 {
+				require.True(t, set.Insert(value))
+			}
+
+			done := make(chan struct{})
+			wg := sync.WaitGroup{}
+			wg.Add(writers)
+
+			// all writers flip the same odd values
+			for i := 0; i < writers; i++ {
+				go func() {
+					defer wg.Done()
+
+					for j := 1; ; j = (j + 2) % keys {
+						select {
+						case <-done:
+							return
+						default:
+						}
+
+						set.Insert(j)
+						set.Remove(j)
+					}
+				}()
+			}
+
+			for r := 0; r < rounds; r++ {
+				snapshot := snapshotter.Snapshot()
+
+				for value := 0; value < keys; value += 2 {
+					require.True(t, snapshot.Contains(value), value)
+				}
+
+				for _, value := range ascend(t, snapshot) {
+					require.True(t, value >= 0 && value < keys, value)
+				}
+			}
+
+			close(done)
+			wg.Wait()
+
+			checker, ok := set.(invariantChecker)
+			require.True(t, ok)
+			require.NoError(t, checker.checkInvariants())
+		})
+	}
+}
+
+// TestSnapshotCollectorGenerations verifies that a deletion reported for a node doesn't hide
+// the next incarnation of the node reused under the same address.
+func TestSnapshotCollectorGenerations(t *testing.T) {
+	var s snapshots
+
+	node := unsafe.Pointer(&lazySyncNode{})
+
+	snapshot := s.collect(func(add func(node unsafe.Pointer, generation uint32, value int)) {
+		// a stale report of the removal of 1, then the node is reused for 2
+		s.reportDelete(node, 0, 1)
+		add(node, 1, 2)
+		s.reportInsert(node, 1, 2)
+	})
+
+	require.Equal(t, []int{2}, snapshot.values)
+}
@@ -0,0 +1,64 @@
+package set
+
+import (
+	"time"
+)
+
+// Stats are the counters of internal events of a set instance. They are collected only when the package
+// is built with `-tags stats` (see StatsEnabled); otherwise the counting compiles to nothing and all counters stay zero.
+type Stats struct {
+	// Operations is the number of completed Insert, Contains and Remove calls.
+	Operations int64
+	// Traversed is the number of nodes visited by all traversals of optimistic, lazy and non-blocking sets,
+	// including the repeated ones.
+	Traversed int64
+	// ValidationFailures is the number of failed validations of optimistic and lazy sets.
+	ValidationFailures int64
+	// CASFailures is the number of failed compare-and-set operations of non-blocking sets.
+	CASFailures int64
+	// Restarts is the number of findWindow traversals restarted from head.
+	Restarts int64
+	// Snips is the number of marked nodes physically removed by findWindow on behalf of the removing operation.
+	Snips int64
+	// LockWait is the total time spent waiting for contended locks by lock-based sets.
+	LockWait time.Duration
+}
+
+// TraversedPerOperation returns the average number of nodes visited by an operation.
+func (s Stats) TraversedPerOperation() float64 {
+	if s.Operations == 0 {
+		return 0
+	}
+
+	return float64(s.Traversed) / float64(s.Operations)
+}
+
+// StatsProvider is implemented by all sets of the package. The sequential baseline collects nothing
+// and always reports zero Stats.
+type StatsProvider interface {
+	Stats() Stats
+}
+
+var (
+	_ StatsProvider = (*sequentialSet)(nil)
+	_ StatsProvider = (*coarseGrainedSyncSet)(nil)
+	_ StatsProvider = (*fineGrainedSyncSet)(nil)
+	_ StatsProvider = (*optimisticSyncSet)(nil)
+	_ StatsProvider = (*lazySyncSet)(nil)
+	_ StatsProvider = (*nonBlockingSet)(nil)
+	_ StatsProvider = (*hazardNonBlockingSet)(nil)
+)
+
+func (s *sequentialSet) Stats() Stats { return Stats{} }
+
+func (c *coarseGrainedSyncSet) Stats() Stats { return c.stats.snapshot() }
+
+func (s *fineGrainedSyncSet) Stats() Stats { return s.stats.snapshot() }
+
+func (s *optimisticSyncSet) Stats() Stats { return s.stats.snapshot() }
+
+func (s *lazySyncSet) Stats() Stats { return s.stats.snapshot() }
+
+func (s *nonBlockingSet) Stats() Stats { return s.stats.snapshot() }
+
+func (s *hazardNonBlockingSet) Stats() Stats { return s.stats.snapshot() }
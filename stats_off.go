@@ -0,0 +1,23 @@
+//go:build !stats
+// +build !stats
+
+package set
+
+// StatsEnabled reports whether the sets collect Stats.
+const StatsEnabled = false
+
+// Without stats build tag the counters take no space, and the calls are inlined into nothing.
+type statsCounters struct{}
+
+func (*statsCounters) operation()         {}
+func (*statsCounters) traverse(int)       {}
+func (*statsCounters) validationFailure() {}
+func (*statsCounters) casFailure()        {}
+func (*statsCounters) restart()           {}
+func (*statsCounters) snip()              {}
+
+func (*statsCounters) lock(m *nodeMutex)    { m.Lock() }
+func (*statsCounters) lockSet(m *setMutex)  { m.Lock() }
+func (*statsCounters) rlockSet(m *setMutex) { m.RLock() }
+
+func (*statsCounters) snapshot() Stats { return Stats{} }
@@ -0,0 +1,74 @@
+//go:build stats
+// +build stats
+
+package set
+
+import (
+	"sync/atomic"
+	"time"
+)
+
+// StatsEnabled reports whether the sets collect Stats.
+const StatsEnabled = true
+
+// statsCounters are shared by all goroutines working with the set, so collecting them has a cost:
+// build with `-tags stats` only to investigate the behaviour of the algorithms.
+type statsCounters struct {
+	operations         int64
+	traversed          int64
+	validationFailures int64
+	casFailures        int64
+	restarts           int64
+	snips              int64
+	lockWait           int64
+}
+
+func (c *statsCounters) operation()         { atomic.AddInt64(&c.operations, 1) }
+func (c *statsCounters) traverse(nodes int) { atomic.AddInt64(&c.traversed, int64(nodes)) }
+func (c *statsCounters) validationFailure() { atomic.AddInt64(&c.validationFailures, 1) }
+func (c *statsCounters) casFailure()        { atomic.AddInt64(&c.casFailures, 1) }
+func (c *statsCounters) restart()           { atomic.AddInt64(&c.restarts, 1) }
+func (c *statsCounters) snip()              { atomic.AddInt64(&c.snips, 1) }
+
+// lock measures the time of waiting only if the lock is contended: the fast path stays untimed.
+func (c *statsCounters) lock(m *nodeMutex) {
+	if m.TryLock() {
+		return
+	}
+
+	start := time.Now()
+	m.Lock()
+	atomic.AddInt64(&c.lockWait, int64(time.Since(start)))
+}
+
+func (c *statsCounters) lockSet(m *setMutex) {
+	if m.TryLock() {
+		return
+	}
+
+	start := time.Now()
+	m.Lock()
+	atomic.AddInt64(&c.lockWait, int64(time.Since(start)))
+}
+
+func (c *statsCounters) rlockSet(m *setMutex) {
+	if m.TryRLock() {
+		return
+	}
+
+	start := time.Now()
+	m.RLock()
+	atomic.AddInt64(&c.lockWait, int64(time.Since(start)))
+}
+
+func (c *statsCounters) snapshot() Stats {
+	return Stats{
+		Operations:         atomic.LoadInt64(&c.operations),
+		Traversed:          atomic.LoadInt64(&c.traversed),
+		ValidationFailures: atomic.LoadInt64(&c.validationFailures),
+		CASFailures:        atomic.LoadInt64(&c.casFailures),
+		Restarts:           atomic.LoadInt64(&c.restarts),
+		Snips:              atomic.LoadInt64(&c.snips),
+		LockWait:           time.Duration(atomic.LoadInt64(&c.lockWait)),
+	}
+}
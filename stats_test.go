@@ -0,0 +1,151 @@
+package set
+
+import (
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+)
+
+// TestStats verifies that the counters are collected only with stats build tag,
+// and that every completed operation is counted exactly once.
+func TestStats(t *testing.T) {
+	f := factory{}
+
+	kinds := []setKind{
+		coarseGrained,
+		fineGrained,
+		optimistic,
+		lazy,
+		nonBlocking,
+		hazardNonBlocking,
+	}
+
+	const (
+		threads = 4
+		items   = 200
+	)
+
+	for _, k := range kinds {
+		k := k
+
+		t.Run(k.String(), func(t *testing.T) {
+			set := f.new(k)
+
+			wg := sync.WaitGroup{}
+			wg.Add(threads)
+
+			for i := 0; i < threads; i++ {
+				go func() {
+					defer wg.Done()
+
+					for j := 0; j < items; j++ {
+						set.Insert(j)
+						set.Contains(j)
+						set.Remove(j)
+					}
+				}()
+			}
+
+			wg.Wait()
+
+			provider, ok := set.(StatsProvider)
+			require.True(t, ok)
+
+			stats := provider.Stats()
+
+			if !StatsEnabled {
+				require.Equal(t, Stats{}, stats)
+
+				return
+			}
+
+			require.Equal(t, int64(threads*items*3), stats.Operations)
+
+			if k != coarseGrained && k != fineGrained {
+				require.GreaterOrEqual(t, stats.TraversedPerOperation(), 1.0)
+			}
+		})
+	}
+}
+
+func TestStatsSequential(t *testing.T) {
+	set := NewSequentialSet()
+	require.True(t, set.Insert(1))
+	require.True(t, set.Contains(1))
+
+	provider, ok := set.(StatsProvider)
+	require.True(t, ok)
+	require.Equal(t, Stats{}, provider.Stats())
+}
+
+// TestStatsEvents provokes the events deterministically.
+func TestStatsEvents(t *testing.T) {
+	if !StatsEnabled {
+		t.Skip("build with -tags stats")
+	}
+
+	t.Run("snips", func(t *testing.T) {
+		s, ok := NewNonBlockingSyncSet().(*nonBlockingSet)
+		require.True(t, ok)
+
+		require.True(t, s.Insert(1))
+		require.True(t, s.Insert(2))
+
+		// mark the node without unlinking it, as if the removing goroutine was descheduled
+		w := s.findWindow(nil, 1)
+		succ := w.curr.next.getNode()
+		require.True(t, w.curr.next.compareAndSet(succ, succ, false, true))
+
+		require.True(t, s.Contains(2))
+		require.Zero(t, s.Stats().Snips)
+
+		require.True(t, s.Insert(3))
+		require.Equal(t, int64(1), s.Stats().Snips)
+	})
+
+	t.Run("lock wait", func(t *testing.T) {
+		s, ok := NewCoarseGrainedSyncSet().(*coarseGrainedSyncSet)
+		require.True(t, ok)
+
+		const hold = 10 * time.Millisecond
+
+		s.mutex.Lock()
+
+		done := make(chan struct{})
+
+		go func() {
+			defer close(done)
+
+			s.Insert(1)
+		}()
+
+		time.Sleep(hold)
+		s.mutex.Unlock()
+		<-done
+
+		require.GreaterOrEqual(t, s.Stats().LockWait, hold/2)
+
+		// uncontended locks are not timed
+		before := s.Stats().LockWait
+		require.True(t, s.Contains(1))
+		require.Equal(t, before, s.Stats().LockWait)
+	})
+
+	t.Run("traversed", func(t *testing.T) {
+		s, ok := NewLazySyncSet().(*lazySyncSet)
+		require.True(t, ok)
+
+		for i := 1; i <= 10; i++ {
+			require.True(t, s.Insert(i))
+		}
+
+		before := s.Stats()
+
+		// head is the starting point, the nodes 1..10 and the tail are visited
+		require.False(t, s.Contains(11))
+		require.Equal(t, int64(11), s.Stats().Traversed-before.Traversed)
+		require.Zero(t, s.Stats().ValidationFailures)
+	})
+}
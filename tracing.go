@@ -0,0 +1,230 @@
+package set
+
+import (
+	"context"
+	"runtime/pprof"
+	"runtime/trace"
+)
+
+// names of the implementations used in pprof labels and trace tasks.
+const (
+	sequentialName        = "sequential"
+	coarseGrainedName     = "coarse_grained"
+	fineGrainedName       = "fine_grained"
+	optimisticName        = "optimistic"
+	lazyName              = "lazy"
+	nonBlockingName       = "nonblocking"
+	hazardNonBlockingName = "nonblocking_hazard"
+	stmName               = "stm"
+	mvccName              = "mvcc"
+)
+
+// WithTracing makes every Insert, Contains and Remove call observable by the profiling tools:
+// the call runs with pprof labels "implementation" and "operation", so CPU profiles can be broken down
+// by them (e.g. `go tool pprof -tagfocus operation=insert`), and, while runtime/trace is running,
+// the call is a trace task containing a region of the same name (e.g. "lazy.insert").
+// Transactions of STM set are traced as a whole under operation "atomically", while the versioned reads
+// of MVCC set are not traced. Tracing costs a few allocations per operation, so it's disabled by default.
+func WithTracing() Option {
+	return func(o *options) {
+		o.tracing = true
+	}
+}
+
+// trace wraps the set into tracedSet if tracing is enabled.
+func (o *options) trace(s Set, implementation string) Set {
+	if !o.tracing {
+		return s
+	}
+
+	t := newTracedSet(s, implementation)
+
+	if _, ok := s.(Snapshotter); ok {
+		return &tracedSnapshotter{tracedSet: t}
+	}
+
+	return t
+}
+
+// traceTransactional wraps STM set if tracing is enabled.
+func (o *options) traceTransactional(s TransactionalSet) TransactionalSet {
+	if !o.tracing {
+		return s
+	}
+
+	return &tracedTransactionalSet{
+		tracedSnapshotter:  &tracedSnapshotter{tracedSet: newTracedSet(s, stmName)},
+		transactional:      s,
+		atomicallyName:     stmName + ".atomically",
+		atomicallyLabelSet: pprof.Labels("implementation", stmName, "operation", "atomically"),
+	}
+}
+
+// traceVersioned wraps MVCC set if tracing is enabled.
+func (o *options) traceVersioned(s VersionedSet) VersionedSet {
+	if !o.tracing {
+		return s
+	}
+
+	return &tracedVersionedSet{
+		tracedSnapshotter: &tracedSnapshotter{tracedSet: newTracedSet(s, mvccName)},
+		versioned:         s,
+	}
+}
+
+func newTracedSet(s Set, implementation string) *tracedSet {
+	t := &tracedSet{set: s}
+
+	for _, op := range []Operation{OperationInsert, OperationContains, OperationRemove} {
+		t.names[op] = implementation + "." + op.String()
+		t.labels[op] = pprof.Labels("implementation", implementation, "operation", op.String())
+	}
+
+	return t
+}
+
+var (
+	_ Set              = (*tracedSet)(nil)
+	_ Iterable         = (*tracedSet)(nil)
+	_ StatsProvider    = (*tracedSet)(nil)
+	_ TransactionalSet = (*tracedTransactionalSet)(nil)
+	_ VersionedSet     = (*tracedVersionedSet)(nil)
+)
+
+// tracedSet runs the operations of the underlying set under pprof labels and runtime/trace tasks.
+type tracedSet struct {
+	set    Set
+	names  [OperationRemove + 1]string
+	labels [OperationRemove + 1]pprof.LabelSet
+}
+
+func (t *tracedSet) Insert(value int) bool {
+	var result bool
+
+	t.do(OperationInsert, func() { result = t.set.Insert(value) })
+
+	return result
+}
+
+func (t *tracedSet) Contains(value int) bool {
+	var result bool
+
+	t.do(OperationContains, func() { result = t.set.Contains(value) })
+
+	return result
+}
+
+func (t *tracedSet) Remove(value int) bool {
+	var result bool
+
+	t.do(OperationRemove, func() { result = t.set.Remove(value) })
+
+	return result
+}
+
+// applyBatch traces the whole batch as a single operation.
+func (t *tracedSet) applyBatch(op Operation, keys []batchKey, results []bool) {
+	t.do(op, func() {
+		if batch, ok := t.set.(batchSet); ok {
+			batch.applyBatch(op, keys, results)
+		} else {
+			applyOneByOne(t.set, op, keys, results)
+		}
+	})
+}
+
+func (t *tracedSet) do(op Operation, f func()) {
+	traced(t.names[op], t.labels[op], f)
+}
+
+// traced runs f under the labels and, while runtime/trace is running, in the task and the region of the name.
+func traced(name string, labels pprof.LabelSet, f func()) {
+	pprof.Do(context.Background(), labels, func(ctx context.Context) {
+		// tasks and regions are cheap no-ops when tracing is off, but the check avoids creating the context
+		if !trace.IsEnabled() {
+			f()
+
+			return
+		}
+
+		ctx, task := trace.NewTask(ctx, name)
+		defer task.End()
+
+		trace.WithRegion(ctx, name, f)
+	})
+}
+
+func (t *tracedSet) appender() func(value int) {
+	return appenderOf(t.set)
+}
+
+func (t *tracedSet) Ascend(yield func(value int) bool) {
+	if iterable, ok := t.set.(Iterable); ok {
+		iterable.Ascend(yield)
+	}
+}
+
+func (t *tracedSet) Stats() Stats {
+	if provider, ok := t.set.(StatsProvider); ok {
+		return provider.Stats()
+	}
+
+	return Stats{}
+}
+
+// tracedSnapshotter is tracedSet of a set supporting snapshots.
+type tracedSnapshotter struct {
+	*tracedSet
+}
+
+func (t *tracedSnapshotter) Snapshot() Set {
+	snapshotter, _ := t.set.(Snapshotter)
+
+	return snapshotter.Snapshot()
+}
+
+func (t *tracedSet) checkInvariants() error {
+	if checker, ok := t.set.(invariantChecker); ok {
+		return checker.checkInvariants()
+	}
+
+	return nil
+}
+
+// tracedTransactionalSet is tracedSet of STM set: a transaction is traced as a single operation.
+type tracedTransactionalSet struct {
+	*tracedSnapshotter
+	transactional      TransactionalSet
+	atomicallyName     string
+	atomicallyLabelSet pprof.LabelSet
+}
+
+func (t *tracedTransactionalSet) Atomically(f func(tx Tx) error) error {
+	var err error
+
+	traced(t.atomicallyName, t.atomicallyLabelSet, func() { err = t.transactional.Atomically(f) })
+
+	return err
+}
+
+// tracedVersionedSet is tracedSet of MVCC set; the versioned reads are not traced.
+type tracedVersionedSet struct {
+	*tracedSnapshotter
+	versioned VersionedSet
+}
+
+func (t *tracedVersionedSet) Version() uint64 { return t.versioned.Version() }
+
+func (t *tracedVersionedSet) ContainsAt(value int, version uint64) (bool, error) {
+	return t.versioned.ContainsAt(value, version)
+}
+
+func (t *tracedVersionedSet) RangeAt(version uint64) ([]int, error) {
+	return t.versioned.RangeAt(version)
+}
+
+func (t *tracedVersionedSet) Pin() uint64 { return t.versioned.Pin() }
+
+func (t *tracedVersionedSet) Unpin(version uint64) { t.versioned.Unpin(version) }
+
+func (t *tracedVersionedSet) Collect() int { return t.versioned.Collect() }
@@ -0,0 +1,128 @@
+package set
+
+import (
+	"bytes"
+	"runtime/pprof"
+	"runtime/trace"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+// goroutineLabels returns the profile of goroutines in the text format, which includes their pprof labels.
+func goroutineLabels(t *testing.T) string {
+	buf := &bytes.Buffer{}
+	require.NoError(t, pprof.Lookup("goroutine").WriteTo(buf, 1))
+
+	return buf.String()
+}
+
+func TestTracing(t *testing.T) {
+	f := factory{}
+
+	kinds := []setKind{
+		sequential,
+		coarseGrained,
+		fineGrained,
+		optimistic,
+		lazy,
+		nonBlocking,
+		hazardNonBlocking,
+		stm,
+		mvcc,
+	}
+
+	for _, k := range kinds {
+		k := k
+
+		t.Run(k.String(), func(t *testing.T) {
+			var profiles []string
+
+			hooks := Hooks{
+				OnInsert: func(int) { profiles = append(profiles, goroutineLabels(t)) },
+				OnRemove: func(int) { profiles = append(profiles, goroutineLabels(t)) },
+			}
+
+			set := f.new(k, WithTracing(), WithHooks(hooks))
+
+			require.True(t, set.Insert(1))
+			require.True(t, set.Contains(1))
+			require.True(t, set.Remove(1))
+
+			checker, ok := set.(invariantChecker)
+			require.True(t, ok)
+			require.NoError(t, checker.checkInvariants())
+
+			require.Len(t, profiles, 2)
+			require.Contains(t, profiles[0], `"implementation":"`+k.String()+`"`)
+			require.Contains(t, profiles[0], `"operation":"insert"`)
+			require.Contains(t, profiles[1], `"operation":"remove"`)
+
+			// labels are removed once the operation is over
+			require.NotContains(t, goroutineLabels(t), `"implementation":"`+k.String()+`"`)
+		})
+	}
+}
+
+func TestTracingTransactional(t *testing.T) {
+	var profiles []string
+
+	hooks := Hooks{OnInsert: func(int) { profiles = append(profiles, goroutineLabels(t)) }}
+	set := NewSTMSet(WithTracing(), WithHooks(hooks))
+
+	_, ok := set.(*tracedTransactionalSet)
+	require.True(t, ok)
+
+	require.NoError(t, set.Atomically(func(tx Tx) error {
+		tx.Insert(1)
+		tx.Insert(2)
+
+		return nil
+	}))
+
+	require.Len(t, profiles, 2)
+	require.Contains(t, profiles[0], `"operation":"atomically"`)
+	require.Equal(t, []int{1, 2}, ascend(t, set))
+
+	_, ok = set.(Snapshotter)
+	require.True(t, ok)
+}
+
+func TestTracingVersioned(t *testing.T) {
+	set := NewMVCCSet(WithTracing())
+
+	_, ok := set.(*tracedVersionedSet)
+	require.True(t, ok)
+
+	require.True(t, set.Insert(1))
+	version := set.Pin()
+	require.True(t, set.Remove(1))
+
+	present, err := set.ContainsAt(1, version)
+	require.NoError(t, err)
+	require.True(t, present)
+
+	values, err := set.RangeAt(set.Version())
+	require.NoError(t, err)
+	require.Empty(t, values)
+
+	set.Unpin(version)
+	require.Equal(t, 1, set.Collect())
+}
+
+func TestTracingTasks(t *testing.T) {
+	buf := &bytes.Buffer{}
+	if err := trace.Start(buf); err != nil {
+		t.Skipf("tracing is already enabled: %v", err)
+	}
+
+	set := NewLazySyncSet(WithTracing())
+	require.True(t, set.Insert(1))
+	require.False(t, set.Contains(2))
+
+	trace.Stop()
+
+	// the names of tasks and regions are stored in the string table of the trace
+	require.Contains(t, buf.String(), "lazy.insert")
+	require.Contains(t, buf.String(), "lazy.contains")
+}
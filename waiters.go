@@ -0,0 +1,121 @@
+package set
+
+import (
+	"context"
+	"sync"
+	"sync/atomic"
+)
+
+// waiters are the goroutines waiting for the next event of a key: the event closes their channels.
+// A waiter must be added before checking the condition it waits for, so the event right after the check is not missed.
+type waiters struct {
+	// size is the number of waiters; the events check it without the mutex
+	size     int32
+	mutex    sync.Mutex
+	channels map[int][]chan struct{}
+}
+
+func (w *waiters) add(key int) chan struct{} {
+	ch := make(chan struct{})
+
+	w.mutex.Lock()
+	defer w.mutex.Unlock()
+
+	if w.channels == nil {
+		w.channels = make(map[int][]chan struct{})
+	}
+
+	w.channels[key] = append(w.channels[key], ch)
+	atomic.AddInt32(&w.size, 1)
+
+	return ch
+}
+
+// wait returns nil if the condition holds or once the next event of the key happens, and the error of ctx if it is done first.
+func (w *waiters) wait(ctx context.Context, key int, condition func() bool) error {
+	ch := w.add(key)
+
+	if condition() {
+		w.remove(key, ch)
+
+		return nil
+	}
+
+	select {
+	case <-ch:
+		return nil
+	case <-ctx.Done():
+		w.remove(key, ch)
+
+		return ctx.Err()
+	}
+}
+
+// remove unregisters the waiter unless it has been woken up already.
+func (w *waiters) remove(key int, ch chan struct{}) {
+	w.mutex.Lock()
+	defer w.mutex.Unlock()
+
+	channels := w.channels[key]
+
+	for i := range channels {
+		if channels[i] == ch {
+			channels = append(channels[:i], channels[i+1:]...)
+			atomic.AddInt32(&w.size, -1)
+
+			break
+		}
+	}
+
+	if len(channels) == 0 {
+		delete(w.channels, key)
+	} else {
+		w.channels[key] = channels
+	}
+}
+
+// wake wakes up all waiters of the key.
+func (w *waiters) wake(key int) {
+	// the waiter is added before it checks the condition, so it is either counted here or sees the event
+	if atomic.LoadInt32(&w.size) == 0 {
+		return
+	}
+
+	w.mutex.Lock()
+	defer w.mutex.Unlock()
+
+	for _, ch := range w.channels[key] {
+		close(ch)
+	}
+
+	atomic.AddInt32(&w.size, -int32(len(w.channels[key])))
+	delete(w.channels, key)
+}
+
+// wakeOne wakes up the waiter of the key that has been waiting the longest.
+func (w *waiters) wakeOne(key int) {
+	if atomic.LoadInt32(&w.size) == 0 {
+		return
+	}
+
+	w.mutex.Lock()
+	defer w.mutex.Unlock()
+
+	channels := w.channels[key]
+	if len(channels) == 0 {
+		return
+	}
+
+	close(channels[0])
+	atomic.AddInt32(&w.size, -1)
+
+	if len(channels) == 1 {
+		delete(w.channels, key)
+	} else {
+		w.channels[key] = channels[1:]
+	}
+}
+
+func (w *waiters) len() int {
+	return int(atomic.LoadInt32(&w.size))
+}
@@ -0,0 +1,327 @@
+package set
+
+import (
+	"context"
+	"sync"
+)
+
+// Event is a successful update of a watched set: OperationInsert or OperationRemove of the value.
+type Event struct {
+	Operation Operation
+	Value     int
+}
+
+// OverflowPolicy decides what happens to an event when the buffer of the watcher is full.
+type OverflowPolicy int8
+
+const (
+	// OverflowBlock makes the update wait until the watcher takes an event from the buffer.
+	OverflowBlock OverflowPolicy = iota + 1
+	// OverflowDrop discards the new event.
+	OverflowDrop
+	// OverflowCoalesce discards the buffered event of the same value and appends the new one,
+	// so the watcher still receives the latest event of every value. If no event of the value is buffered,
+	// the update waits like with OverflowBlock.
+	OverflowCoalesce
+)
+
+func (p OverflowPolicy) String() string {
+	switch p {
+	case OverflowBlock:
+		return "block"
+	case OverflowDrop:
+		return "drop"
+	case OverflowCoalesce:
+		return "coalesce"
+	default:
+		panic("unknown OverflowPolicy")
+	}
+}
+
+// WatchOption configures a watcher.
+type WatchOption func(*watchOptions)
+
+type watchOptions struct {
+	bufferSize int
+	overflow   OverflowPolicy
+}
+
+// WithBufferSize sets the number of events buffered for a slow watcher (64 by default).
+func WithBufferSize(size int) WatchOption {
+	return func(o *watchOptions) {
+		if size > 0 {
+			o.bufferSize = size
+		}
+	}
+}
+
+// WithOverflowPolicy sets the policy applied when the buffer is full (OverflowBlock by default).
+func WithOverflowPolicy(policy OverflowPolicy) WatchOption {
+	return func(o *watchOptions) {
+		o.overflow = policy
+	}
+}
+
+// WatchedSet notifies the watchers about the successful updates.
+type WatchedSet interface {
+	Set
+	// Watch returns the channel of the events of the updates made after the call, in the order of the updates.
+	// The channel is closed once ctx is done.
+	Watch(ctx context.Context, opts ...WatchOption) <-chan Event
+	// WaitFor blocks until the value is present or ctx is done. The value may be removed again by the time
+	// WaitFor returns: it guarantees only that the value has been present at some moment during the call.
+	WaitFor(ctx context.Context, value int) error
+}
+
+// watchStripes is the number of locks ordering the updates of the values.
+const watchStripes = 64
+
+var _ WatchedSet = (*watchedSet)(nil)
+
+// watchedSet publishes the events of the updates while holding the lock of the value, so the events
+// of the same value are published in the order of the updates. The updates of different values are not
+// serialized: the events of concurrent updates are published in any order, but an update that has returned
+// is always published before the updates that begin afterwards.
+type watchedSet struct {
+	set     Set
+	stripes [watchStripes]sync.Mutex
+	// watchers is replaced on every change under watchersMutex, so the publishers take it under the read lock
+	// and push the events after releasing the lock: a blocked publisher doesn't block the registration
+	watchersMutex sync.RWMutex
+	watchers      []*watcher
+	// inserted are woken up by the next insertion of the value
+	inserted waiters
+}
+
+func (s *watchedSet) stripe(value int) *sync.Mutex {
+	return &s.stripes[uint(value)%watchStripes]
+}
+
+func (s *watchedSet) Insert(value int) bool {
+	m := s.stripe(value)
+	m.Lock()
+	defer m.Unlock()
+
+	if !s.set.Insert(value) {
+		return false
+	}
+
+	s.publish(Event{Operation: OperationInsert, Value: value})
+
+	return true
+}
+
+func (s *watchedSet) Contains(value int) bool {
+	return s.set.Contains(value)
+}
+
+func (s *watchedSet) Remove(value int) bool {
+	m := s.stripe(value)
+	m.Lock()
+	defer m.Unlock()
+
+	if !s.set.Remove(value) {
+		return false
+	}
+
+	s.publish(Event{Operation: OperationRemove, Value: value})
+
+	return true
+}
+
+func (s *watchedSet) publish(e Event) {
+	if e.Operation == OperationInsert {
+		s.inserted.wake(e.Value)
+	}
+
+	s.watchersMutex.RLock()
+	watchers := s.watchers
+	s.watchersMutex.RUnlock()
+
+	for _, w := range watchers {
+		w.push(e)
+	}
+}
+
+func (s *watchedSet) register(w *watcher) {
+	s.watchersMutex.Lock()
+	defer s.watchersMutex.Unlock()
+
+	watchers := make([]*watcher, 0, len(s.watchers)+1)
+	s.watchers = append(append(watchers, s.watchers...), w)
+}
+
+func (s *watchedSet) unregister(w *watcher) {
+	s.watchersMutex.Lock()
+	defer s.watchersMutex.Unlock()
+
+	watchers := make([]*watcher, 0, len(s.watchers))
+
+	for _, other := range s.watchers {
+		if other != w {
+			watchers = append(watchers, other)
+		}
+	}
+
+	s.watchers = watchers
+}
+
+func (s *watchedSet) Watch(ctx context.Context, opts ...WatchOption) <-chan Event {
+	o := &watchOptions{bufferSize: 64, overflow: OverflowBlock}
+
+	for _, opt := range opts {
+		opt(o)
+	}
+
+	w := &watcher{
+		ctx:     ctx,
+		options: o,
+		pending: make(map[int]int),
+		ready:   make(chan struct{}, 1),
+		space:   make(chan struct{}, 1),
+	}
+
+	s.register(w)
+
+	out := make(chan Event)
+
+	go func() {
+		w.run(out)
+		close(out)
+
+		// the publishers that have taken the watcher before it's unregistered don't wait for it once ctx is done
+		s.unregister(w)
+	}()
+
+	return out
+}
+
+func (s *watchedSet) WaitFor(ctx context.Context, value int) error {
+	return s.inserted.wait(ctx, value, func() bool { return s.set.Contains(value) })
+}
+
+// watcher buffers the events until its goroutine passes them to the channel returned by Watch.
+type watcher struct {
+	ctx     context.Context //nolint:containedctx // the watcher lives as long as the context
+	options *watchOptions
+	mutex   sync.Mutex
+	events  []Event
+	// pending counts the buffered events of every value, so that OverflowCoalesce finds them quickly
+	pending map[int]int
+	// ready and space wake up the goroutine of the watcher and the blocked publishers
+	ready chan struct{}
+	space chan struct{}
+}
+
+func signal(ch chan struct{}) {
+	select {
+	case ch <- struct{}{}:
+	default:
+	}
+}
+
+// push buffers the event according to the overflow policy.
+func (w *watcher) push(e Event) {
+	w.mutex.Lock()
+	defer w.mutex.Unlock()
+
+	for len(w.events) >= w.options.bufferSize {
+		switch {
+		case w.options.overflow == OverflowDrop:
+			return
+		case w.options.overflow == OverflowCoalesce && w.pending[e.Value] > 0:
+			w.dropPending(e.Value)
+
+			continue
+		}
+
+		w.mutex.Unlock()
+
+		select {
+		case <-w.space:
+		case <-w.ctx.Done():
+			w.mutex.Lock()
+
+			return
+		}
+
+		w.mutex.Lock()
+	}
+
+	w.events = append(w.events, e)
+	w.pending[e.Value]++
+
+	signal(w.ready)
+
+	// several publishers may be waiting for the space, pass the wakeup on
+	if len(w.events) < w.options.bufferSize {
+		signal(w.space)
+	}
+}
+
+// dropPending removes the oldest buffered event of the value.
+func (w *watcher) dropPending(value int) {
+	for i := range w.events {
+		if w.events[i].Value == value {
+			w.events = append(w.events[:i], w.events[i+1:]...)
+			w.forget(value)
+
+			return
+		}
+	}
+}
+
+func (w *watcher) forget(value int) {
+	if w.pending[value] == 1 {
+		delete(w.pending, value)
+	} else {
+		w.pending[value]--
+	}
+}
+
+// pop waits for the next event; it returns false once ctx is done.
+func (w *watcher) pop() (Event, bool) {
+	for {
+		w.mutex.Lock()
+
+		if len(w.events) > 0 {
+			e := w.events[0]
+			w.events = w.events[1:]
+			w.forget(e.Value)
+			w.mutex.Unlock()
+
+			signal(w.space)
+
+			return e, true
+		}
+
+		w.mutex.Unlock()
+
+		select {
+		case <-w.ready:
+		case <-w.ctx.Done():
+			return Event{}, false
+		}
+	}
+}
+
+func (w *watcher) run(out chan<- Event) {
+	for {
+		e, ok := w.pop()
+		if !ok {
+			return
+		}
+
+		select {
+		case out <- e:
+		case <-w.ctx.Done():
+			return
+		}
+	}
+}
+
+// NewWatchedSet wraps the set to notify the watchers about its updates.
+// All updates of the set must go through the wrapper.
+func NewWatchedSet(s Set) WatchedSet {
+	return &watchedSet{set: s}
+}
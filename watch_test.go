@@ -0,0 +1,375 @@
+package set
+
+import (
+	"context"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+)
+
+// receive takes n events from the channel.
+func receive(t *testing.T, events <-chan Event, n int) []Event {
+	t.Helper()
+
+	received := make([]Event, 0, n)
+
+	for len(received) < n {
+		select {
+		case e := <-events:
+			received = append(received, e)
+		case <-time.After(10 * time.Second):
+			t.Fatalf("received %d events of %d", len(received), n)
+		}
+	}
+
+	return received
+}
+
+func inserted(value int) Event { return Event{Operation: OperationInsert, Value: value} }
+
+func removed(value int) Event { return Event{Operation: OperationRemove, Value: value} }
+
+func TestWatch(t *testing.T) {
+	set := NewWatchedSet(NewLazySyncSet())
+	require.True(t, set.Insert(1))
+
+	ctx, cancel := context.WithCancel(context.Background())
+	events := set.Watch(ctx)
+
+	require.True(t, set.Insert(2))
+	require.False(t, set.Insert(2))
+	require.True(t, set.Remove(1))
+	require.False(t, set.Remove(1))
+	require.True(t, set.Contains(2))
+	require.True(t, set.Insert(1))
+
+	// the failed updates and the updates made before Watch are not reported
+	require.Equal(t, []Event{inserted(2), removed(1), inserted(1)}, receive(t, events, 3))
+
+	cancel()
+
+	for range events {
+		t.Fatal("the channel of a canceled watcher must be closed")
+	}
+
+	// the canceled watcher doesn't block the updates
+	for value := 0; value < 100; value++ {
+		set.Insert(value)
+	}
+}
+
+func TestWatchOverflow(t *testing.T) {
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	t.Run("drop", func(t *testing.T) {
+		set := NewWatchedSet(NewSequentialSet())
+		events := set.Watch(ctx, WithBufferSize(2), WithOverflowPolicy(OverflowDrop))
+
+		// the watcher doesn't read, so the events beyond the buffer and the one held by its goroutine are dropped
+		for value := 0; value < 10; value++ {
+			require.True(t, set.Insert(value))
+		}
+
+		require.Equal(t, []Event{inserted(0), inserted(1)}, receive(t, events, 2))
+
+		// there is room in the buffer again
+		require.True(t, set.Remove(0))
+
+		received := receive(t, events, 1)
+		if received[0] != removed(0) {
+			// the goroutine has taken the first event before the buffer became full, so one more event has been kept
+			require.Equal(t, inserted(2), received[0])
+			require.Equal(t, []Event{removed(0)}, receive(t, events, 1))
+		}
+	})
+
+	t.Run("coalesce", func(t *testing.T) {
+		set := NewWatchedSet(NewSequentialSet())
+		events := set.Watch(ctx, WithBufferSize(3), WithOverflowPolicy(OverflowCoalesce))
+
+		// one event is taken by the goroutine of the watcher, the buffer is full of the events of 1, 2 and 3
+		for value := 0; value < 4; value++ {
+			require.True(t, set.Insert(value))
+		}
+
+		require.Eventually(t, func() bool {
+			w := watcherOf(set)
+			w.mutex.Lock()
+			defer w.mutex.Unlock()
+
+			return len(w.events) == 3
+		}, 10*time.Second, time.Millisecond)
+
+		// the new events replace the buffered ones of the same values
+		require.True(t, set.Remove(2))
+		require.True(t, set.Insert(2))
+		require.True(t, set.Remove(1))
+
+		require.Equal(t, []Event{inserted(0), inserted(3), inserted(2), removed(1)}, receive(t, events, 4))
+	})
+
+	t.Run("block", func(t *testing.T) {
+		set := NewWatchedSet(NewCoarseGrainedSyncSet())
+		events := set.Watch(ctx, WithBufferSize(1), WithOverflowPolicy(OverflowBlock))
+
+		done := make(chan struct{})
+
+		go func() {
+			defer close(done)
+
+			for value := 0; value < 10; value++ {
+				set.Insert(value)
+			}
+		}()
+
+		select {
+		case <-done:
+			t.Fatal("the updates must wait for the watcher")
+		case <-time.After(10 * time.Millisecond):
+		}
+
+		received := receive(t, events, 10)
+		for value, e := range received {
+			require.Equal(t, inserted(value), e)
+		}
+
+		<-done
+	})
+}
+
+// watcherOf returns the only watcher of the set.
+func watcherOf(s WatchedSet) *watcher {
+	set, ok := s.(*watchedSet)
+	if !ok {
+		return nil
+	}
+
+	set.watchersMutex.RLock()
+	defer set.watchersMutex.RUnlock()
+
+	if len(set.watchers) != 1 {
+		return nil
+	}
+
+	return set.watchers[0]
+}
+
+// TestWatchBlockedPublisher cancels a watcher while a publisher is blocked on another one.
+func TestWatchBlockedPublisher(t *testing.T) {
+	set := NewWatchedSet(NewLazySyncSet())
+
+	blockingCtx, cancelBlocking := context.WithCancel(context.Background())
+	defer cancelBlocking()
+
+	// the watcher doesn't read: its goroutine holds the first event and the buffer holds the second one
+	set.Watch(blockingCtx, WithBufferSize(1))
+
+	ctx, cancel := context.WithCancel(context.Background())
+	events := set.Watch(ctx)
+
+	published := make(chan struct{})
+
+	go func() {
+		defer close(published)
+
+		for value := 0; value < 3; value++ {
+			set.Insert(value)
+		}
+	}()
+
+	require.Equal(t, []Event{inserted(0), inserted(1)}, receive(t, events, 2))
+
+	select {
+	case <-published:
+		t.Fatal("the third update must wait for the blocking watcher")
+	case <-time.After(10 * time.Millisecond):
+	}
+
+	cancel()
+
+	closed := make(chan struct{})
+
+	go func() {
+		defer close(closed)
+
+		// the event of the third update is received unless the publisher is blocked before pushing it
+		for range events {
+		}
+	}()
+
+	select {
+	case <-closed:
+	case <-time.After(10 * time.Second):
+		t.Fatal("the channel of a canceled watcher must be closed while the publisher is blocked")
+	}
+
+	watchCtx, cancelWatch := context.WithCancel(context.Background())
+	defer cancelWatch()
+
+	watched := make(chan struct{})
+
+	go func() {
+		defer close(watched)
+
+		set.Watch(watchCtx)
+	}()
+
+	select {
+	case <-watched:
+	case <-time.After(10 * time.Second):
+		t.Fatal("Watch must not wait for the blocked publisher")
+	}
+
+	cancelBlocking()
+	<-published
+}
+
+// TestWatchConcurrent updates the same values from concurrent writers: the events of every value
+// must alternate between insertions and removals, and they must bring the watcher to the final state of the set.
+func TestWatchConcurrent(t *testing.T) {
+	f := factory{}
+
+	kinds := []setKind{
+		coarseGrained,
+		fineGrained,
+		optimistic,
+		lazy,
+		nonBlocking,
+		hazardNonBlocking,
+		stm,
+		mvcc,
+	}
+
+	const (
+		threads = 4
+		items   = 16
+		rounds  = 100
+	)
+
+	for _, k := range kinds {
+		k := k
+
+		t.Run(k.String(), func(t *testing.T) {
+			set := NewWatchedSet(f.new(k))
+
+			ctx, cancel := context.WithCancel(context.Background())
+			defer cancel()
+
+			events := set.Watch(ctx, WithBufferSize(8))
+
+			type result struct {
+				state     map[int]bool
+				received  int64
+				unordered []Event
+			}
+
+			results := make(chan result)
+
+			go func() {
+				r := result{state: make(map[int]bool)}
+
+				// the event of the final insertion comes after the events of all writers
+				for e := range events {
+					r.received++
+
+					if (e.Operation == OperationInsert) == r.state[e.Value] {
+						r.unordered = append(r.unordered, e)
+					}
+
+					r.state[e.Value] = e.Operation == OperationInsert
+
+					if e.Value == items {
+						break
+					}
+				}
+
+				results <- r
+			}()
+
+			var (
+				wg         sync.WaitGroup
+				successful int64
+			)
+
+			wg.Add(threads)
+
+			for i := 0; i < threads; i++ {
+				i := i
+
+				go func() {
+					defer wg.Done()
+
+					for j := 0; j < rounds; j++ {
+						for value := 0; value < items; value++ {
+							var ok bool
+
+							if (i+j+value)%2 == 0 {
+								ok = set.Insert(value)
+							} else {
+								ok = set.Remove(value)
+							}
+
+							if ok {
+								atomic.AddInt64(&successful, 1)
+							}
+						}
+					}
+				}()
+			}
+
+			wg.Wait()
+			require.True(t, set.Insert(items))
+
+			r := <-results
+			require.Empty(t, r.unordered)
+			require.Equal(t, successful+1, r.received)
+
+			for value := 0; value <= items; value++ {
+				require.Equal(t, set.Contains(value), r.state[value], value)
+			}
+		})
+	}
+}
+
+func TestWaitFor(t *testing.T) {
+	set := NewWatchedSet(NewLazySyncSet())
+	require.True(t, set.Insert(1))
+
+	// the present value is not waited for
+	require.NoError(t, set.WaitFor(context.Background(), 1))
+
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Millisecond)
+	defer cancel()
+
+	require.ErrorIs(t, set.WaitFor(ctx, 2), context.DeadlineExceeded)
+
+	// the waiters are woken up by the concurrent insertions of their values
+	const waiters = 64
+
+	errs := make(chan error, waiters)
+
+	for value := 0; value < waiters; value++ {
+		value := value
+
+		go func() {
+			errs <- set.WaitFor(context.Background(), value%8)
+		}()
+	}
+
+	for value := 0; value < 8; value++ {
+		set.Remove(value)
+		set.Insert(value)
+	}
+
+	for i := 0; i < waiters; i++ {
+		require.NoError(t, <-errs)
+	}
+
+	watched, ok := set.(*watchedSet)
+	require.True(t, ok)
+	require.Zero(t, watched.inserted.len())
+}